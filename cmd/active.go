@@ -0,0 +1,39 @@
+package main
+
+import (
+	"sync"
+
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+)
+
+// 当前出站，即 BestNodeClient 首先尝试的延迟最低的健康节点
+var (
+	activeMutex sync.Mutex
+	activeID    string
+)
+
+// updateActive 重新选出当前出站，变化时发布 outbound.switched，没有健康节点时切换为空
+func updateActive() {
+	nodes, err := node.Active(&node.Filter{Healthy: true})
+	if err != nil {
+		logger.Error("Failed to list healthy nodes", "err", err.Error())
+		return
+	}
+	sortByLatency(nodes)
+	change := &event.Switch{}
+	if len(nodes) > 0 {
+		change = &event.Switch{To: nodes[0].ID, Name: nodes[0].DisplayName(), Latency: nodes[0].Latency}
+	}
+
+	activeMutex.Lock()
+	defer activeMutex.Unlock()
+	if change.To == activeID {
+		return
+	}
+	change.From = activeID
+	activeID = change.To
+	logger.Info("Switched active outbound", "from", change.From, "to", change.To)
+	event.Publish(event.OutboundSwitched, change)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+)
+
+const (
+	nodeFetchTimeout = 30 * time.Second
+	// 依次尝试延迟最低的几个节点
+	nodeFetchCandidates = 3
+)
+
+// BestNodeClient 返回经由延迟最低的健康节点的 HTTP 客户端，用于拉取直连被屏蔽的订阅
+func BestNodeClient() (*http.Client, func(), error) {
+	nodes, err := node.Active(&node.Filter{Healthy: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	sortByLatency(nodes)
+
+	for _, n := range nodes[:min(len(nodes), nodeFetchCandidates)] {
+		if n.Outbound == nil {
+			continue
+		}
+		vxrayInstance, err := StartOutbound(n.Outbound)
+		if err != nil {
+			logger.Debug("Failed to start node for fetching", "id", n.ID, "err", err.Error())
+			continue
+		}
+
+		client := BuildProxyClient(vxrayInstance)
+		client.Timeout = nodeFetchTimeout
+		return client, func() { vxrayInstance.Close() }, nil
+	}
+	return nil, nil, fmt.Errorf("no healthy node to fetch through")
+}
+
+// sortByLatency 按延迟从低到高排序，未测出延迟的节点排在最后
+func sortByLatency(nodes []*node.Node) {
+	slices.SortStableFunc(nodes, func(a, b *node.Node) int {
+		switch {
+		case a.Latency == b.Latency:
+			return 0
+		case a.Latency == 0:
+			return 1
+		case b.Latency == 0:
+			return -1
+		}
+		return cmp.Compare(a.Latency, b.Latency)
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/internal/subscription"
+)
+
+// runImport 从标准输入或本地文件导入节点，例如
+// cat links.txt | vxrayui import -name local
+// vxrayui import -name local file:///etc/vxray/links
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	name := flags.String("name", "stdin", "subscription name of the imported nodes")
+	isBase64 := flags.Bool("base64", false, "input is base64 encoded")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	source := "stdin://"
+	if flags.NArg() > 0 {
+		source = flags.Arg(0)
+	}
+	if subscription.SourceType(source) == subscription.SourceRemote {
+		return fmt.Errorf("not a local source: %s", source)
+	}
+
+	sub := &config.Subscription{Name: *name, Url: source, IsBase64: *isBase64, Enabled: true}
+	outbounds := subscription.NewSubscriptionParser().ParseSubscription(sub)
+	if len(outbounds) == 0 {
+		return fmt.Errorf("no nodes in %s", source)
+	}
+	nodes := node.Save(sub, outbounds)
+	node.ResolveNodes(nodes)
+	logger.Info("Imported nodes", "source", source, "outbounds", len(outbounds), "saved", len(nodes))
+	return nil
+}
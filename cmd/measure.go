@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -8,11 +9,16 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	vxnet "github.com/xtls/xray-core/common/net"
 	vxcore "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/infra/conf"
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/internal/subscription"
 )
 
 type MeasureResult int
@@ -24,25 +30,101 @@ const (
 )
 
 const (
-	curlUrl = "http://www.gstatic.com/generate_204"
+	curlUrl  = "http://www.gstatic.com/generate_204"
+	traceUrl = "https://www.cloudflare.com/cdn-cgi/trace"
+
+	locateTimeout = 5 * time.Second
 )
 
-func OutboundMeasure(outbound *conf.OutboundDetourConfig) (MeasureResult, error) {
-	if outbound == nil {
-		return None, nil
+// Measurement 是一次节点测量的结果
+type Measurement struct {
+	Result MeasureResult
+	Delays []int64
+	// 通过节点访问 traceUrl 观察到的出口 IP
+	ExitIP string
+}
+
+// MeasureNode 测量节点并记录健康状态、延迟、生命周期与地理信息。
+// Select 以外的结果（包括 Delete）都算作失败，由生命周期决定隔离和退役；
+// 新节点首次得出结论时反馈给订阅选择，测量完成后发布事件并重新选出当前出站。
+// 返回保存后的节点，节点已被删除或保存失败时返回 nil
+func MeasureNode(n *node.Node) *node.Node {
+	measurement, err := OutboundMeasure(n.Outbound)
+	if err != nil {
+		logger.Debug("Failed to measure node", "id", n.ID, "err", err.Error())
 	}
 
-	vxrayConfig := conf.Config{
-		OutboundConfigs: []conf.OutboundDetourConfig{*outbound},
+	healthy := measurement.Result == Select
+	var latency time.Duration
+	if healthy {
+		latency = measurement.Latency()
 	}
-	vxrayConfigPb, err := vxrayConfig.Build()
+
+	// 在副本上定位，解析域名不占用存储事务
+	located := *n
+	if len(measurement.ExitIP) > 0 {
+		located.ExitIP = measurement.ExitIP
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), locateTimeout)
+	node.Locate(ctx, &located)
+	cancel()
+
+	// 重新读取节点，只改测量、生命周期和地理字段，不覆盖测量期间订阅解析等其它更新
+	now := time.Now()
+	var previous node.State
+	updated, err := node.Modify(n.ID, func(stored *node.Node) {
+		stored.Healthy = healthy
+		stored.LastCheck = now
+		stored.Checks++
+		stored.Latency = latency
+		if healthy {
+			stored.Successes++
+		}
+		previous = stored.State
+		stored.Observe(healthy, now)
+		stored.EntryIP, stored.Entry = located.EntryIP, located.Entry
+		stored.ExitIP, stored.Exit = located.ExitIP, located.Exit
+	})
 	if err != nil {
-		return None, err
+		logger.Error("Failed to update node", "id", n.ID, "err", err.Error())
+		return nil
 	}
+	if updated == nil {
+		logger.Debug("Measured node was removed", "id", n.ID)
+		return nil
+	}
+	// 派生字段不在存储中，沿用测量前读取的值
+	updated.Tags, updated.Pinned, updated.Blocked, updated.Persistence = n.Tags, n.Pinned, n.Blocked, n.Persistence
+	// 新节点的首个结论是其订阅的一次产出
+	if yield, settled := node.Verdict(previous, updated.State); settled {
+		subscription.Reward(updated.Source, yield)
+	}
+	event.Publish(event.MeasurementCompleted, &event.Measurement{Node: *updated.Event(previous), Healthy: healthy, Latency: latency})
+	updateActive()
+	return updated
+}
 
-	vxrayInstance, err := vxcore.New(vxrayConfigPb)
-	if err := vxrayInstance.Start(); err != nil {
-		return None, err
+// Latency 返回测量延迟的平均值
+func (measurement *Measurement) Latency() time.Duration {
+	if len(measurement.Delays) == 0 {
+		return 0
+	}
+	var total int64
+	for _, delay := range measurement.Delays {
+		total += delay
+	}
+	return time.Duration(total/int64(len(measurement.Delays))) * time.Millisecond
+}
+
+func OutboundMeasure(outbound *conf.OutboundDetourConfig) (*Measurement, error) {
+	measurement := &Measurement{Result: None}
+	if outbound == nil {
+		return measurement, nil
+	}
+
+	vxrayInstance, err := StartOutbound(outbound)
+	if err != nil {
+		return measurement, err
 	}
 	defer vxrayInstance.Close()
 
@@ -53,15 +135,16 @@ func OutboundMeasure(outbound *conf.OutboundDetourConfig) (MeasureResult, error)
 	for i := 0; i < times; i++ {
 		msec, err := OutBoundCurlDelay(client)
 		if err != nil && !os.IsTimeout(err) {
-			fmt.Println(err)
+			logger.Debug("Failed to measure delay", "err", err.Error())
 			break
 		}
-		fmt.Println("delay: ", msec)
+		logger.Debug("Measured delay", "delay", msec)
 		res = append(res, msec)
 	}
+	measurement.Delays = res
 
 	if len(res) < times {
-		return None, err
+		return measurement, err
 	}
 	d, s := 0, 0
 	for i := 0; i < times; i++ {
@@ -73,12 +156,36 @@ func OutboundMeasure(outbound *conf.OutboundDetourConfig) (MeasureResult, error)
 		}
 	}
 	if d >= times {
-		return Delete, err
+		measurement.Result = Delete
+		return measurement, err
 	}
 	if s >= times {
-		return Select, err
+		measurement.Result = Select
+		measurement.ExitIP, err = OutboundExitIP(client)
+		return measurement, err
+	}
+	return measurement, err
+}
+
+// StartOutbound 启动只包含该出站的 xray 实例，调用方负责 Close
+func StartOutbound(outbound *conf.OutboundDetourConfig) (*vxcore.Instance, error) {
+	vxrayConfig := conf.Config{
+		OutboundConfigs: []conf.OutboundDetourConfig{*outbound},
+	}
+	vxrayConfigPb, err := vxrayConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	vxrayInstance, err := vxcore.New(vxrayConfigPb)
+	if err != nil {
+		return nil, err
+	}
+	if err := vxrayInstance.Start(); err != nil {
+		vxrayInstance.Close()
+		return nil, err
 	}
-	return None, err
+	return vxrayInstance, nil
 }
 
 func BuildProxyClient(vxrayInstance *vxcore.Instance) *http.Client {
@@ -103,15 +210,33 @@ func OutBoundCurlDelay(client *http.Client) (delayMillisecond int64, err error)
 	startTime := time.Now()
 	resp, err := client.Get(curlUrl)
 	if err != nil {
-		fmt.Println("http err: ", err)
 		return math.MaxInt64, err
 	}
 	defer resp.Body.Close()
 	_, err = io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println("io err: ", err)
 		return math.MaxInt64, err
 	}
 
 	return time.Since(startTime).Milliseconds(), nil
 }
+
+// OutboundExitIP 通过节点请求 traceUrl，返回服务端看到的来源 IP
+func OutboundExitIP(client *http.Client) (string, error) {
+	resp, err := client.Get(traceUrl)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if ip, ok := strings.CutPrefix(scanner.Text(), "ip="); ok {
+			return ip, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no ip in %s", traceUrl)
+}
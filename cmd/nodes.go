@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/pkg/expr"
+)
+
+// runNodes 列出库中符合筛选表达式的节点，例如
+// vxrayui nodes -filter 'country in (JP, HK) and latency < 300ms'
+func runNodes(args []string) error {
+	flags := flag.NewFlagSet("nodes", flag.ExitOnError)
+	filterText := flags.String("filter", "", "node filter expression")
+	asJson := flags.Bool("json", false, "print nodes as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	e, err := node.ParseExpr(*filterText)
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	nodes, err := node.Select(&node.Filter{Exprs: []*expr.Expr{e}})
+	if err != nil {
+		return err
+	}
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(nodes)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tPROTOCOL\tTRANSPORT\tSECURITY\tCOUNTRY\tLATENCY\tSUCCESS\tNAME")
+	for _, n := range nodes {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			n.ID,
+			attributeText(n, "protocol"),
+			attributeText(n, "transport"),
+			attributeText(n, "security"),
+			attributeText(n, "country"),
+			attributeText(n, "latency"),
+			attributeText(n, "success_rate"),
+			n.Name,
+		)
+	}
+	return writer.Flush()
+}
+
+func attributeText(n *node.Node, name string) string {
+	value, ok := n.Attribute(name)
+	if !ok {
+		return "-"
+	}
+	if rate, ok := value.(float64); ok && name == "success_rate" {
+		return fmt.Sprintf("%.0f%%", rate*100)
+	}
+	return fmt.Sprint(value)
+}
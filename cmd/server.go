@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"os"
 
 	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/api"
+	"zhouxin.learn/go/vxrayui/internal/decision"
 	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/metrics"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/internal/notify"
+	"zhouxin.learn/go/vxrayui/internal/scheduler"
 	"zhouxin.learn/go/vxrayui/internal/storage"
 	"zhouxin.learn/go/vxrayui/internal/subscription"
 )
@@ -15,30 +23,84 @@ func main() {
 	config.Init()
 	logger.Init()
 	storage.Init()
+	node.InitGeo()
+	node.InitDNS()
+	node.InitLifecycle()
+	subscription.InitQuota()
 
-	sub := subscription.PickSubscription()
-	logger.Info("Picked subscription", "scheme", sub.Scheme, "url", sub.Url)
+	if flag.Arg(0) == "nodes" {
+		if err := runNodes(flag.Args()[1:]); err != nil {
+			logger.Error("Failed to list nodes", "err", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "import" {
+		if err := runImport(flag.Args()[1:]); err != nil {
+			logger.Error("Failed to import nodes", "err", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 先启动通知，初次拉取的失败和配额告警也会通知
+	notifier, err := notify.New(config.GetNotify())
+	if err != nil {
+		logger.Error("Invalid notify config", "err", err.Error())
+		os.Exit(1)
+	}
+	go notifier.Run()
+	defer notifier.Stop()
+
+	metrics.Init()
+	collector := metrics.NewCollector()
+	go collector.Run()
+	defer collector.Stop()
 
 	parser := subscription.NewSubscriptionParser()
-	_ = parser.ParseSubscription(sub)
-
-	/*
-		engine := decision.NewEngine([]decision.Strategy{
-			&decision.FreshnessStrategy{},
-			&decision.SourcePriorityStrategy{},
-		})
-
-		stats := stats.NewCollector(store)
-		sources := map[string]*subscription.SourceConfig{}
-
-		// 启动轮询器
-		poller := subscription.NewPoller(
-			parser,
-			store,
-			engine,
-			stats,
-			sources,
-		)
-		poller.Run()
-	*/
+	parser.SetNodeClient(BestNodeClient)
+	parse := func(sub *config.Subscription) []*node.Node {
+		outbounds := parser.ParseSubscription(sub)
+		nodes := node.Save(sub, outbounds)
+		node.ResolveNodes(nodes)
+		return nodes
+	}
+
+	if sub := subscription.PickSubscription(); sub != nil {
+		logger.Info("Picked subscription", "scheme", sub.Scheme, "url", sub.Url)
+		// 拉取失败或没有节点的订阅直接记一次失败，其余结果来自新节点的测量
+		if nodes := parse(sub); len(nodes) == 0 {
+			subscription.Reward(sub.Url, false)
+		}
+	} else {
+		logger.Error("No subscription to fetch")
+	}
+
+	// 本地文件和目录订阅变化后重新解析
+	watcher := subscription.NewWatcher(0, func(sub *config.Subscription) { parse(sub) })
+	go watcher.Run()
+	defer watcher.Stop()
+
+	// 远程、git 和网页订阅按各自学到的间隔轮询，内容变化时解析入库；
+	// 测量调度器随轮询器启停，新节点从未测量，调度器启动后立即测量
+	engine := decision.NewEngine([]decision.Strategy{
+		&decision.FreshnessStrategy{},
+		&decision.SourcePriorityStrategy{},
+		&decision.StabilityStrategy{},
+	})
+	poller := subscription.NewPoller(parser, storage.ConfigStore{}, engine, subscription.PollSources())
+	poller.SetScheduler(scheduler.NewFromConfig(MeasureNode))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go poller.Run(ctx)
+	defer poller.Stop()
+
+	compactor := node.NewCompactor()
+	go compactor.Run()
+	defer compactor.Stop()
+
+	server := api.NewServer(config.GetApi())
+	if err := server.Run(); err != nil {
+		logger.Error("API server exited", "err", err.Error())
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -29,22 +30,183 @@ type Logger struct {
 }
 
 type Subscription struct {
-	Name     string `json:"name" yaml:"name"`
+	Name string `json:"name" yaml:"name"`
+	// http(s):// remote subscription, file:// file or directory (its *.txt,
+	// *.yaml and *.json files, watched for changes), stdin://, git+<repo url>
+	// or scrape+<page url> (share links found in a web page)
 	Url      string `json:"url" yaml:"url"`
 	IsBase64 bool   `json:"is_base64" yaml:"is_base64"`
 	Enabled  bool   `json:"enabled" yaml:"enabled"`
 	Scheme   string `json:"scheme" yaml:"scheme"`
+	// fetch routes tried in order: direct, proxy (through Proxy) and node
+	// (through the best healthy node), empty means direct then node
+	Fetch []string `json:"fetch" yaml:"fetch"`
+	// upstream proxy of the proxy route, http://, https:// or socks5://
+	Proxy string `json:"proxy" yaml:"proxy"`
+	// a preset (clash, clash-meta, v2rayn, v2rayng, sing-box, shadowrocket,
+	// quantumult-x) or a literal User-Agent
+	UserAgent string            `json:"user_agent" yaml:"user_agent"`
+	Headers   map[string]string `json:"headers" yaml:"headers"`
+	Auth      *SubscriptionAuth `json:"auth" yaml:"auth"`
+	// files read from a git+ repository, e.g. Splitted-By-Protocol/vless.txt
+	Paths []string `json:"paths" yaml:"paths"`
+	// branch of a git+ repository, empty means the default branch
+	Branch string `json:"branch" yaml:"branch"`
+	// recent commits of a git+ repository inspected for node persistence
+	History int `json:"history" yaml:"history"`
+}
+
+type SubscriptionAuth struct {
+	// basic, bearer or query
+	Type     string `json:"type" yaml:"type"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	Token    string `json:"token" yaml:"token"`
+	// query parameter carrying the token of the query type, default token
+	Param string `json:"param" yaml:"param"`
 }
 
 type Storage struct {
 	Type string `json:"type" yaml:"type"`
 	Path string `json:"path" yaml:"path"`
+	// clones of git+ subscriptions, empty means git next to Path
+	GitDir string `json:"git_dir" yaml:"git_dir"`
+}
+
+type Api struct {
+	Listen string `json:"listen" yaml:"listen"`
+	// named node selections, served with ?export=<name>
+	Exports []*Export `json:"exports" yaml:"exports"`
+	// extra url-test groups added to every exported config
+	Balancers []*Balancer `json:"balancers" yaml:"balancers"`
+}
+
+type Export struct {
+	Name string `json:"name" yaml:"name"`
+	// node filter expression, e.g. "protocol in (vless, trojan) and latency < 500ms"
+	Filter string `json:"filter" yaml:"filter"`
+}
+
+type Balancer struct {
+	// group name in the exported config
+	Name string `json:"name" yaml:"name"`
+	// node filter expression selecting the group members
+	Filter string `json:"filter" yaml:"filter"`
+}
+
+type Geo struct {
+	// mmdb (City/Country/ASN) or xray geoip.dat files, earlier files win
+	Databases []string `json:"databases" yaml:"databases"`
+}
+
+type Dns struct {
+	// resolve and pin node hostnames in the node pipeline
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// queried directly to learn TTLs, empty uses the system resolver
+	Server string `json:"server" yaml:"server"`
+}
+
+type Lifecycle struct {
+	// consecutive successes that make a testing or degraded node healthy
+	HealthyAfter int `json:"healthy_after" yaml:"healthy_after"`
+	// consecutive failures that quarantine a node
+	QuarantineAfter int `json:"quarantine_after" yaml:"quarantine_after"`
+	// consecutive failures that retire a node
+	RetireAfter int `json:"retire_after" yaml:"retire_after"`
+	// delay before the first quarantine retest, doubled after every failed retest
+	RetestBackoff    time.Duration `json:"retest_backoff" yaml:"retest_backoff"`
+	MaxRetestBackoff time.Duration `json:"max_retest_backoff" yaml:"max_retest_backoff"`
+	// retired nodes are deleted from storage after this long
+	Retention       time.Duration `json:"retention" yaml:"retention"`
+	CompactInterval time.Duration `json:"compact_interval" yaml:"compact_interval"`
+}
+
+type Scheduler struct {
+	// how often healthy and degraded nodes are retested, degraded ones twice as often
+	ActiveInterval time.Duration `json:"active_interval" yaml:"active_interval"`
+	// how often new and testing nodes are tested
+	CandidateInterval time.Duration `json:"candidate_interval" yaml:"candidate_interval"`
+	// lower bound of the quarantine retest backoff
+	QuarantineInterval time.Duration `json:"quarantine_interval" yaml:"quarantine_interval"`
+	// random fraction added to or removed from every interval
+	Jitter float64 `json:"jitter" yaml:"jitter"`
+	// probes running at the same time
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+	// probes per minute against the nodes of one subscription
+	SubscriptionRate int `json:"subscription_rate" yaml:"subscription_rate"`
+	// probes per hour in total
+	Budget int `json:"budget" yaml:"budget"`
+	// how often due nodes are looked for
+	Tick time.Duration `json:"tick" yaml:"tick"`
+}
+
+type Quota struct {
+	// fractions of the total traffic whose crossing raises a warning
+	UsageThresholds []float64 `json:"usage_thresholds" yaml:"usage_thresholds"`
+	// warn when a subscription expires within this long
+	ExpireWithin time.Duration `json:"expire_within" yaml:"expire_within"`
+	// quota records kept per subscription
+	History int `json:"history" yaml:"history"`
+	// optional URL every new warning is POSTed to as JSON
+	Webhook string `json:"webhook" yaml:"webhook"`
+}
+
+type Notify struct {
+	// a subscription failing this long raises subscription_failing
+	FailingAfter time.Duration `json:"failing_after" yaml:"failing_after"`
+	Sinks        []*NotifySink `json:"sinks" yaml:"sinks"`
+}
+
+type NotifySink struct {
+	Name string `json:"name" yaml:"name"`
+	// webhook, telegram or email
+	Type string `json:"type" yaml:"type"`
+	// triggers sent to this sink, all when empty:
+	// active_changed, no_healthy, subscription_failing, quota
+	Events []string `json:"events" yaml:"events"`
+	// text/template per trigger replacing the default message
+	Templates map[string]string `json:"templates" yaml:"templates"`
+	RateLimit *RateLimit        `json:"rate_limit" yaml:"rate_limit"`
+
+	// webhook: notifications are POSTed as JSON
+	Url     string            `json:"url" yaml:"url"`
+	Headers map[string]string `json:"headers" yaml:"headers"`
+	// telegram: sent with the Bot API sendMessage
+	BotToken string `json:"bot_token" yaml:"bot_token"`
+	ChatId   string `json:"chat_id" yaml:"chat_id"`
+	// Bot API base, default https://api.telegram.org
+	ApiUrl string `json:"api_url" yaml:"api_url"`
+	// email
+	Smtp *Smtp `json:"smtp" yaml:"smtp"`
+}
+
+// RateLimit allows Burst notifications at once, refilled one per Interval
+type RateLimit struct {
+	Burst    int           `json:"burst" yaml:"burst"`
+	Interval time.Duration `json:"interval" yaml:"interval"`
+}
+
+type Smtp struct {
+	Host string `json:"host" yaml:"host"`
+	// 465 uses implicit TLS, other ports STARTTLS when the server offers it
+	Port     int      `json:"port" yaml:"port"`
+	Username string   `json:"username" yaml:"username"`
+	Password string   `json:"password" yaml:"password"`
+	From     string   `json:"from" yaml:"from"`
+	To       []string `json:"to" yaml:"to"`
 }
 
 type config struct {
 	Logger        *Logger         `json:"logger" yaml:"logger"`
 	Subscriptions []*Subscription `json:"subscriptions" yaml:"subscriptions"`
 	Storage       *Storage        `json:"storage" yaml:"storage"`
+	Api           *Api            `json:"api" yaml:"api"`
+	Geo           *Geo            `json:"geo" yaml:"geo"`
+	Dns           *Dns            `json:"dns" yaml:"dns"`
+	Lifecycle     *Lifecycle      `json:"lifecycle" yaml:"lifecycle"`
+	Scheduler     *Scheduler      `json:"scheduler" yaml:"scheduler"`
+	Quota         *Quota          `json:"quota" yaml:"quota"`
+	Notify        *Notify         `json:"notify" yaml:"notify"`
 }
 
 const DefalutScheme string = "mix"
@@ -70,6 +232,34 @@ func GetStorage() *Storage {
 	return cfg.Storage
 }
 
+func GetApi() *Api {
+	return cfg.Api
+}
+
+func GetGeo() *Geo {
+	return cfg.Geo
+}
+
+func GetDns() *Dns {
+	return cfg.Dns
+}
+
+func GetLifecycle() *Lifecycle {
+	return cfg.Lifecycle
+}
+
+func GetScheduler() *Scheduler {
+	return cfg.Scheduler
+}
+
+func GetQuota() *Quota {
+	return cfg.Quota
+}
+
+func GetNotify() *Notify {
+	return cfg.Notify
+}
+
 func Init() {
 	initOnce.Do(func() {
 		initConfig()
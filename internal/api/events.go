@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+)
+
+const (
+	// 每个流可积压的事件数，超过后丢弃
+	eventBuffer = 64
+	// 没有事件时定期发送注释，避免代理断开空闲连接
+	eventHeartbeat = 30 * time.Second
+)
+
+// handleEvents 以 Server-Sent Events 推送事件总线上的事件。
+// type 参数筛选事件类型，可重复或用逗号分隔，类别（如 node）匹配其下全部类型；
+// 重连时浏览器带上 Last-Event-ID，服务端补发总线仍保留的事件
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var types []event.Type
+	for _, value := range r.URL.Query()["type"] {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); len(t) > 0 {
+				types = append(types, event.Type(t))
+			}
+		}
+	}
+
+	// 先订阅再补发，补发和订阅重叠的事件按 ID 跳过
+	subscriber := event.Subscribe(eventBuffer, types...)
+	defer subscriber.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	var last uint64
+	if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		for _, e := range event.Since(id, types...) {
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			last = e.ID
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-subscriber.Events():
+			if !ok {
+				return
+			}
+			if e.ID <= last {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			last = e.ID
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.shutdown:
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent 写出一条 SSE 事件，订阅事件发布时已脱敏
+func writeEvent(w http.ResponseWriter, e event.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		logger.Error("Failed to encode event", "type", string(e.Type), "err", err.Error())
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload)
+	return err
+}
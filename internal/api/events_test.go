@@ -0,0 +1,38 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/subscription"
+)
+
+func TestHandleEventsRedactsSubscriptionFailure(t *testing.T) {
+	// nothing listens on port 1, so the fetch fails with the url in the error
+	sub := &config.Subscription{Url: "http://127.0.0.1:1/sub?token=secret", Fetch: []string{subscription.RouteDirect}}
+	subscription.NewSubscriptionParser().ParseSubscription(sub)
+
+	// replay the retained events, then return as the request is already done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/events?type="+string(event.SubscriptionFailed), nil).WithContext(ctx)
+	r.Header.Set("Last-Event-ID", "0")
+	w := httptest.NewRecorder()
+	NewServer(&config.Api{}).handleEvents(w, r)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "event: "+string(event.SubscriptionFailed)) {
+		t.Fatalf("no failure event in %q", body)
+	}
+	if !strings.Contains(body, "127.0.0.1:1/sub") {
+		t.Errorf("redacted url missing from %q", body)
+	}
+	if strings.Contains(body, "secret") {
+		t.Errorf("token leaked in %q", body)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/pkg/xray"
+)
+
+// handleClash 以 Clash Meta 订阅的形式导出健康节点
+func (s *Server) handleClash(w http.ResponseWriter, r *http.Request) {
+	filter, err := s.parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outbounds, healthy, groups, err := s.exportOutbounds(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := xray.ConvertOutboundsToClashYaml(outbounds, healthy, nil, groups...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="vxray.yaml"`)
+	w.Write(data)
+}
+
+// handleSingBox 以 sing-box 订阅的形式导出健康节点
+func (s *Server) handleSingBox(w http.ResponseWriter, r *http.Request) {
+	filter, err := s.parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outbounds, healthy, groups, err := s.exportOutbounds(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := xray.ConvertOutboundsToSingBoxJson(outbounds, healthy, groups...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="vxray.json"`)
+	w.Write(data)
+}
+
+// exportOutbounds 返回符合筛选条件的健康节点和置顶节点的出站、健康状态及负载均衡分组，不含被拉黑的节点
+func (s *Server) exportOutbounds(filter *node.Filter) ([]conf.OutboundDetourConfig, []bool, []xray.OutboundGroup, error) {
+	filter.Healthy = true
+	nodes, err := s.activeNodes(filter)
+	if err != nil {
+		logger.Error("Failed to list nodes", "err", err.Error())
+		return nil, nil, nil, err
+	}
+
+	groups := make([]xray.OutboundGroup, len(s.balancers))
+	for i, b := range s.balancers {
+		groups[i].Name = b.name
+	}
+
+	var outbounds []conf.OutboundDetourConfig
+	var healthy []bool
+	for _, n := range nodes {
+		if n.Outbound == nil {
+			continue
+		}
+		// 导出格式以 Tag 作为节点名称
+		outbound := *n.Outbound
+		outbound.Tag = n.DisplayName()
+		outbounds = append(outbounds, outbound)
+		// 置顶节点始终参与自动选择
+		healthy = append(healthy, n.Healthy || n.Pinned)
+		for i, b := range s.balancers {
+			groups[i].Members = append(groups[i].Members, b.expr.Match(n))
+		}
+	}
+	return outbounds, healthy, groups, nil
+}
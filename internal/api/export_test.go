@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/pkg/xray"
+)
+
+const exportLinks = `ss://YWVzLTI1Ni1nY206cGFzcyt3b3JkLzE@1.2.3.4:8388#ss
+trojan://secret@example.com:443?security=tls&sni=example.com&type=ws&host=example.com&path=%2Fws#trojan
+vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?security=tls&sni=example.com&type=grpc&serviceName=grpc#vless`
+
+func exportNodes(t *testing.T) []*node.Node {
+	t.Helper()
+
+	xrayConfig, err := xray.ConvertShareLinksToXrayJson(exportLinks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"Tokyo", "Osaka", "Seoul"}
+	var nodes []*node.Node
+	for i := range xrayConfig.OutboundConfigs {
+		nodes = append(nodes, &node.Node{
+			ID:       names[i],
+			Name:     names[i],
+			Outbound: &xrayConfig.OutboundConfigs[i],
+		})
+	}
+	// Tokyo is healthy, Osaka is pinned, Seoul is neither
+	nodes[0].Healthy = true
+	nodes[1].Pinned = true
+	return nodes
+}
+
+func TestHandleClash(t *testing.T) {
+	s := NewServer(&config.Api{
+		Balancers: []*config.Balancer{{Name: "Trojan", Filter: "protocol == trojan"}},
+	})
+	nodes := exportNodes(t)
+	var filters []*node.Filter
+	s.activeNodes = func(filter *node.Filter) ([]*node.Node, error) {
+		filters = append(filters, filter)
+		return nodes, nil
+	}
+
+	w := httptest.NewRecorder()
+	s.handleClash(w, httptest.NewRequest(http.MethodGet, "/subscription/clash?country=jp", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/yaml") {
+		t.Errorf("content type = %s", got)
+	}
+	if len(filters) != 1 || !filters[0].Healthy || len(filters[0].Countries) != 1 || filters[0].Countries[0] != "JP" {
+		t.Errorf("filter = %+v", filters)
+	}
+
+	var clash xray.ClashYaml
+	if err := yaml.Unmarshal(w.Body.Bytes(), &clash); err != nil {
+		t.Fatal(err)
+	}
+	if len(clash.Proxies) != 3 || clash.Proxies[0].Name != "Tokyo" || clash.Proxies[1].Type != "trojan" {
+		t.Errorf("proxies = %+v", clash.Proxies)
+	}
+	groups := make(map[string][]string)
+	for _, group := range clash.ProxyGroups {
+		groups[group.Name] = group.Proxies
+	}
+	if got := strings.Join(groups[xray.ClashAutoGroup], ","); got != "Tokyo,Osaka" {
+		t.Errorf("auto group = %s, want healthy and pinned nodes", got)
+	}
+	if got := strings.Join(groups["Trojan"], ","); got != "Osaka" {
+		t.Errorf("balancer group = %s", got)
+	}
+
+	// the exported config imports back into the same outbounds
+	imported, err := xray.ConvertShareLinksToXrayJson(w.Body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags []string
+	for _, outbound := range imported.OutboundConfigs {
+		tags = append(tags, outbound.Tag)
+	}
+	if got := strings.Join(tags, ","); !strings.HasPrefix(got, "Tokyo,Osaka,Seoul") {
+		t.Errorf("imported tags = %s", got)
+	}
+}
+
+func TestHandleClashErrors(t *testing.T) {
+	s := NewServer(&config.Api{})
+	s.activeNodes = func(filter *node.Filter) ([]*node.Node, error) {
+		return nil, nil
+	}
+
+	w := httptest.NewRecorder()
+	s.handleClash(w, httptest.NewRequest(http.MethodGet, "/subscription/clash?asn=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid asn status = %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleClash(w, httptest.NewRequest(http.MethodGet, "/subscription/clash", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("no nodes status = %d", w.Code)
+	}
+}
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/pkg/counter"
+)
+
+// handleMetrics 以 Prometheus 文本格式导出指标
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", counter.ContentType)
+	if err := counter.WritePrometheus(w); err != nil {
+		logger.Error("Failed to write metrics", "err", err.Error())
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/internal/subscription"
+)
+
+// handleNodes 以 JSON 返回符合筛选条件的节点
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	filter, err := s.parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nodes, err := node.Select(filter)
+	if err != nil {
+		logger.Error("Failed to list nodes", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redactNodes(nodes)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(nodes); err != nil {
+		logger.Error("Failed to write nodes", "err", err.Error())
+	}
+}
+
+// handleDuplicates 返回不同域名指向同一服务端的节点分组
+func handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	nodes, err := node.List()
+	if err != nil {
+		logger.Error("Failed to list nodes", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redactNodes(nodes)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(node.Duplicates(nodes)); err != nil {
+		logger.Error("Failed to write nodes", "err", err.Error())
+	}
+}
+
+// redactNodes 就地脱敏节点的订阅 URL，节点是刚读出的副本，不影响存储
+func redactNodes(nodes []*node.Node) {
+	for _, n := range nodes {
+		n.Source = subscription.RedactURL(n.Source)
+	}
+}
+
+// parseFilter 解析查询参数，例如 ?country=JP,HK&city=Tokyo&asn=13335&healthy=true&relayed=true&fronted=true，
+// 以及配置中的命名导出 ?export=fast 和筛选表达式 ?filter=latency<500ms，所有条件需同时满足
+func (s *Server) parseFilter(query url.Values) (*node.Filter, error) {
+	filter := &node.Filter{
+		City: query.Get("city"),
+	}
+
+	for _, country := range splitQuery(query, "country") {
+		filter.Countries = append(filter.Countries, strings.ToUpper(country))
+	}
+	for _, text := range splitQuery(query, "asn") {
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(text), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asn: %s", text)
+		}
+		filter.ASNs = append(filter.ASNs, uint(asn))
+	}
+
+	var err error
+	if filter.Healthy, err = parseBoolQuery(query, "healthy"); err != nil {
+		return nil, err
+	}
+	if filter.Relayed, err = parseBoolQuery(query, "relayed"); err != nil {
+		return nil, err
+	}
+	if filter.Fronted, err = parseBoolQuery(query, "fronted"); err != nil {
+		return nil, err
+	}
+
+	if name := query.Get("export"); len(name) > 0 {
+		e, ok := s.exports[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown export: %s", name)
+		}
+		filter.Exprs = append(filter.Exprs, e)
+	}
+	if text := query.Get("filter"); len(text) > 0 {
+		e, err := node.ParseExpr(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		filter.Exprs = append(filter.Exprs, e)
+	}
+	return filter, nil
+}
+
+// splitQuery 支持重复参数和逗号分隔两种写法
+func splitQuery(query url.Values, key string) []string {
+	var values []string
+	for _, value := range query[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+func parseBoolQuery(query url.Values, key string) (bool, error) {
+	text := query.Get(key)
+	if len(text) == 0 {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", key, text)
+	}
+	return value, nil
+}
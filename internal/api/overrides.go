@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/override"
+)
+
+type overrideRequest struct {
+	Kind   override.Kind `json:"kind"`
+	Value  string        `json:"value"`
+	Reason string        `json:"reason"`
+	Tags   []string      `json:"tags"`
+}
+
+// handleOverrides 返回全部置顶、黑名单和标签
+func handleOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides, err := override.Load()
+	if err != nil {
+		logger.Error("Failed to load overrides", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, overrides)
+}
+
+// handlePin 置顶节点，请求体可选 {"reason": "..."}
+func handlePin(w http.ResponseWriter, r *http.Request) {
+	request, ok := readOverrideRequest(w, r)
+	if !ok {
+		return
+	}
+	respondOverride(w, override.PinNode(r.PathValue("id"), request.Reason))
+}
+
+func handleUnpin(w http.ResponseWriter, r *http.Request) {
+	respondOverride(w, override.UnpinNode(r.PathValue("id")))
+}
+
+// handleBlock 添加黑名单规则，请求体 {"kind": "cidr", "value": "203.0.113.0/24", "reason": "..."}
+func handleBlock(w http.ResponseWriter, r *http.Request) {
+	request, ok := readOverrideRequest(w, r)
+	if !ok {
+		return
+	}
+	rule, err := override.NewRule(request.Kind, request.Value, request.Reason)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	respondOverride(w, override.Block(rule))
+}
+
+// handleUnblock 删除黑名单规则，规则值可能含有 / 因此通过 ?kind=&value= 传入
+func handleUnblock(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if err := override.Unblock(override.Kind(query.Get("kind")), query.Get("value")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTags 覆盖节点或订阅的标签，请求体 {"tags": ["streaming"]}
+func handleTags(w http.ResponseWriter, r *http.Request) {
+	request, ok := readOverrideRequest(w, r)
+	if !ok {
+		return
+	}
+	var tags []string
+	if r.Method != http.MethodDelete {
+		tags = request.Tags
+	}
+	if err := override.SetTags(override.TagScope(r.PathValue("scope")), r.PathValue("id"), tags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readOverrideRequest 解析可选的 JSON 请求体，失败时已写入响应
+func readOverrideRequest(w http.ResponseWriter, r *http.Request) (*overrideRequest, bool) {
+	request := &overrideRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return request, true
+}
+
+func respondOverride(w http.ResponseWriter, err error) {
+	if err != nil {
+		logger.Error("Failed to save override", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJson(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error("Failed to write response", "err", err.Error())
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/pkg/expr"
+)
+
+type Server struct {
+	server *http.Server
+	// 配置中的命名导出和负载均衡分组，表达式在启动时编译
+	exports   map[string]*expr.Expr
+	balancers []balancer
+	// 关闭时通知事件流结束，Shutdown 不会中断长连接
+	shutdown chan struct{}
+	// 导出的节点来源，可替换以便测试
+	activeNodes func(filter *node.Filter) ([]*node.Node, error)
+}
+
+type balancer struct {
+	name string
+	expr *expr.Expr
+}
+
+func NewServer(cfg *config.Api) *Server {
+	s := &Server{
+		exports:     make(map[string]*expr.Expr),
+		shutdown:    make(chan struct{}),
+		activeNodes: node.Active,
+	}
+	for _, export := range cfg.Exports {
+		filter, err := node.ParseExpr(export.Filter)
+		if err != nil {
+			logger.Error("Invalid export filter, skipped", "export", export.Name, "err", err.Error())
+			continue
+		}
+		s.exports[export.Name] = filter
+	}
+	for _, b := range cfg.Balancers {
+		filter, err := node.ParseExpr(b.Filter)
+		if err != nil {
+			logger.Error("Invalid balancer filter, skipped", "balancer", b.Name, "err", err.Error())
+			continue
+		}
+		s.balancers = append(s.balancers, balancer{name: b.Name, expr: filter})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscription/clash", s.handleClash)
+	mux.HandleFunc("GET /subscription/sing-box", s.handleSingBox)
+	mux.HandleFunc("GET /nodes", s.handleNodes)
+	mux.HandleFunc("GET /nodes/duplicates", handleDuplicates)
+	mux.HandleFunc("GET /subscriptions", handleSubscriptions)
+	mux.HandleFunc("GET /subscriptions/bandit", handleBandit)
+	mux.HandleFunc("GET /subscriptions/{name}/quota", handleQuotaHistory)
+	mux.HandleFunc("GET /subscriptions/{name}/revisions", handleRevisions)
+	mux.HandleFunc("GET /events", s.handleEvents)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+	mux.HandleFunc("GET /overrides", handleOverrides)
+	mux.HandleFunc("PUT /overrides/pins/{id}", handlePin)
+	mux.HandleFunc("DELETE /overrides/pins/{id}", handleUnpin)
+	mux.HandleFunc("POST /overrides/blacklist", handleBlock)
+	mux.HandleFunc("DELETE /overrides/blacklist", handleUnblock)
+	mux.HandleFunc("PUT /overrides/tags/{scope}/{id}", handleTags)
+	mux.HandleFunc("DELETE /overrides/tags/{scope}/{id}", handleTags)
+
+	s.server = &http.Server{
+		Addr:    cfg.Listen,
+		Handler: mux,
+	}
+	s.server.RegisterOnShutdown(func() { close(s.shutdown) })
+	return s
+}
+
+func (s *Server) Run() error {
+	logger.Info("API server listening", "addr", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		logger.Error("Failed to shutdown API server", "err", err.Error())
+	}
+}
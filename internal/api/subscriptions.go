@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/metrics"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/internal/subscription"
+)
+
+// subscriptionView 是订阅的公开信息，不包含认证信息和 URL 中的 token
+type subscriptionView struct {
+	Name    string              `json:"name"`
+	Url     string              `json:"url"`
+	Enabled bool                `json:"enabled"`
+	Fetch   []string            `json:"fetch,omitempty"`
+	Quota   *subscription.Quota `json:"quota,omitempty"`
+	// 当前的用量和到期告警
+	Warnings []subscription.Warning `json:"warnings,omitempty"`
+	// 最近一小时的拉取和解析失败次数
+	Errors *metrics.Errors `json:"errors_last_hour"`
+}
+
+// handleSubscriptions 返回配置的订阅及其最近一次的流量、到期信息、告警和最近的失败次数
+func handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	var views []subscriptionView
+	for _, sub := range config.GetSubscriptions() {
+		quota, err := subscription.GetQuota(subscription.QuotaKey(sub))
+		if err != nil {
+			logger.Error("Failed to get subscription quota", "subscription", sub.Name, "err", err.Error())
+		}
+		view := subscriptionView{
+			Name:    sub.Name,
+			Url:     subscription.RedactURL(sub.Url),
+			Enabled: sub.Enabled,
+			Fetch:   sub.Fetch,
+			Errors:  metrics.SubscriptionErrors(subscription.MetricName(sub), time.Hour),
+		}
+		if quota != nil {
+			view.Warnings = quota.Warnings(now)
+			// 未命名订阅的配额以 URL 为键
+			redacted := *quota
+			redacted.Subscription = subscription.RedactName(quota.Subscription)
+			view.Quota = &redacted
+		}
+		views = append(views, view)
+	}
+	writeJson(w, views)
+}
+
+// handleQuotaHistory 返回订阅的配额历史，按时间从旧到新
+func handleQuotaHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := subscription.GetQuotaHistory(r.PathValue("name"))
+	if err != nil {
+		logger.Error("Failed to get subscription quota history", "subscription", r.PathValue("name"), "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, history)
+}
+
+// handleRevisions 返回同名 git 订阅最近提交中的节点演变，从新到旧
+func handleRevisions(w http.ResponseWriter, r *http.Request) {
+	var histories []*node.SourceHistory
+	for _, sub := range config.GetSubscriptions() {
+		if sub.Name != r.PathValue("name") || subscription.SourceType(sub.Url) != subscription.SourceGit {
+			continue
+		}
+		history, err := node.GetSourceHistory(sub.Url)
+		if err != nil {
+			logger.Error("Failed to get git subscription history", "url", sub.Url, "err", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if history != nil {
+			history.Subscription = subscription.RedactURL(history.Subscription)
+			histories = append(histories, history)
+		}
+	}
+	writeJson(w, histories)
+}
+
+// handleBandit 返回订阅选择的后验：均值越高越常被选中，区间越宽越需要探索
+func handleBandit(w http.ResponseWriter, r *http.Request) {
+	posteriors, err := subscription.Posteriors()
+	if err != nil {
+		logger.Error("Failed to get subscription posteriors", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, posterior := range posteriors {
+		arm := *posterior.Arm
+		arm.Subscription = subscription.RedactURL(arm.Subscription)
+		posterior.Arm = &arm
+	}
+	writeJson(w, posteriors)
+}
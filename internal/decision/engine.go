@@ -1,6 +1,10 @@
 package decision
 
 import (
+	"slices"
+
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/override"
 	"zhouxin.learn/go/vxrayui/internal/types"
 )
 
@@ -17,7 +21,16 @@ func NewEngine(strategies []Strategy) *Engine {
 	return &Engine{strategies: strategies}
 }
 
+// Decide 返回得分最高的配置，来源被拉黑的配置不参与
 func (e *Engine) Decide(configs []*types.ConfigMetadata) *types.ConfigMetadata {
+	blacklist, err := override.LoadBlacklist()
+	if err != nil {
+		logger.Error("Failed to load blacklist", "err", err.Error())
+	}
+	configs = slices.DeleteFunc(slices.Clone(configs), func(cfg *types.ConfigMetadata) bool {
+		return blacklist.Subscription(cfg.ID, cfg.SourceURL) != nil
+	})
+
 	scores := make(map[string]float64)
 	for _, cfg := range configs {
 		for _, strat := range e.strategies {
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"time"
 
+	"zhouxin.learn/go/vxrayui/internal/node"
 	"zhouxin.learn/go/vxrayui/internal/types"
 )
 
@@ -31,3 +32,18 @@ func (s *SourcePriorityStrategy) Score(cfg *types.ConfigMetadata) float64 {
 func (s *SourcePriorityStrategy) Weight() float64 {
 	return 0.3
 }
+
+// StabilityStrategy 偏好节点在多次提交中持续存在的 git 订阅，其它来源按中等稳定性计
+type StabilityStrategy struct{}
+
+func (s *StabilityStrategy) Score(cfg *types.ConfigMetadata) float64 {
+	history, err := node.GetSourceHistory(cfg.SourceURL)
+	if err != nil || history == nil {
+		return 0.5
+	}
+	return history.Stability
+}
+
+func (s *StabilityStrategy) Weight() float64 {
+	return 0.3
+}
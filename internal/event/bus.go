@@ -0,0 +1,133 @@
+package event
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/internal/logger"
+)
+
+// 总线保留的最近事件数，供断线重连的流按 Last-Event-ID 补发
+const historySize = 256
+
+// Bus 是进程内的发布订阅总线。发布从不阻塞：订阅者的缓冲满时丢弃该订阅者的事件并计数，
+// 慢的 API 客户端不会拖慢测量和拉取
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+	history     []Event
+	nextID      uint64
+	now         func() time.Time
+}
+
+// Subscriber 是一个订阅，从 Events 读取符合类型的事件，不再需要时调用 Close
+type Subscriber struct {
+	bus     *Bus
+	types   []Type
+	ch      chan Event
+	dropped atomic.Int64
+	closed  bool
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscriber]struct{}),
+		now:         time.Now,
+	}
+}
+
+// Publish 发布一条事件并返回它
+func (b *Bus) Publish(t Type, data any) Event {
+	// 编号和投递在同一把锁内，每个订阅者收到的事件按 ID 递增
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e := Event{ID: b.nextID, Type: t, Time: b.now(), Data: data}
+	if len(b.history) == historySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, e)
+
+	for s := range b.subscribers {
+		if !t.Match(s.types...) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+			logger.Debug("Dropped event for slow subscriber", "type", string(t), "id", e.ID)
+		}
+	}
+	return e
+}
+
+// Subscribe 订阅 types 的事件，types 为空时订阅全部，buffer 是可积压的事件数
+func (b *Bus) Subscribe(buffer int, types ...Type) *Subscriber {
+	s := &Subscriber{bus: b, types: types, ch: make(chan Event, max(buffer, 1))}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// Since 返回 ID 大于 id 且符合 types 的保留事件
+func (b *Bus) Since(id uint64, types ...Type) []Event {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var events []Event
+	for _, e := range b.history {
+		if e.ID > id && e.Type.Match(types...) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Events 返回事件通道，Close 后通道关闭
+func (s *Subscriber) Events() <-chan Event {
+	return s.ch
+}
+
+// Types 返回订阅的事件类型
+func (s *Subscriber) Types() []Type {
+	return s.types
+}
+
+// Dropped 返回因缓冲已满丢弃的事件数
+func (s *Subscriber) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close 取消订阅并关闭事件通道，可重复调用
+func (s *Subscriber) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.bus.subscribers, s)
+	close(s.ch)
+}
+
+// 进程内的默认总线
+var bus = NewBus()
+
+// Publish 向默认总线发布事件
+func Publish(t Type, data any) Event {
+	return bus.Publish(t, data)
+}
+
+// Subscribe 订阅默认总线
+func Subscribe(buffer int, types ...Type) *Subscriber {
+	return bus.Subscribe(buffer, types...)
+}
+
+// Since 返回默认总线保留的事件
+func Since(id uint64, types ...Type) []Event {
+	return bus.Since(id, types...)
+}
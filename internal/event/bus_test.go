@@ -0,0 +1,132 @@
+package event
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTypeMatch(t *testing.T) {
+	tests := []struct {
+		t        Type
+		patterns []Type
+		want     bool
+	}{
+		{NodeDiscovered, nil, true},
+		{NodeDiscovered, []Type{NodeDiscovered}, true},
+		{NodeDiscovered, []Type{"node"}, true},
+		{NodeDiscovered, []Type{"nod"}, false},
+		{NodeDiscovered, []Type{"subscription", NodeRetired}, false},
+		{OutboundSwitched, []Type{"subscription", "outbound"}, true},
+	}
+	for _, test := range tests {
+		if got := test.t.Match(test.patterns...); got != test.want {
+			t.Errorf("%s.Match(%v) = %v, want %v", test.t, test.patterns, got, test.want)
+		}
+	}
+}
+
+func TestBusPublishSubscribe(t *testing.T) {
+	b := NewBus()
+	all := b.Subscribe(10)
+	nodes := b.Subscribe(10, "node")
+
+	b.Publish(NodeDiscovered, &Node{ID: "a"})
+	b.Publish(SubscriptionFetched, &Subscription{Name: "free"})
+	b.Publish(NodeRetired, &Node{ID: "a"})
+
+	if got := drain(all); len(got) != 3 || got[0].ID != 1 || got[2].ID != 3 {
+		t.Errorf("all = %v", got)
+	}
+	got := drain(nodes)
+	if len(got) != 2 || got[0].Type != NodeDiscovered || got[1].Type != NodeRetired {
+		t.Errorf("node events = %v", got)
+	}
+	if data, ok := got[0].Data.(*Node); !ok || data.ID != "a" {
+		t.Errorf("data = %#v", got[0].Data)
+	}
+
+	nodes.Close()
+	nodes.Close()
+	if _, ok := <-nodes.Events(); ok {
+		t.Error("closed subscriber still receives")
+	}
+	b.Publish(NodeUpdated, nil)
+	if got := drain(all); len(got) != 1 {
+		t.Errorf("after close all = %v", got)
+	}
+}
+
+func TestBusDropsForSlowSubscriber(t *testing.T) {
+	b := NewBus()
+	slow := b.Subscribe(2)
+	for range 5 {
+		b.Publish(MeasurementCompleted, nil)
+	}
+	if got := drain(slow); len(got) != 2 || got[1].ID != 2 {
+		t.Errorf("slow subscriber got %v", got)
+	}
+	if slow.Dropped() != 3 {
+		t.Errorf("dropped = %d, want 3", slow.Dropped())
+	}
+}
+
+func TestBusSince(t *testing.T) {
+	b := NewBus()
+	for range historySize + 10 {
+		b.Publish(NodeUpdated, nil)
+	}
+	b.Publish(OutboundSwitched, &Switch{To: "b"})
+
+	events := b.Since(0)
+	if len(events) != historySize || events[0].ID != 12 {
+		t.Fatalf("history = %d events from %d, want %d from 12", len(events), events[0].ID, historySize)
+	}
+	if events := b.Since(historySize+5, "outbound"); len(events) != 1 || events[0].Type != OutboundSwitched {
+		t.Errorf("since = %v", events)
+	}
+}
+
+func TestBusConcurrent(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(1000)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				b.Publish(MeasurementCompleted, nil)
+				b.Subscribe(1).Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every subscriber sees events in ID order
+	var last uint64
+	for _, e := range drain(s) {
+		if e.ID <= last {
+			t.Fatalf("event %d after %d", e.ID, last)
+		}
+		last = e.ID
+	}
+	if last != 500 {
+		t.Errorf("last id = %d, want 500", last)
+	}
+}
+
+// drain returns the events already queued for the subscriber
+func drain(s *Subscriber) []Event {
+	var events []Event
+	for {
+		select {
+		case e, ok := <-s.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package event
+
+import (
+	"strings"
+	"time"
+)
+
+// Type 是事件类型，形如 <类别>.<动作>
+type Type string
+
+const (
+	SubscriptionFetched Type = "subscription.fetched"
+	SubscriptionChanged Type = "subscription.changed"
+	SubscriptionFailed  Type = "subscription.failed"
+	// 订阅流量或到期新越过告警阈值
+	SubscriptionQuota Type = "subscription.quota"
+
+	NodeDiscovered Type = "node.discovered"
+	NodeUpdated    Type = "node.updated"
+	NodeRetired    Type = "node.retired"
+
+	MeasurementCompleted Type = "measurement.completed"
+
+	OutboundSwitched Type = "outbound.switched"
+)
+
+// Match 判断事件类型是否符合 patterns 之一，pattern 可以是完整类型或类别（如 node），
+// 没有 pattern 时全部符合
+func (t Type) Match(patterns ...Type) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if t == pattern || strings.HasPrefix(string(t), string(pattern)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Event 是总线上的一条事件，ID 在进程内递增，Data 为下面的某种载荷
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+// Subscription 是订阅拉取事件的载荷，URL 可能带有凭据，对外展示前需脱敏
+type Subscription struct {
+	// 由原始 URL 得出的标识，URL 脱敏后仅 token 不同的订阅以此区分
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Hash  string `json:"hash,omitempty"`
+	Nodes int    `json:"nodes,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Quota 是订阅配额告警的载荷
+type Quota struct {
+	Subscription string    `json:"subscription"`
+	Kind         string    `json:"kind"`
+	Threshold    float64   `json:"threshold,omitempty"`
+	Used         int64     `json:"used"`
+	Total        int64     `json:"total"`
+	Expire       time.Time `json:"expire,omitempty"`
+	Message      string    `json:"message"`
+}
+
+// Node 是节点事件的载荷，Previous 为状态变化前的状态
+type Node struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Subscription string `json:"subscription"`
+	State        string `json:"state"`
+	Previous     string `json:"previous,omitempty"`
+}
+
+// Measurement 是一次节点测量的结果
+type Measurement struct {
+	Node
+	Healthy bool          `json:"healthy"`
+	Latency time.Duration `json:"latency,omitempty"`
+}
+
+// Switch 是当前出站（延迟最低的健康节点）的变化，没有健康节点时 To 为空
+type Switch struct {
+	From    string        `json:"from,omitempty"`
+	To      string        `json:"to,omitempty"`
+	Name    string        `json:"name,omitempty"`
+	Latency time.Duration `json:"latency,omitempty"`
+}
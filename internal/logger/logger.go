@@ -11,7 +11,8 @@ import (
 )
 
 var (
-	logger   *slog.Logger
+	// Init 之前（例如测试中）使用默认 logger
+	logger   = slog.Default()
 	initOnce sync.Once
 )
 
@@ -19,6 +20,10 @@ func Info(msg string, args ...any) {
 	logger.Info(msg, args...)
 }
 
+func Warn(msg string, args ...any) {
+	logger.Warn(msg, args...)
+}
+
 func Error(msg string, args ...any) {
 	logger.Error(msg, args...)
 }
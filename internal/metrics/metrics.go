@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/internal/storage"
+	"zhouxin.learn/go/vxrayui/pkg/counter"
+)
+
+// StorageKeySnapshot 保存计数器快照，重启后恢复
+const StorageKeySnapshot = "metrics.snapshot"
+
+// 保存快照的间隔
+const snapshotInterval = 5 * time.Minute
+
+// 导出的指标名
+const (
+	SubscriptionFetches     = "vxray_subscription_fetches_total"
+	SubscriptionParseErrors = "vxray_subscription_parse_errors_total"
+	Probes                  = "vxray_probes_total"
+	ProbeLatency            = "vxray_probe_latency_seconds"
+	HealthyNodes            = "vxray_healthy_nodes"
+	ActiveLatency           = "vxray_active_node_latency_seconds"
+	EventsDropped           = "vxray_metrics_events_dropped_total"
+)
+
+// 测量延迟的桶，测量超过 1 秒即判为不可用
+var probeBuckets = []float64{0.05, 0.1, 0.2, 0.3, 0.5, 0.75, 1, 2, 3}
+
+// 收集器可积压的事件数，积压时丢弃并计入 EventsDropped
+const eventBuffer = 1024
+
+var initOnce sync.Once
+
+// Init 声明指标、注册按需计算的节点指标并恢复上次保存的计数，Run 之前调用
+func Init() {
+	initOnce.Do(func() {
+		counter.Describe(SubscriptionFetches, counter.KindCounter, "Subscription fetches by subscription and result.")
+		counter.Describe(SubscriptionParseErrors, counter.KindCounter, "Subscription lines or documents that failed to parse, by subscription and reason.")
+		counter.Describe(Probes, counter.KindCounter, "Node measurements by outcome.")
+		counter.Histogram(ProbeLatency, "Latency of successful node measurements.", probeBuckets)
+		counter.Describe(EventsDropped, counter.KindCounter, "Events the metrics collector dropped because it fell behind.")
+		counter.Describe(ActiveLatency, counter.KindGauge, "Latency of the active outbound, 0 when there is none.")
+		counter.Collect(HealthyNodes, "Healthy nodes by subscription, protocol and country.", healthyNodes)
+
+		snapshot, err := storage.Get[*counter.Snapshot](StorageKeySnapshot)
+		if err != nil {
+			logger.Error("Failed to load metrics snapshot", "err", err.Error())
+			return
+		}
+		counter.Default().Restore(snapshot)
+	})
+}
+
+// save 保存计数器快照
+func save() {
+	if err := storage.Set(StorageKeySnapshot, counter.Default().Snapshot()); err != nil {
+		logger.Error("Failed to save metrics snapshot", "err", err.Error())
+	}
+}
+
+// healthyNodes 在导出时统计健康节点
+func healthyNodes() []counter.Sample {
+	nodes, err := node.Select(&node.Filter{Healthy: true})
+	if err != nil {
+		logger.Error("Failed to list nodes for metrics", "err", err.Error())
+		return nil
+	}
+	counts := make(map[[3]string]int)
+	for _, n := range nodes {
+		var protocol string
+		if n.Outbound != nil {
+			protocol = n.Outbound.Protocol
+		}
+		counts[[3]string{n.Subscription, protocol, n.CountryCode()}]++
+	}
+	samples := make([]counter.Sample, 0, len(counts))
+	for key, count := range counts {
+		samples = append(samples, counter.Sample{
+			Labels: counter.Labels{"subscription": key[0], "protocol": key[1], "country": key[2]},
+			Value:  float64(count),
+		})
+	}
+	return samples
+}
+
+// Collector 订阅事件总线，把拉取、测量和出站切换记入指标
+type Collector struct {
+	// 当前出站，用于在它被复测时更新延迟
+	active string
+	// 已计入 EventsDropped 的丢弃数
+	dropped  int64
+	mu       sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (c *Collector) Run() {
+	// 与 Stop 互斥，Stop 之后调用的 Run 直接返回，Wait 不会漏掉之后才开始的 Run
+	c.mu.Lock()
+	select {
+	case <-c.stopChan:
+		c.mu.Unlock()
+		return
+	default:
+	}
+	c.wg.Add(1)
+	c.mu.Unlock()
+	defer c.wg.Done()
+
+	subscriber := event.Subscribe(eventBuffer, "subscription", event.MeasurementCompleted, event.OutboundSwitched)
+	defer subscriber.Close()
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-subscriber.Events():
+			c.countDropped(subscriber.Dropped())
+			c.record(e)
+		case <-ticker.C:
+			c.countDropped(subscriber.Dropped())
+			save()
+		case <-c.stopChan:
+			c.countDropped(subscriber.Dropped())
+			save()
+			return
+		}
+	}
+}
+
+func (c *Collector) Stop() {
+	c.mu.Lock()
+	close(c.stopChan)
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+// countDropped 把订阅者新丢弃的事件计入 EventsDropped，dropped 是订阅者累计的丢弃数
+func (c *Collector) countDropped(dropped int64) {
+	if delta := dropped - c.dropped; delta > 0 {
+		counter.Add(EventsDropped, delta, nil)
+		c.dropped = dropped
+	}
+}
+
+func (c *Collector) record(e event.Event) {
+	switch data := e.Data.(type) {
+	case *event.Subscription:
+		switch e.Type {
+		case event.SubscriptionFetched:
+			counter.Add(SubscriptionFetches, 1, counter.Labels{"subscription": subscriptionName(data), "result": "success"})
+		case event.SubscriptionFailed:
+			counter.Add(SubscriptionFetches, 1, counter.Labels{"subscription": subscriptionName(data), "result": "failure"})
+		}
+	case *event.Measurement:
+		outcome := "failure"
+		if data.Healthy {
+			outcome = "success"
+			counter.Observe(ProbeLatency, data.Latency.Seconds(), nil)
+		}
+		counter.Add(Probes, 1, counter.Labels{"outcome": outcome})
+		if data.ID == c.active {
+			counter.Set(ActiveLatency, data.Latency.Seconds(), nil)
+		}
+	case *event.Switch:
+		c.active = data.To
+		counter.Set(ActiveLatency, data.Latency.Seconds(), nil)
+	}
+}
+
+// subscriptionName 返回订阅在指标中的名称，未命名的订阅使用事件中已脱敏的 URL，与 subscription.MetricName 一致
+func subscriptionName(data *event.Subscription) string {
+	if len(data.Name) > 0 {
+		return data.Name
+	}
+	return data.URL
+}
+
+// Errors 是订阅最近一段时间的拉取失败和解析失败次数
+type Errors struct {
+	Fetch int64 `json:"fetch"`
+	Parse int64 `json:"parse"`
+}
+
+// SubscriptionErrors 返回订阅在最近 d 内的失败次数，name 为 subscription.MetricName，d 最长为 counter.MaxWindow
+func SubscriptionErrors(name string, d time.Duration) *Errors {
+	return &Errors{
+		Fetch: counter.Window(SubscriptionFetches, counter.Labels{"subscription": name, "result": "failure"}, d),
+		Parse: counter.Window(SubscriptionParseErrors, counter.Labels{"subscription": name}, d),
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/pkg/counter"
+)
+
+func TestCollectorRecord(t *testing.T) {
+	counter.Reset()
+	t.Cleanup(counter.Reset)
+
+	c := NewCollector()
+	c.record(event.Event{Type: event.SubscriptionFetched, Data: &event.Subscription{Name: "a"}})
+	c.record(event.Event{Type: event.SubscriptionChanged, Data: &event.Subscription{Name: "a"}})
+	c.record(event.Event{Type: event.SubscriptionFailed, Data: &event.Subscription{Name: "b"}})
+	c.record(event.Event{Type: event.OutboundSwitched, Data: &event.Switch{To: "n1", Latency: 200 * time.Millisecond}})
+	c.record(event.Event{Type: event.MeasurementCompleted, Data: &event.Measurement{Node: event.Node{ID: "n1"}, Healthy: true, Latency: 100 * time.Millisecond}})
+	c.record(event.Event{Type: event.MeasurementCompleted, Data: &event.Measurement{Node: event.Node{ID: "n2"}}})
+
+	if got := counter.Value(SubscriptionFetches, counter.Labels{"subscription": "a", "result": "success"}); got != 1 {
+		t.Errorf("successful fetches = %d, want 1", got)
+	}
+	if got := SubscriptionErrors("b", time.Hour).Fetch; got != 1 {
+		t.Errorf("failed fetches = %d, want 1", got)
+	}
+	if got := SubscriptionErrors("a", time.Hour).Fetch; got != 0 {
+		t.Errorf("failed fetches of a = %d, want 0", got)
+	}
+	if got := counter.Value(Probes, counter.Labels{"outcome": "success"}); got != 1 {
+		t.Errorf("successful probes = %d, want 1", got)
+	}
+	if got := counter.Value(Probes, counter.Labels{"outcome": "failure"}); got != 1 {
+		t.Errorf("failed probes = %d, want 1", got)
+	}
+	c.record(event.Event{Type: event.SubscriptionFailed, Data: &event.Subscription{URL: "https://example.com/sub"}})
+	if got := SubscriptionErrors("https://example.com/sub", time.Hour).Fetch; got != 1 {
+		t.Errorf("failed fetches of the unnamed subscription = %d, want 1", got)
+	}
+	if c.active != "n1" {
+		t.Errorf("active = %q, want n1", c.active)
+	}
+}
+
+func TestCollectorCountDropped(t *testing.T) {
+	counter.Reset()
+	t.Cleanup(counter.Reset)
+
+	c := NewCollector()
+	c.countDropped(3)
+	c.countDropped(3)
+	c.countDropped(5)
+	if got := counter.Value(EventsDropped, nil); got != 5 {
+		t.Errorf("dropped events = %d, want 5", got)
+	}
+}
@@ -0,0 +1,113 @@
+package node
+
+import (
+	"time"
+
+	"zhouxin.learn/go/vxrayui/pkg/expr"
+	"zhouxin.learn/go/vxrayui/pkg/xray"
+)
+
+// Schema 是筛选表达式可用的节点属性
+var Schema = expr.Schema{
+	"protocol":     expr.String,
+	"transport":    expr.String,
+	"security":     expr.String,
+	"fingerprint":  expr.String,
+	"country":      expr.String,
+	"city":         expr.String,
+	"asn":          expr.Number,
+	"cdn":          expr.String,
+	"subscription": expr.String,
+	"name":         expr.String,
+	"latency":      expr.Duration,
+	"success_rate": expr.Number,
+	"persistence":  expr.Number,
+	"age":          expr.Duration,
+	"tags":         expr.List,
+	"state":        expr.String,
+	"healthy":      expr.Bool,
+	"udp":          expr.Bool,
+	"relayed":      expr.Bool,
+	"fronted":      expr.Bool,
+	"pinned":       expr.Bool,
+	"blacklisted":  expr.Bool,
+}
+
+// ParseExpr 按节点属性编译筛选表达式，例如
+// protocol in (vless, trojan) and country != CN and latency < 500ms and udp
+func ParseExpr(text string) (*expr.Expr, error) {
+	return expr.Parse(text, Schema)
+}
+
+// Attribute 实现 expr.Attributes，未测量的延迟、成功率，缺失的地理信息和非 git 订阅的节点出现比例视为未知
+func (node *Node) Attribute(name string) (any, bool) {
+	switch name {
+	case "country":
+		country := node.CountryCode()
+		return country, len(country) > 0
+	case "city":
+		if location := node.Location(); location != nil && len(location.City) > 0 {
+			return location.City, true
+		}
+		return nil, false
+	case "asn":
+		if location := node.Location(); location != nil && location.ASN > 0 {
+			return float64(location.ASN), true
+		}
+		return nil, false
+	case "cdn":
+		return node.CDN(), true
+	case "subscription":
+		return node.Subscription, true
+	case "name":
+		return node.Name, true
+	case "latency":
+		return node.Latency, node.Latency > 0
+	case "success_rate":
+		if node.Checks == 0 {
+			return nil, false
+		}
+		return float64(node.Successes) / float64(node.Checks), true
+	case "persistence":
+		if node.Persistence == nil {
+			return nil, false
+		}
+		return node.Persistence.Score(), true
+	case "age":
+		return time.Since(node.FirstSeen), !node.FirstSeen.IsZero()
+	case "tags":
+		return node.Tags, true
+	case "state":
+		return string(node.State), len(node.State) > 0
+	case "healthy":
+		return node.Healthy, true
+	case "relayed":
+		return node.Relayed(), true
+	case "fronted":
+		return len(node.CDN()) > 0, true
+	case "pinned":
+		return node.Pinned, true
+	case "blacklisted":
+		return node.Blocked != nil, true
+	}
+
+	if node.Outbound == nil {
+		return nil, false
+	}
+	switch name {
+	case "protocol":
+		return node.Outbound.Protocol, true
+	case "transport":
+		transport, err := xray.Transport(*node.Outbound)
+		return transport, err == nil
+	case "security":
+		security, _ := xray.Security(*node.Outbound)
+		return security, true
+	case "fingerprint":
+		_, fingerprint := xray.Security(*node.Outbound)
+		return fingerprint, len(fingerprint) > 0
+	case "udp":
+		return xray.SupportsUDP(*node.Outbound), true
+	}
+	return nil, false
+}
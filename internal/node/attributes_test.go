@@ -0,0 +1,96 @@
+package node
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"zhouxin.learn/go/vxrayui/pkg/geo"
+)
+
+func TestParseExprMatchNode(t *testing.T) {
+	settings := json.RawMessage(`{"vnext":[{"address":"jp.example.com","port":443,"users":[{"id":"27848739-7e62-4138-9fd3-098a63964b6b","encryption":"none"}]}]}`)
+	network := conf.TransportProtocol("ws")
+	node := &Node{
+		ID:           "a",
+		Subscription: "free",
+		Name:         "Tokyo 01",
+		Outbound: &conf.OutboundDetourConfig{
+			Protocol: "vless",
+			Settings: &settings,
+			StreamSetting: &conf.StreamConfig{
+				Network:     &network,
+				Security:    "tls",
+				TLSSettings: &conf.TLSConfig{Fingerprint: "chrome"},
+			},
+		},
+		Exit:        &geo.Info{Country: "JP", ASN: 2516},
+		Healthy:     true,
+		Latency:     320 * time.Millisecond,
+		Checks:      10,
+		Successes:   9,
+		Tags:        []string{"streaming"},
+		Persistence: &Persistence{Revisions: 8, Commits: 10},
+		FirstSeen:   time.Now().Add(-48 * time.Hour),
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"protocol in (vless, trojan) and security == tls and country != CN and latency < 500ms and udp", true},
+		{"transport == ws and fingerprint == chrome", true},
+		{"subscription == free and asn == 2516 and tags == streaming", true},
+		{"success_rate >= 90% and age > 1d and healthy", true},
+		{"success_rate > 95%", false},
+		{"fronted or relayed", false},
+		{"city == Tokyo", false},
+		{"persistence >= 80%", true},
+	}
+	for _, test := range tests {
+		e, err := ParseExpr(test.filter)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): %v", test.filter, err)
+		}
+		if got := e.Match(node); got != test.want {
+			t.Errorf("Match(%q) = %v, want %v", test.filter, got, test.want)
+		}
+	}
+}
+
+func TestUDPAttribute(t *testing.T) {
+	outbound := func(protocol, settings, network string) *conf.OutboundDetourConfig {
+		raw := json.RawMessage(settings)
+		outbound := &conf.OutboundDetourConfig{Protocol: protocol, Settings: &raw}
+		if len(network) > 0 {
+			transport := conf.TransportProtocol(network)
+			outbound.StreamSetting = &conf.StreamConfig{Network: &transport}
+		}
+		return outbound
+	}
+	ss := `{"servers":[{"address":"1.2.3.4","port":8388,"method":"aes-128-gcm","password":"p"}]}`
+	ssUoT := `{"servers":[{"address":"1.2.3.4","port":8388,"method":"aes-128-gcm","password":"p","uot":true,"uotVersion":2}]}`
+	vless := `{"vnext":[{"address":"1.2.3.4","port":443,"users":[{"id":"27848739-7e62-4138-9fd3-098a63964b6b","encryption":"none"}]}]}`
+
+	tests := []struct {
+		name     string
+		outbound *conf.OutboundDetourConfig
+		want     bool
+	}{
+		{"shadowsocks", outbound("shadowsocks", ss, ""), true},
+		{"shadowsocks raw", outbound("shadowsocks", ss, "raw"), true},
+		// native UDP does not go through the websocket
+		{"shadowsocks ws", outbound("shadowsocks", ss, "ws"), false},
+		{"shadowsocks ws uot", outbound("shadowsocks", ssUoT, "ws"), true},
+		{"vless ws", outbound("vless", vless, "ws"), true},
+		{"http", outbound("http", `{"servers":[{"address":"1.2.3.4","port":8080}]}`, ""), false},
+	}
+	for _, test := range tests {
+		node := &Node{Outbound: test.outbound}
+		got, ok := node.Attribute("udp")
+		if !ok || got != test.want {
+			t.Errorf("%s: udp = %v, %v, want %v", test.name, got, ok, test.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package node
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/pkg/dns"
+	"zhouxin.learn/go/vxrayui/pkg/xray"
+)
+
+const (
+	// 解析器不提供 TTL 时的固定时长
+	defaultPinTTL = 10 * time.Minute
+
+	resolveConcurrency = 16
+	resolveTimeout     = 5 * time.Second
+)
+
+var (
+	dnsInitOnce sync.Once
+	dnsEnabled  bool
+	resolver    dns.Resolver = dns.SystemResolver{}
+)
+
+// 常见 CDN 的公开地址段
+var cdnPrefixes = map[string][]netip.Prefix{
+	"cloudflare": mustParsePrefixes(
+		"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+		"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+		"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+		"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+		"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+		"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+	),
+	"fastly": mustParsePrefixes("151.101.0.0/16", "199.232.0.0/16", "2a04:4e40::/32"),
+}
+
+// 地址段不全的 CDN 通过入口 ASN 识别
+var cdnASNs = map[uint]string{
+	13335:  "cloudflare",
+	209242: "cloudflare",
+	54113:  "fastly",
+	20940:  "akamai",
+	16625:  "akamai",
+	199524: "gcore",
+	60068:  "cdn77",
+}
+
+// Resolution 是节点域名的解析结果，TTL 内固定使用
+type Resolution struct {
+	Host       string       `json:"host"`
+	Records    []dns.Record `json:"records"`
+	ResolvedAt time.Time    `json:"resolved_at"`
+}
+
+// InitDNS 根据配置选择解析器
+func InitDNS() {
+	dnsInitOnce.Do(func() {
+		cfg := config.GetDns()
+		if cfg == nil {
+			return
+		}
+		dnsEnabled = cfg.Enabled
+		if len(cfg.Server) > 0 {
+			resolver = dns.NewClient(cfg.Server)
+		}
+	})
+}
+
+// Expired 判断解析结果是否超过最小 TTL
+func (resolution *Resolution) Expired(now time.Time) bool {
+	var ttl time.Duration
+	for _, record := range resolution.Records {
+		recordTTL := time.Duration(record.TTL) * time.Second
+		if recordTTL > 0 && (ttl == 0 || recordTTL < ttl) {
+			ttl = recordTTL
+		}
+	}
+	if ttl == 0 {
+		ttl = defaultPinTTL
+	}
+	return now.Sub(resolution.ResolvedAt) >= ttl
+}
+
+// IPs 返回排序后的解析地址
+func (resolution *Resolution) IPs() []netip.Addr {
+	var ips []netip.Addr
+	for _, record := range resolution.Records {
+		if ip, err := netip.ParseAddr(record.IP); err == nil {
+			ips = append(ips, ip)
+		}
+	}
+	slices.SortFunc(ips, func(a, b netip.Addr) int { return a.Compare(b) })
+	return slices.Compact(ips)
+}
+
+// ResolveNodes 并发解析并固定节点域名，未启用时直接返回
+func ResolveNodes(nodes []*Node) {
+	if !dnsEnabled {
+		return
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, resolveConcurrency)
+	for _, n := range nodes {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(n *Node) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+			defer cancel()
+
+			previous := n.DNS
+			if err := Resolve(ctx, resolver, n, time.Now()); err != nil {
+				logger.Debug("Failed to resolve node", "id", n.ID, "err", err.Error())
+				return
+			}
+			if n.DNS == previous {
+				return
+			}
+			// 只写入解析结果，不覆盖同时进行的测量
+			if _, err := Modify(n.ID, func(stored *Node) { stored.DNS = n.DNS }); err != nil {
+				logger.Error("Failed to update node", "id", n.ID, "err", err.Error())
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+// Resolve 解析节点地址并固定结果，未过期的结果直接复用
+func Resolve(ctx context.Context, r dns.Resolver, node *Node, now time.Time) error {
+	host, _, err := xray.Endpoint(*node.Outbound)
+	if err != nil {
+		return err
+	}
+	if node.DNS != nil && node.DNS.Host == host && !node.DNS.Expired(now) {
+		return nil
+	}
+
+	records, err := r.Resolve(ctx, host)
+	if err != nil {
+		return err
+	}
+	node.DNS = &Resolution{
+		Host:       host,
+		Records:    records,
+		ResolvedAt: now,
+	}
+	return nil
+}
+
+// CDN 返回节点入口所属的 CDN，非 CDN 前置的节点返回空字符串
+func (node *Node) CDN() string {
+	if node.DNS != nil {
+		for _, ip := range node.DNS.IPs() {
+			for provider, prefixes := range cdnPrefixes {
+				if slices.ContainsFunc(prefixes, func(prefix netip.Prefix) bool { return prefix.Contains(ip) }) {
+					return provider
+				}
+			}
+		}
+	}
+	if node.Entry != nil {
+		return cdnASNs[node.Entry.ASN]
+	}
+	return ""
+}
+
+// EndpointKey 由解析后的 IP、端口和凭据组成，不同域名指向同一服务端的节点 key 相同。
+// 解析到多个 IP 时取最小的一个，未解析的节点使用原始地址。
+func (node *Node) EndpointKey() (string, error) {
+	host, port, err := xray.Endpoint(*node.Outbound)
+	if err != nil {
+		return "", err
+	}
+	credential, err := xray.Credential(*node.Outbound)
+	if err != nil {
+		return "", err
+	}
+
+	address := host
+	if node.DNS != nil && node.DNS.Host == host {
+		if ips := node.DNS.IPs(); len(ips) > 0 {
+			address = ips[0].String()
+		}
+	}
+	return strings.Join([]string{address, strconv.Itoa(int(port)), credential}, "|"), nil
+}
+
+// Duplicates 返回指向同一服务端的节点分组，只包含两个及以上节点的分组
+func Duplicates(nodes []*Node) [][]*Node {
+	groups := make(map[string][]*Node)
+	var keys []string
+	for _, node := range nodes {
+		if node.Outbound == nil {
+			continue
+		}
+		key, err := node.EndpointKey()
+		if err != nil {
+			logger.Debug("Failed to get node endpoint", "id", node.ID, "err", err.Error())
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], node)
+	}
+
+	var duplicates [][]*Node
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			duplicates = append(duplicates, groups[key])
+		}
+	}
+	return duplicates
+}
+
+func mustParsePrefixes(texts ...string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, text := range texts {
+		prefixes = append(prefixes, netip.MustParsePrefix(text))
+	}
+	return prefixes
+}
@@ -0,0 +1,94 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"zhouxin.learn/go/vxrayui/pkg/dns"
+)
+
+// stubResolver answers from a fixed table and counts the queries
+type stubResolver struct {
+	records map[string][]dns.Record
+	queries int
+}
+
+func (r *stubResolver) Resolve(ctx context.Context, host string) ([]dns.Record, error) {
+	r.queries++
+	return r.records[host], nil
+}
+
+func trojanNode(id, host, password string) *Node {
+	settings := json.RawMessage(`{"servers":[{"address":"` + host + `","port":443,"password":"` + password + `"}]}`)
+	return &Node{
+		ID:       id,
+		Outbound: &conf.OutboundDetourConfig{Protocol: "trojan", Settings: &settings},
+	}
+}
+
+func TestResolvePinsUntilTTL(t *testing.T) {
+	resolver := &stubResolver{records: map[string][]dns.Record{
+		"a.example.com": {{IP: "203.0.113.1", TTL: 60}, {IP: "203.0.113.2", TTL: 300}},
+	}}
+	node := trojanNode("a", "a.example.com", "secret")
+	now := time.Now()
+
+	for _, elapsed := range []time.Duration{0, 59 * time.Second, 60 * time.Second} {
+		if err := Resolve(context.Background(), resolver, node, now.Add(elapsed)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// pinned for the smallest ttl, then resolved again
+	if resolver.queries != 2 {
+		t.Errorf("queries = %d, want 2", resolver.queries)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	resolver := &stubResolver{records: map[string][]dns.Record{
+		"a.example.com": {{IP: "203.0.113.1"}},
+		"b.example.com": {{IP: "203.0.113.1"}},
+		"c.example.com": {{IP: "203.0.113.1"}},
+		"d.example.com": {{IP: "203.0.113.9"}},
+	}}
+	nodes := []*Node{
+		trojanNode("a", "a.example.com", "secret"),
+		trojanNode("b", "b.example.com", "secret"),
+		trojanNode("c", "c.example.com", "other"),
+		trojanNode("d", "d.example.com", "secret"),
+	}
+	for _, node := range nodes {
+		if err := Resolve(context.Background(), resolver, node, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	duplicates := Duplicates(nodes)
+	if len(duplicates) != 1 || len(duplicates[0]) != 2 || duplicates[0][0].ID != "a" || duplicates[0][1].ID != "b" {
+		t.Errorf("Duplicates = %v", duplicates)
+	}
+}
+
+func TestCDN(t *testing.T) {
+	resolver := &stubResolver{records: map[string][]dns.Record{
+		"www.speedtest.net":  {{IP: "104.17.147.22"}},
+		"origin.example.com": {{IP: "203.0.113.1"}},
+	}}
+	fronted := trojanNode("fronted", "www.speedtest.net", "secret")
+	origin := trojanNode("origin", "origin.example.com", "secret")
+	for _, node := range []*Node{fronted, origin} {
+		if err := Resolve(context.Background(), resolver, node, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cdn := fronted.CDN(); cdn != "cloudflare" {
+		t.Errorf("CDN(fronted) = %q", cdn)
+	}
+	if cdn := origin.CDN(); cdn != "" {
+		t.Errorf("CDN(origin) = %q", cdn)
+	}
+}
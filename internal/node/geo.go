@@ -0,0 +1,157 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/pkg/expr"
+	"zhouxin.learn/go/vxrayui/pkg/geo"
+	"zhouxin.learn/go/vxrayui/pkg/xray"
+)
+
+var (
+	geoInitOnce sync.Once
+	geoDatabase geo.Databases
+)
+
+// InitGeo 打开配置的离线地理数据库，未配置时节点只有名称中的国家
+func InitGeo() {
+	geoInitOnce.Do(func() {
+		cfg := config.GetGeo()
+		if cfg == nil || len(cfg.Databases) == 0 {
+			return
+		}
+		databases, err := geo.OpenAll(cfg.Databases)
+		if err != nil {
+			logger.Error("Failed to open geo databases", "err", err.Error())
+			return
+		}
+		geoDatabase = databases
+	})
+}
+
+// Locate 解析节点的入口 IP，并查询入口和出口 IP 的地理信息
+func Locate(ctx context.Context, node *Node) {
+	if node.Outbound == nil {
+		return
+	}
+
+	host, _, err := xray.Endpoint(*node.Outbound)
+	if err != nil {
+		logger.Debug("Failed to get node endpoint", "id", node.ID, "err", err.Error())
+		return
+	}
+	ip, err := entryIP(ctx, node, host)
+	if err != nil {
+		logger.Debug("Failed to resolve node endpoint", "id", node.ID, "host", host, "err", err.Error())
+	} else {
+		node.EntryIP = ip.String()
+		node.Entry = lookupGeo(ip)
+	}
+
+	if exitIP, err := netip.ParseAddr(node.ExitIP); err == nil {
+		node.Exit = lookupGeo(exitIP)
+	}
+}
+
+// entryIP 优先使用固定的解析结果
+func entryIP(ctx context.Context, node *Node, host string) (netip.Addr, error) {
+	if node.DNS != nil && node.DNS.Host == host {
+		if ips := node.DNS.IPs(); len(ips) > 0 {
+			return ips[0], nil
+		}
+	}
+
+	records, err := resolver.Resolve(ctx, host)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	for _, record := range records {
+		if ip, err := netip.ParseAddr(record.IP); err == nil {
+			return ip, nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("no address for %s", host)
+}
+
+func lookupGeo(ip netip.Addr) *geo.Info {
+	if len(geoDatabase) == 0 {
+		return nil
+	}
+	info, err := geoDatabase.Lookup(ip)
+	if err != nil {
+		logger.Debug("Failed to lookup geo", "ip", ip.String(), "err", err.Error())
+		return nil
+	}
+	return info
+}
+
+// Filter 按地理信息和筛选表达式筛选节点，零值字段不参与筛选
+type Filter struct {
+	Countries []string
+	City      string
+	ASNs      []uint
+	Healthy   bool
+	// 仅保留出口 IP 与入口 IP 不同（经过中转）的节点
+	Relayed bool
+	// 仅保留 CDN 前置的节点
+	Fronted bool
+	// 需全部匹配的筛选表达式，见 ParseExpr
+	Exprs []*expr.Expr
+}
+
+func (filter *Filter) Match(node *Node) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.Healthy && !node.Healthy {
+		return false
+	}
+	if filter.Relayed && !node.Relayed() {
+		return false
+	}
+	if filter.Fronted && len(node.CDN()) == 0 {
+		return false
+	}
+	if len(filter.Countries) > 0 && !slices.ContainsFunc(filter.Countries, func(country string) bool {
+		return strings.EqualFold(country, node.CountryCode())
+	}) {
+		return false
+	}
+
+	location := node.Location()
+	if len(filter.City) > 0 && (location == nil || !strings.EqualFold(filter.City, location.City)) {
+		return false
+	}
+	if len(filter.ASNs) > 0 && (location == nil || !slices.Contains(filter.ASNs, location.ASN)) {
+		return false
+	}
+	for _, e := range filter.Exprs {
+		if !e.Match(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select 返回符合筛选条件的节点
+func Select(filter *Filter) ([]*Node, error) {
+	nodes, err := List()
+	if err != nil {
+		return nil, err
+	}
+	return filter.Select(nodes), nil
+}
+
+// Select 就地筛选 nodes，返回符合条件的节点
+func (filter *Filter) Select(nodes []*Node) []*Node {
+	return slices.DeleteFunc(nodes, func(node *Node) bool {
+		return !filter.Match(node)
+	})
+}
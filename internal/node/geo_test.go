@@ -0,0 +1,72 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"zhouxin.learn/go/vxrayui/pkg/expr"
+	"zhouxin.learn/go/vxrayui/pkg/geo"
+)
+
+func filterNodes() []*Node {
+	return []*Node{
+		// direct, located by the entry
+		{ID: "tokyo", Country: "JP", Healthy: true, EntryIP: "203.0.113.1",
+			Entry: &geo.Info{Country: "JP", City: "Tokyo", ASN: 64500}},
+		// relayed, the exit wins over the entry and the name
+		{ID: "relay", Country: "HK", Healthy: true, EntryIP: "198.51.100.1", ExitIP: "203.0.113.17",
+			Entry: &geo.Info{Country: "HK", ASN: 13335}, Exit: &geo.Info{Country: "US", City: "Los Angeles", ASN: 64501}},
+		// same entry and exit IP
+		{ID: "osaka", Country: "JP", EntryIP: "203.0.113.2", ExitIP: "203.0.113.2",
+			Entry: &geo.Info{Country: "JP", City: "Osaka", ASN: 64500}},
+		// only the name is known
+		{ID: "named", Country: "DE", Healthy: true},
+	}
+}
+
+func mustParseExpr(t *testing.T, text string) *expr.Expr {
+	t.Helper()
+
+	e, err := ParseExpr(text)
+	if err != nil {
+		t.Fatalf("ParseExpr(%q): %v", text, err)
+	}
+	return e
+}
+
+func TestFilterSelect(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   []string
+	}{
+		{"nil", nil, []string{"tokyo", "relay", "osaka", "named"}},
+		{"zero", &Filter{}, []string{"tokyo", "relay", "osaka", "named"}},
+		{"country", &Filter{Countries: []string{"jp"}}, []string{"tokyo", "osaka"}},
+		{"country of exit", &Filter{Countries: []string{"US"}}, []string{"relay"}},
+		{"country of name", &Filter{Countries: []string{"DE", "HK"}}, []string{"named"}},
+		{"city", &Filter{City: "tokyo"}, []string{"tokyo"}},
+		{"city of exit", &Filter{City: "Los Angeles"}, []string{"relay"}},
+		{"asn", &Filter{ASNs: []uint{64500}}, []string{"tokyo", "osaka"}},
+		{"asn of exit", &Filter{ASNs: []uint{13335, 64501}}, []string{"relay"}},
+		{"healthy", &Filter{Healthy: true}, []string{"tokyo", "relay", "named"}},
+		{"relayed", &Filter{Relayed: true}, []string{"relay"}},
+		{"fronted", &Filter{Fronted: true}, []string{"relay"}},
+		{"combined", &Filter{Countries: []string{"JP"}, Healthy: true}, []string{"tokyo"}},
+		{"expr", &Filter{Exprs: []*expr.Expr{mustParseExpr(t, "country == JP")}}, []string{"tokyo", "osaka"}},
+		{"exprs", &Filter{Exprs: []*expr.Expr{mustParseExpr(t, "country == JP"), mustParseExpr(t, "city == Osaka")}}, []string{"osaka"}},
+		{"no match", &Filter{Countries: []string{"FR"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, node := range tt.filter.Select(filterNodes()) {
+				got = append(got, node.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
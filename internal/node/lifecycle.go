@@ -0,0 +1,238 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/storage"
+)
+
+// State 是节点的生命周期状态，由测量结果驱动：
+//
+//	new → testing → healthy ⇄ degraded → quarantined → retired
+//
+// 连续成功 HealthyAfter 次转为 healthy，healthy 失败一次转为 degraded，
+// 连续失败 QuarantineAfter 次隔离并按指数退避重测，连续失败 RetireAfter 次退役。
+type State string
+
+const (
+	StateNew         State = "new"
+	StateTesting     State = "testing"
+	StateHealthy     State = "healthy"
+	StateDegraded    State = "degraded"
+	StateQuarantined State = "quarantined"
+	StateRetired     State = "retired"
+)
+
+// Thresholds 是状态转换的阈值
+type Thresholds struct {
+	HealthyAfter     int
+	QuarantineAfter  int
+	RetireAfter      int
+	RetestBackoff    time.Duration
+	MaxRetestBackoff time.Duration
+	Retention        time.Duration
+	CompactInterval  time.Duration
+}
+
+var DefaultThresholds = Thresholds{
+	HealthyAfter:     2,
+	QuarantineAfter:  3,
+	RetireAfter:      8,
+	RetestBackoff:    10 * time.Minute,
+	MaxRetestBackoff: 24 * time.Hour,
+	Retention:        7 * 24 * time.Hour,
+	CompactInterval:  time.Hour,
+}
+
+var (
+	lifecycleInitOnce sync.Once
+	thresholds        = DefaultThresholds
+)
+
+// InitLifecycle 读取配置的阈值，未配置的字段使用默认值
+func InitLifecycle() {
+	lifecycleInitOnce.Do(func() {
+		cfg := config.GetLifecycle()
+		if cfg == nil {
+			return
+		}
+		if cfg.HealthyAfter > 0 {
+			thresholds.HealthyAfter = cfg.HealthyAfter
+		}
+		if cfg.QuarantineAfter > 0 {
+			thresholds.QuarantineAfter = cfg.QuarantineAfter
+		}
+		if cfg.RetireAfter > 0 {
+			thresholds.RetireAfter = cfg.RetireAfter
+		}
+		if cfg.RetestBackoff > 0 {
+			thresholds.RetestBackoff = cfg.RetestBackoff
+		}
+		if cfg.MaxRetestBackoff > 0 {
+			thresholds.MaxRetestBackoff = cfg.MaxRetestBackoff
+		}
+		if cfg.Retention > 0 {
+			thresholds.Retention = cfg.Retention
+		}
+		if cfg.CompactInterval > 0 {
+			thresholds.CompactInterval = cfg.CompactInterval
+		}
+	})
+}
+
+// Observe 记录一次测量结果并推进节点状态，状态变化时发布 node.updated 或 node.retired
+func (node *Node) Observe(success bool, now time.Time) {
+	previous := node.State
+	node.observe(success, now, thresholds)
+	switch {
+	case node.State == previous:
+	case node.State == StateRetired:
+		event.Publish(event.NodeRetired, node.Event(previous))
+	default:
+		event.Publish(event.NodeUpdated, node.Event(previous))
+	}
+}
+
+func (node *Node) observe(success bool, now time.Time, t Thresholds) {
+	if node.State == StateRetired {
+		return
+	}
+
+	if success {
+		node.SuccessStreak++
+		node.FailureStreak = 0
+		node.NextCheck = time.Time{}
+		switch {
+		case node.SuccessStreak >= t.HealthyAfter:
+			node.State = StateHealthy
+		case node.State != StateDegraded:
+			// 新节点和隔离后重测成功的节点需重新积累连续成功
+			node.State = StateTesting
+		}
+		return
+	}
+
+	node.FailureStreak++
+	node.SuccessStreak = 0
+	switch {
+	case node.FailureStreak >= t.RetireAfter:
+		node.State = StateRetired
+		node.RetiredAt = now
+		node.NextCheck = time.Time{}
+	case node.FailureStreak >= t.QuarantineAfter:
+		node.State = StateQuarantined
+		node.NextCheck = now.Add(retestBackoff(node.FailureStreak-t.QuarantineAfter, t))
+	case node.State == StateHealthy || node.State == StateDegraded:
+		node.State = StateDegraded
+	default:
+		node.State = StateTesting
+	}
+}
+
+// Verdict 判断一次状态转换是否为节点的首个结论：新节点或测试中的节点转为 healthy 为可用，
+// 转为隔离或退役为不可用，其它转换返回 settled 为 false
+func Verdict(from, to State) (healthy, settled bool) {
+	if from != StateNew && from != StateTesting {
+		return false, false
+	}
+	switch to {
+	case StateHealthy:
+		return true, true
+	case StateQuarantined, StateRetired:
+		return false, true
+	}
+	return false, false
+}
+
+// retestBackoff 是第 retests 次失败重测后的等待时长
+func retestBackoff(retests int, t Thresholds) time.Duration {
+	backoff := t.RetestBackoff
+	for i := 0; i < retests && backoff < t.MaxRetestBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, t.MaxRetestBackoff)
+}
+
+// Due 判断节点是否需要测量，退役节点和退避中的隔离节点不测量
+func (node *Node) Due(now time.Time) bool {
+	if node.State == StateRetired {
+		return false
+	}
+	return node.NextCheck.IsZero() || !now.Before(node.NextCheck)
+}
+
+// Compact 删除退役超过 retention 的节点，返回删除数量
+func Compact(now time.Time, retention time.Duration) (int, error) {
+	nodes, err := List()
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	for _, node := range nodes {
+		if node.State != StateRetired || now.Sub(node.RetiredAt) < retention {
+			continue
+		}
+		if err := storage.Delete(StorageKeyNode + node.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Compactor 定期清理退役节点，避免免费订阅让数据库无限增长
+type Compactor struct {
+	mu       sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewCompactor() *Compactor {
+	return &Compactor{
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (c *Compactor) Run() {
+	// 与 Stop 互斥，Stop 之后调用的 Run 直接返回，Wait 不会漏掉之后才开始的 Run
+	c.mu.Lock()
+	select {
+	case <-c.stopChan:
+		c.mu.Unlock()
+		return
+	default:
+	}
+	c.wg.Add(1)
+	c.mu.Unlock()
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(thresholds.CompactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			deleted, err := Compact(time.Now(), thresholds.Retention)
+			if err != nil {
+				logger.Error("Failed to compact retired nodes", "err", err.Error())
+			}
+			if deleted > 0 {
+				logger.Info("Compacted retired nodes", "deleted", deleted)
+			}
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+func (c *Compactor) Stop() {
+	c.mu.Lock()
+	close(c.stopChan)
+	c.mu.Unlock()
+	c.wg.Wait()
+}
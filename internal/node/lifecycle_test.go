@@ -0,0 +1,101 @@
+package node
+
+import (
+	"testing"
+	"time"
+)
+
+var testThresholds = Thresholds{
+	HealthyAfter:     2,
+	QuarantineAfter:  3,
+	RetireAfter:      5,
+	RetestBackoff:    10 * time.Minute,
+	MaxRetestBackoff: 30 * time.Minute,
+}
+
+func TestObserveTransitions(t *testing.T) {
+	now := time.Now()
+	node := &Node{State: StateNew}
+
+	steps := []struct {
+		success bool
+		want    State
+	}{
+		{true, StateTesting},
+		{true, StateHealthy},
+		{false, StateDegraded},
+		{true, StateDegraded},
+		{true, StateHealthy},
+		{false, StateDegraded},
+		{false, StateDegraded},
+		{false, StateQuarantined},
+		{true, StateTesting},
+		{false, StateTesting},
+		{false, StateTesting},
+		{false, StateQuarantined},
+		{false, StateQuarantined},
+		{false, StateRetired},
+		{true, StateRetired},
+	}
+	for i, step := range steps {
+		node.observe(step.success, now, testThresholds)
+		if node.State != step.want {
+			t.Fatalf("step %d: state = %s, want %s", i, node.State, step.want)
+		}
+	}
+	if !node.RetiredAt.Equal(now) {
+		t.Errorf("RetiredAt = %v, want %v", node.RetiredAt, now)
+	}
+}
+
+func TestQuarantineBackoff(t *testing.T) {
+	thresholds := testThresholds
+	thresholds.RetireAfter = 10
+
+	now := time.Now()
+	node := &Node{State: StateHealthy}
+	for range thresholds.QuarantineAfter - 1 {
+		node.observe(false, now, thresholds)
+	}
+
+	// doubled after every failed retest, capped at MaxRetestBackoff
+	for _, want := range []time.Duration{10 * time.Minute, 20 * time.Minute, 30 * time.Minute, 30 * time.Minute} {
+		node.observe(false, now, thresholds)
+		if node.State != StateQuarantined {
+			t.Fatalf("state = %s, want %s", node.State, StateQuarantined)
+		}
+		if got := node.NextCheck.Sub(now); got != want {
+			t.Errorf("backoff = %v, want %v", got, want)
+		}
+		if node.Due(now) || !node.Due(node.NextCheck) {
+			t.Errorf("node should be due at NextCheck only, backoff %v", want)
+		}
+		now = node.NextCheck
+	}
+
+	node.State = StateRetired
+	if node.Due(now) {
+		t.Error("retired node is due")
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	tests := []struct {
+		from, to         State
+		healthy, settled bool
+	}{
+		{StateNew, StateTesting, false, false},
+		{StateTesting, StateHealthy, true, true},
+		{StateTesting, StateQuarantined, false, true},
+		{StateNew, StateRetired, false, true},
+		{StateHealthy, StateDegraded, false, false},
+		{StateDegraded, StateQuarantined, false, false},
+		{StateQuarantined, StateHealthy, false, false},
+	}
+	for _, test := range tests {
+		healthy, settled := Verdict(test.from, test.to)
+		if healthy != test.healthy || settled != test.settled {
+			t.Errorf("Verdict(%s, %s) = %v, %v, want %v, %v", test.from, test.to, healthy, settled, test.healthy, test.settled)
+		}
+	}
+}
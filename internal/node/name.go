@@ -0,0 +1,156 @@
+package node
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	regionalIndicatorA = 0x1F1E6
+	regionalIndicatorZ = 0x1F1FF
+)
+
+// 订阅名称中常见的广告：链接、频道、测速信息
+var adPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)https?://\S+`),
+	regexp.MustCompile(`(?i)\bt\.me/\S+`),
+	regexp.MustCompile(`@[\w.]+`),
+	regexp.MustCompile(`(?i)\bping\s*[:：]?\s*[\d.]+\s*ms\b`),
+	regexp.MustCompile(`(?i)\b(telegram|channel|join)\b`),
+}
+
+var countryCodePattern = regexp.MustCompile(`\b[A-Z]{2}\b`)
+
+// 国家名称到 ISO 3166-1 代码，按顺序匹配，长名称在前
+var countryNames = []struct {
+	names []string
+	code  string
+}{
+	{[]string{"hong kong", "hongkong", "香港"}, "HK"},
+	{[]string{"taiwan", "台湾"}, "TW"},
+	{[]string{"macau", "macao", "澳门"}, "MO"},
+	{[]string{"japan", "tokyo", "osaka", "日本", "东京", "大阪"}, "JP"},
+	{[]string{"singapore", "新加坡", "狮城"}, "SG"},
+	{[]string{"south korea", "korea", "seoul", "韩国", "首尔"}, "KR"},
+	{[]string{"united states", "america", "usa", "美国"}, "US"},
+	{[]string{"united kingdom", "england", "london", "英国", "伦敦"}, "GB"},
+	{[]string{"germany", "frankfurt", "德国"}, "DE"},
+	{[]string{"france", "paris", "法国"}, "FR"},
+	{[]string{"netherlands", "amsterdam", "荷兰"}, "NL"},
+	{[]string{"canada", "toronto", "加拿大"}, "CA"},
+	{[]string{"australia", "sydney", "澳大利亚", "澳洲"}, "AU"},
+	{[]string{"russia", "moscow", "俄罗斯"}, "RU"},
+	{[]string{"turkey", "türkiye", "土耳其"}, "TR"},
+	{[]string{"india", "印度"}, "IN"},
+	{[]string{"iran", "伊朗"}, "IR"},
+	{[]string{"china", "中国"}, "CN"},
+}
+
+// 单独出现的两位大写代码只认这些，避免把 "VPN"、"OK" 之类误认成国家
+var countryCodes = map[string]string{
+	"HK": "HK", "TW": "TW", "MO": "MO", "JP": "JP", "SG": "SG", "KR": "KR",
+	"US": "US", "UK": "GB", "GB": "GB", "DE": "DE", "FR": "FR", "NL": "NL",
+	"CA": "CA", "AU": "AU", "RU": "RU", "TR": "TR", "IR": "IR", "CN": "CN",
+}
+
+// NormalizeName 清理订阅里的节点名称，去掉广告和 emoji，并提取国家代码
+func NormalizeName(raw string) (name string, country string) {
+	country = flagCountry(raw)
+
+	text := raw
+	for _, pattern := range adPatterns {
+		text = pattern.ReplaceAllString(text, " ")
+	}
+	text = strings.Map(func(r rune) rune {
+		if isNameJunk(r) {
+			return ' '
+		}
+		return r
+	}, text)
+	name = strings.Trim(strings.Join(strings.Fields(text), " "), " -_|:·")
+
+	if len(country) == 0 {
+		country = nameCountry(name)
+	}
+	return name, country
+}
+
+// CountryFlag 将国家代码转换为国旗 emoji
+func CountryFlag(country string) string {
+	if len(country) != 2 {
+		return ""
+	}
+	var flag []rune
+	for _, r := range strings.ToUpper(country) {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+		flag = append(flag, regionalIndicatorA+r-'A')
+	}
+	return string(flag)
+}
+
+// flagCountry 返回名称中第一个国旗对应的国家代码
+func flagCountry(text string) string {
+	runes := []rune(text)
+	for i := 0; i+1 < len(runes); i++ {
+		if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
+			return string([]rune{'A' + runes[i] - regionalIndicatorA, 'A' + runes[i+1] - regionalIndicatorA})
+		}
+	}
+	return ""
+}
+
+// 英文名称按整词匹配，避免 "Busan" 匹配到 "usa"
+var countryNamePatterns = func() []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, country := range countryNames {
+		var alternatives []string
+		for _, countryName := range country.names {
+			quoted := regexp.QuoteMeta(countryName)
+			if countryName[0] < utf8.RuneSelf {
+				quoted = `\b` + quoted + `\b`
+			}
+			alternatives = append(alternatives, quoted)
+		}
+		patterns = append(patterns, regexp.MustCompile(`(?i)`+strings.Join(alternatives, "|")))
+	}
+	return patterns
+}()
+
+func nameCountry(name string) string {
+	for i, pattern := range countryNamePatterns {
+		if pattern.MatchString(name) {
+			return countryNames[i].code
+		}
+	}
+
+	for _, code := range countryCodePattern.FindAllString(name, -1) {
+		if country, ok := countryCodes[code]; ok {
+			return country
+		}
+	}
+	return ""
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalIndicatorA && r <= regionalIndicatorZ
+}
+
+// isNameJunk 判断字符是否为 emoji 及其修饰符、控制字符或分隔用的符号
+func isNameJunk(r rune) bool {
+	switch {
+	case unicode.Is(unicode.So, r), unicode.Is(unicode.Sk, r):
+		return true
+	case unicode.Is(unicode.Cf, r), unicode.IsControl(r):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r == 0x20E3:
+		// variation selectors and the keycap
+		return true
+	case r == '`', r == '|', r == '｜':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package node
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		raw     string
+		name    string
+		country string
+	}{
+		{"👉🆔@v2ray_configs_pool📡🇨🇦®️Canada©️Toronto🅿️ping:15.62ms`", "Canada Toronto", "CA"},
+		{"🇭🇰 香港 01 | t.me/free_nodes", "香港 01", "HK"},
+		{"日本 东京 IPLC", "日本 东京 IPLC", "JP"},
+		{"US-West 1️⃣", "US-West 1", "US"},
+		{"Busan Relay", "Busan Relay", ""},
+		{"Join telegram https://example.com VPN", "VPN", ""},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		name, country := NormalizeName(test.raw)
+		if name != test.name || country != test.country {
+			t.Errorf("NormalizeName(%q) = %q, %q, want %q, %q", test.raw, name, country, test.name, test.country)
+		}
+	}
+}
+
+func TestCountryFlag(t *testing.T) {
+	if flag := CountryFlag("ca"); flag != "🇨🇦" {
+		t.Errorf("CountryFlag(ca) = %q", flag)
+	}
+	if flag := CountryFlag("C1"); flag != "" {
+		t.Errorf("CountryFlag(C1) = %q", flag)
+	}
+}
@@ -0,0 +1,213 @@
+package node
+
+import (
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/override"
+	"zhouxin.learn/go/vxrayui/internal/storage"
+	"zhouxin.learn/go/vxrayui/pkg/geo"
+	"zhouxin.learn/go/vxrayui/pkg/xray"
+)
+
+const StorageKeyNode = "node."
+
+// Node 是持久化的出站节点，ID 为出站指纹
+type Node struct {
+	ID            string                     `json:"id"`
+	Subscription  string                     `json:"subscription"`
+	Source        string                     `json:"source,omitempty"` // 订阅 Url，同名订阅以此区分
+	Name          string                     `json:"name"`
+	Country       string                     `json:"country"`
+	Outbound      *conf.OutboundDetourConfig `json:"outbound"`
+	DNS           *Resolution                `json:"dns,omitempty"`
+	EntryIP       string                     `json:"entry_ip,omitempty"`
+	Entry         *geo.Info                  `json:"entry,omitempty"`
+	ExitIP        string                     `json:"exit_ip,omitempty"`
+	Exit          *geo.Info                  `json:"exit,omitempty"`
+	Healthy       bool                       `json:"healthy"`
+	Latency       time.Duration              `json:"latency,omitempty"` // 最近一次测量的平均延迟，失败时为 0
+	Checks        int                        `json:"checks"`
+	Successes     int                        `json:"successes"`
+	State         State                      `json:"state"`
+	SuccessStreak int                        `json:"success_streak"`
+	FailureStreak int                        `json:"failure_streak"`
+	NextCheck     time.Time                  `json:"next_check"`
+	RetiredAt     time.Time                  `json:"retired_at"`
+	Tags          []string                   `json:"tags,omitempty"`
+	Pinned        bool                       `json:"pinned"`
+	Blocked       *override.Rule             `json:"blocked,omitempty"`
+	Persistence   *Persistence               `json:"persistence,omitempty"`
+	FirstSeen     time.Time                  `json:"first_seen"`
+	LastSeen      time.Time                  `json:"last_seen"`
+	LastCheck     time.Time                  `json:"last_check"`
+}
+
+// DisplayName 返回带国旗的名称，名称为空时返回空字符串由导出方决定
+func (node *Node) DisplayName() string {
+	country := node.CountryCode()
+	var parts []string
+	if flag := CountryFlag(country); len(flag) > 0 {
+		parts = append(parts, flag)
+	}
+	if len(node.Name) > 0 {
+		parts = append(parts, node.Name)
+	} else if len(parts) > 0 {
+		parts = append(parts, country)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Location 返回节点的地理信息，出口优先于入口
+func (node *Node) Location() *geo.Info {
+	if node.Exit != nil {
+		return node.Exit
+	}
+	return node.Entry
+}
+
+// CountryCode 依次取出口、入口和名称中的国家
+func (node *Node) CountryCode() string {
+	if location := node.Location(); location != nil && len(location.Country) > 0 {
+		return location.Country
+	}
+	return node.Country
+}
+
+// Relayed 判断出口 IP 是否与入口 IP 不同，例如经过 CDN 或中转
+func (node *Node) Relayed() bool {
+	return len(node.EntryIP) > 0 && len(node.ExitIP) > 0 && node.EntryIP != node.ExitIP
+}
+
+// Event 返回节点事件的载荷，previous 为变化前的状态，可为空
+func (node *Node) Event(previous State) *event.Node {
+	return &event.Node{
+		ID:           node.ID,
+		Name:         node.Name,
+		Subscription: node.Subscription,
+		State:        string(node.State),
+		Previous:     string(previous),
+	}
+}
+
+// Get 返回节点，和 List 一样填充置顶、拉黑状态、标签和出现次数，节点不存在时返回 nil
+func Get(id string) (*Node, error) {
+	node, err := get(id)
+	if err != nil || node == nil {
+		return node, err
+	}
+	applyOverrides([]*Node{node})
+	applyPersistence([]*Node{node})
+	return node, nil
+}
+
+// get 返回存储中的节点，不含派生字段
+func get(id string) (*Node, error) {
+	return storage.Get[*Node](StorageKeyNode + id)
+}
+
+// List 返回全部节点，并填充用户覆盖的置顶、拉黑状态、标签和 git 订阅中的出现次数
+func List() ([]*Node, error) {
+	nodes, err := storage.List[*Node](StorageKeyNode)
+	if err != nil {
+		return nil, err
+	}
+	applyOverrides(nodes)
+	applyPersistence(nodes)
+	return nodes, nil
+}
+
+// Modify 在一次存储更新中修改节点，fn 收到存储中的最新节点（不含派生字段），
+// 只改 fn 修改的字段，不覆盖同时进行的其它更新；节点已被删除时不调用 fn，返回 nil
+func Modify(id string, fn func(node *Node)) (*Node, error) {
+	var modified *Node
+	err := storage.Modify(StorageKeyNode+id, func(node *Node) (*Node, bool) {
+		if node == nil {
+			return nil, false
+		}
+		fn(node)
+		modified = node
+		return node.stored(), true
+	})
+	return modified, err
+}
+
+// stored 返回写入存储的副本，派生的置顶、拉黑状态、标签和出现次数不写入存储，读取时重新填充
+func (node *Node) stored() *Node {
+	stored := *node
+	stored.Tags, stored.Pinned, stored.Blocked, stored.Persistence = nil, false, nil, nil
+	return &stored
+}
+
+// Save 保存订阅解析出的出站，已存在的节点只刷新出站和 LastSeen，被拉黑的订阅和节点不保存。
+// 已退役的节点重新出现时保持退役，直到被 Compact 删除
+func Save(sub *config.Subscription, outbounds []*conf.OutboundDetourConfig) []*Node {
+	subscription := sub.Name
+	blacklist, err := override.LoadBlacklist()
+	if err != nil {
+		logger.Error("Failed to load blacklist", "err", err.Error())
+	}
+	if rule := blacklist.Subscription(sub.Name, sub.Url); rule != nil {
+		logger.Info("Skipped blacklisted subscription", "subscription", subscription, "rule", rule.Value)
+		return nil
+	}
+
+	var nodes []*Node
+	now := time.Now()
+	for _, outbound := range outbounds {
+		id, err := xray.Fingerprint(*outbound)
+		if err != nil {
+			logger.Error("Failed to fingerprint outbound", "err", err.Error())
+			continue
+		}
+		host, _, _ := xray.Endpoint(*outbound)
+		if rule := blacklist.Match(override.Target{Fingerprint: id, Subscription: subscription, Host: host}); rule != nil {
+			logger.Debug("Skipped blacklisted node", "id", id, "kind", rule.Kind, "rule", rule.Value)
+			continue
+		}
+
+		// 解析出的出站在 Tag 中携带订阅里的名称，入库前换成由指纹生成的 Tag
+		name, country := NormalizeName(outbound.Tag)
+		outbound.Tag, err = xray.OutboundTag(*outbound)
+		if err != nil {
+			logger.Error("Failed to tag outbound", "id", id, "err", err.Error())
+			continue
+		}
+
+		// 在一次存储更新中读取并刷新节点，不覆盖同时进行的测量结果
+		var node *Node
+		discovered := false
+		err = storage.Modify(StorageKeyNode+id, func(stored *Node) (*Node, bool) {
+			discovered = stored == nil
+			if discovered {
+				stored = &Node{
+					ID:           id,
+					Subscription: subscription,
+					State:        StateNew,
+					FirstSeen:    now,
+				}
+			}
+			stored.Name, stored.Country = name, country
+			stored.Outbound = outbound
+			stored.Source = sub.Url
+			stored.LastSeen = now
+			node = stored
+			return stored.stored(), true
+		})
+		if err != nil {
+			logger.Error("Failed to save node", "id", id, "err", err.Error())
+			continue
+		}
+		if discovered {
+			event.Publish(event.NodeDiscovered, node.Event(""))
+		}
+		nodes = append(nodes, node)
+	}
+
+	logger.Info("Saved nodes from subscription", "subscription", subscription, "total", len(nodes))
+	return nodes
+}
@@ -0,0 +1,68 @@
+package node
+
+import (
+	"net/netip"
+	"slices"
+
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/override"
+	"zhouxin.learn/go/vxrayui/pkg/xray"
+)
+
+// Target 返回节点的黑名单匹配对象，IP 包括固定的解析结果和入口 IP
+func (node *Node) Target() override.Target {
+	target := override.Target{
+		Fingerprint:  node.ID,
+		Subscription: node.Subscription,
+	}
+	if node.Outbound != nil {
+		target.Host, _, _ = xray.Endpoint(*node.Outbound)
+	}
+	if node.DNS != nil {
+		target.IPs = node.DNS.IPs()
+	}
+	if ip, err := netip.ParseAddr(node.EntryIP); err == nil && !slices.Contains(target.IPs, ip) {
+		target.IPs = append(target.IPs, ip)
+	}
+	return target
+}
+
+// applyOverrides 填充节点的置顶、拉黑状态和标签，节点标签在前，订阅标签在后
+func applyOverrides(nodes []*Node) {
+	overrides, err := override.Load()
+	if err != nil {
+		logger.Error("Failed to load overrides", "err", err.Error())
+		return
+	}
+
+	for _, node := range nodes {
+		_, node.Pinned = overrides.Pins[node.ID]
+		node.Blocked = overrides.Blacklist.Match(node.Target())
+
+		node.Tags = nil
+		for _, tag := range overrides.Tags[override.TagScopeNode][node.ID] {
+			node.Tags = append(node.Tags, tag)
+		}
+		for _, tag := range overrides.Tags[override.TagScopeSubscription][node.Subscription] {
+			if !slices.Contains(node.Tags, tag) {
+				node.Tags = append(node.Tags, tag)
+			}
+		}
+	}
+}
+
+// Active 返回可用节点：符合筛选条件的节点加上置顶节点，去掉被拉黑的节点
+func Active(filter *Filter) ([]*Node, error) {
+	nodes, err := List()
+	if err != nil {
+		return nil, err
+	}
+	return active(nodes, filter), nil
+}
+
+// active 就地筛选已填充覆盖的节点
+func active(nodes []*Node, filter *Filter) []*Node {
+	return slices.DeleteFunc(nodes, func(node *Node) bool {
+		return node.Blocked != nil || !(node.Pinned || filter.Match(node))
+	})
+}
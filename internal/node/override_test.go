@@ -0,0 +1,44 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"zhouxin.learn/go/vxrayui/internal/override"
+)
+
+func TestActive(t *testing.T) {
+	blocked := &override.Rule{Kind: override.KindFingerprint, Value: "blocked"}
+	nodes := []*Node{
+		{ID: "healthy", Country: "JP", Healthy: true},
+		{ID: "unhealthy", Country: "JP"},
+		// pinned nodes are kept even when they fail the filter
+		{ID: "pinned-unhealthy", Country: "JP", Pinned: true},
+		{ID: "pinned-elsewhere", Country: "US", Healthy: true, Pinned: true},
+		// the blacklist wins over pins
+		{ID: "blocked-pinned", Country: "JP", Healthy: true, Pinned: true, Blocked: blocked},
+		{ID: "blocked", Country: "JP", Healthy: true, Blocked: blocked},
+	}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   []string
+	}{
+		{"nil", nil, []string{"healthy", "unhealthy", "pinned-unhealthy", "pinned-elsewhere"}},
+		{"healthy", &Filter{Healthy: true}, []string{"healthy", "pinned-unhealthy", "pinned-elsewhere"}},
+		{"country", &Filter{Countries: []string{"JP"}, Healthy: true}, []string{"healthy", "pinned-unhealthy", "pinned-elsewhere"}},
+		{"no match", &Filter{Countries: []string{"FR"}}, []string{"pinned-unhealthy", "pinned-elsewhere"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, node := range active(append([]*Node(nil), nodes...), tt.filter) {
+				got = append(got, node.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("active = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package node
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/storage"
+)
+
+const (
+	StorageKeyPersistence = "persistence.node."
+	StorageKeyRevisions   = "persistence.source."
+)
+
+// Persistence 是节点在 git 订阅最近若干次提交中出现的次数，越多越稳定
+type Persistence struct {
+	ID           string `json:"id"`
+	Subscription string `json:"subscription"`
+	Source       string `json:"source,omitempty"` // 订阅 Url，同名订阅以此区分
+	// 包含该节点的提交数
+	Revisions int `json:"revisions"`
+	// 检查的提交数
+	Commits int `json:"commits"`
+	// 最早包含该节点的提交时间
+	Since time.Time `json:"since"`
+}
+
+// key 返回记录的存储键，按来源和节点区分，同一节点在不同 git 订阅中的记录互不覆盖
+func (p *Persistence) key() string {
+	return fmt.Sprintf("%s%x.%s", StorageKeyPersistence, sha256.Sum256([]byte(p.Source)), p.ID)
+}
+
+// Score 返回节点出现的提交比例，0 到 1
+func (p *Persistence) Score() float64 {
+	if p == nil || p.Commits == 0 {
+		return 0
+	}
+	return float64(p.Revisions) / float64(p.Commits)
+}
+
+// Revision 是 git 订阅一次提交中的节点变化
+type Revision struct {
+	Commit  string    `json:"commit"`
+	Time    time.Time `json:"time"`
+	Nodes   int       `json:"nodes"`
+	Added   int       `json:"added"`
+	Removed int       `json:"removed"`
+}
+
+// SourceHistory 是 git 订阅最近若干次提交的节点演变，Revisions 从新到旧
+type SourceHistory struct {
+	Subscription string     `json:"subscription"`
+	Head         string     `json:"head"`
+	Revisions    []Revision `json:"revisions"`
+	// 最新提交中各节点 Persistence.Score 的平均值
+	Stability float64   `json:"stability"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SavePersistence 保存订阅的提交历史和其中节点的出现次数，覆盖该订阅之前的记录，
+// 不再出现在最近提交中的节点的记录被删除
+func SavePersistence(history *SourceHistory, persistence []*Persistence) error {
+	if err := storage.Set(StorageKeyRevisions+history.Subscription, history); err != nil {
+		return err
+	}
+	previous, err := storage.List[*Persistence](StorageKeyPersistence)
+	if err != nil {
+		return err
+	}
+	for _, p := range stalePersistence(previous, history.Subscription, persistence) {
+		if err := storage.Delete(p.key()); err != nil {
+			return err
+		}
+	}
+	for _, p := range persistence {
+		if err := storage.Set(p.key(), p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stalePersistence 返回来源为 source 且不在 current 中的记录
+func stalePersistence(previous []*Persistence, source string, current []*Persistence) []*Persistence {
+	keep := make(map[string]bool, len(current))
+	for _, p := range current {
+		keep[p.ID] = true
+	}
+	var stale []*Persistence
+	for _, p := range previous {
+		if p.Source == source && !keep[p.ID] {
+			stale = append(stale, p)
+		}
+	}
+	return stale
+}
+
+// GetSourceHistory 返回 git 订阅的提交历史，url 为订阅的 Url，没有记录时返回 nil
+func GetSourceHistory(url string) (*SourceHistory, error) {
+	return storage.Get[*SourceHistory](StorageKeyRevisions + url)
+}
+
+// applyPersistence 填充节点在 git 订阅历史中的出现次数
+func applyPersistence(nodes []*Node) {
+	records, err := storage.List[*Persistence](StorageKeyPersistence)
+	if err != nil {
+		logger.Error("Failed to load node persistence", "err", err.Error())
+		return
+	}
+	persistence := make(map[string][]*Persistence, len(records))
+	for _, p := range records {
+		persistence[p.ID] = append(persistence[p.ID], p)
+	}
+	for _, node := range nodes {
+		node.Persistence = selectPersistence(node, persistence[node.ID])
+	}
+}
+
+// selectPersistence 在节点出现于多个 git 订阅时选出一条记录：优先节点所属的订阅，其次出现比例最高的
+func selectPersistence(node *Node, records []*Persistence) *Persistence {
+	var selected *Persistence
+	for _, p := range records {
+		if p.Source == node.Source {
+			return p
+		}
+		if selected == nil || p.Score() > selected.Score() {
+			selected = p
+		}
+	}
+	return selected
+}
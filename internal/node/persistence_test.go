@@ -0,0 +1,48 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStalePersistence(t *testing.T) {
+	previous := []*Persistence{
+		{ID: "kept", Source: "git+https://example.com/a.git"},
+		{ID: "dropped", Source: "git+https://example.com/a.git"},
+		{ID: "other", Source: "git+https://example.com/b.git"},
+	}
+	current := []*Persistence{
+		{ID: "kept", Source: "git+https://example.com/a.git"},
+		{ID: "new", Source: "git+https://example.com/a.git"},
+	}
+	got := stalePersistence(previous, "git+https://example.com/a.git", current)
+	if want := previous[1:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("stale = %v, want %v", got, want)
+	}
+	// a subscription without nodes drops all of its records
+	if got := stalePersistence(previous, "git+https://example.com/b.git", nil); !reflect.DeepEqual(got, previous[2:]) {
+		t.Errorf("stale = %v, want [other]", got)
+	}
+}
+
+func TestPersistenceKey(t *testing.T) {
+	a := &Persistence{ID: "node", Source: "git+https://example.com/a.git"}
+	b := &Persistence{ID: "node", Source: "git+https://example.com/b.git"}
+	if a.key() == b.key() {
+		t.Errorf("records of two subscriptions share the key %s", a.key())
+	}
+}
+
+func TestSelectPersistence(t *testing.T) {
+	records := []*Persistence{
+		{ID: "node", Source: "a", Revisions: 1, Commits: 4},
+		{ID: "node", Source: "b", Revisions: 3, Commits: 4},
+		{ID: "node", Source: "c", Revisions: 2, Commits: 4},
+	}
+	if got := selectPersistence(&Node{Source: "c"}, records); got != records[2] {
+		t.Errorf("selected %+v, want the record of the node's own subscription", got)
+	}
+	if got := selectPersistence(&Node{Source: "d"}, records); got != records[1] {
+		t.Errorf("selected %+v, want the most persistent record", got)
+	}
+}
@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter 是令牌桶：最多连续发送 burst 条，之后每 interval 恢复一条
+type limiter struct {
+	mu       sync.Mutex
+	burst    float64
+	interval time.Duration
+	tokens   float64
+	last     time.Time
+}
+
+func newLimiter(burst int, interval time.Duration) *limiter {
+	b := float64(max(burst, 1))
+	return &limiter{burst: b, interval: interval, tokens: b}
+}
+
+// allow 判断现在能否发送，能发送时消耗一个令牌
+func (l *limiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
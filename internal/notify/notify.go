@@ -0,0 +1,287 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+)
+
+// Trigger 是触发通知的情况
+type Trigger string
+
+const (
+	// 当前出站切换到另一个健康节点
+	TriggerActiveChanged Trigger = "active_changed"
+	// 最后一个健康节点失效
+	TriggerNoHealthy Trigger = "no_healthy"
+	// 订阅连续拉取失败超过 FailingAfter
+	TriggerSubscriptionFailing Trigger = "subscription_failing"
+	// 订阅流量或到期告警
+	TriggerQuota Trigger = "quota"
+)
+
+const (
+	DefaultFailingAfter = 6 * time.Hour
+	sendTimeout         = 10 * time.Second
+	// 通知器可积压的事件数
+	eventBuffer = 64
+)
+
+// Notification 是发给各渠道的一条通知，Message 由模板渲染
+type Notification struct {
+	Trigger Trigger   `json:"trigger"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+	Data    any       `json:"data,omitempty"`
+}
+
+// Failing 是 subscription_failing 通知的数据
+type Failing struct {
+	Subscription string        `json:"subscription"`
+	Error        string        `json:"error"`
+	Since        time.Time     `json:"since"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// channel 是配置的一个通知渠道
+type channel struct {
+	name      string
+	sink      Sink
+	triggers  []Trigger
+	templates map[Trigger]*template.Template
+	limiter   *limiter
+}
+
+// Notifier 订阅事件总线，把关心的事件转成通知发往各渠道，每个渠道各自限流
+type Notifier struct {
+	channels     []*channel
+	failingAfter time.Duration
+
+	// 可替换以便测试
+	now func() time.Time
+
+	mu sync.Mutex
+	// 各订阅（按事件 ID）连续失败的开始时间和是否已通知
+	failingSince map[string]time.Time
+	notified     map[string]bool
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New 按配置创建通知器，渠道或模板配置错误时返回错误
+func New(cfg *config.Notify) (*Notifier, error) {
+	n := &Notifier{
+		failingAfter: DefaultFailingAfter,
+		now:          time.Now,
+		failingSince: make(map[string]time.Time),
+		notified:     make(map[string]bool),
+		stopChan:     make(chan struct{}),
+	}
+	if cfg == nil {
+		return n, nil
+	}
+	if cfg.FailingAfter > 0 {
+		n.failingAfter = cfg.FailingAfter
+	}
+	for _, sinkCfg := range cfg.Sinks {
+		c, err := newChannel(sinkCfg)
+		if err != nil {
+			return nil, fmt.Errorf("notify sink %s: %w", sinkCfg.Name, err)
+		}
+		n.channels = append(n.channels, c)
+	}
+	return n, nil
+}
+
+func newChannel(cfg *config.NotifySink) (*channel, error) {
+	sink, err := newSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &channel{name: cfg.Name, sink: sink, templates: make(map[Trigger]*template.Template)}
+	for _, name := range cfg.Events {
+		trigger := Trigger(name)
+		if _, ok := defaultTemplates[trigger]; !ok {
+			return nil, fmt.Errorf("unknown event %q", name)
+		}
+		c.triggers = append(c.triggers, trigger)
+	}
+	for trigger, text := range defaultTemplates {
+		if custom, ok := cfg.Templates[string(trigger)]; ok {
+			text = custom
+		}
+		tmpl, err := template.New(string(trigger)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", trigger, err)
+		}
+		c.templates[trigger] = tmpl
+	}
+	if cfg.RateLimit != nil && cfg.RateLimit.Interval > 0 {
+		c.limiter = newLimiter(cfg.RateLimit.Burst, cfg.RateLimit.Interval)
+	}
+	return c, nil
+}
+
+// Run 处理总线上的事件直到 Stop 被调用
+func (n *Notifier) Run() {
+	// 与 Stop 互斥，Stop 之后调用的 Run 直接返回，Wait 不会漏掉之后才开始的 Run
+	n.mu.Lock()
+	select {
+	case <-n.stopChan:
+		n.mu.Unlock()
+		return
+	default:
+	}
+	n.wg.Add(1)
+	n.mu.Unlock()
+	defer n.wg.Done()
+
+	if len(n.channels) == 0 {
+		return
+	}
+	subscriber := event.Subscribe(eventBuffer, "subscription", event.OutboundSwitched)
+	defer subscriber.Close()
+
+	for {
+		select {
+		case e := <-subscriber.Events():
+			n.handle(e)
+		case <-n.stopChan:
+			return
+		}
+	}
+}
+
+func (n *Notifier) Stop() {
+	n.mu.Lock()
+	close(n.stopChan)
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+// handle 把一条事件转成通知，不关心的事件忽略
+func (n *Notifier) handle(e event.Event) {
+	switch data := e.Data.(type) {
+	case *event.Switch:
+		switch {
+		case len(data.To) > 0:
+			n.notify(TriggerActiveChanged, data)
+		case len(data.From) > 0:
+			n.notify(TriggerNoHealthy, data)
+		}
+	case *event.Quota:
+		n.notify(TriggerQuota, data)
+	case *event.Subscription:
+		if failing := n.track(e.Type, data); failing != nil {
+			n.notify(TriggerSubscriptionFailing, failing)
+		}
+	}
+}
+
+// track 记录订阅的连续失败，失败持续超过 failingAfter 时返回一次通知数据，拉取成功后重新计时。
+// 订阅按事件中的 ID 区分，事件中的 URL 已脱敏，仅 token 不同的订阅 URL 相同
+func (n *Notifier) track(t event.Type, data *event.Subscription) *Failing {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	key := data.ID
+	if len(key) == 0 {
+		key = data.URL
+	}
+	switch t {
+	case event.SubscriptionFetched:
+		delete(n.failingSince, key)
+		delete(n.notified, key)
+		return nil
+	case event.SubscriptionFailed:
+	default:
+		return nil
+	}
+
+	now := n.now()
+	since, ok := n.failingSince[key]
+	if !ok {
+		since = now
+		n.failingSince[key] = since
+	}
+	if n.notified[key] || now.Sub(since) < n.failingAfter {
+		return nil
+	}
+	n.notified[key] = true
+	return &Failing{
+		Subscription: subscriptionName(data),
+		Error:        redactError(data.Error),
+		Since:        since,
+		Duration:     now.Sub(since).Round(time.Minute),
+	}
+}
+
+// subscriptionName 返回订阅名称，没有名称时返回 URL 的主机，避免把 token 发到外部
+func subscriptionName(data *event.Subscription) string {
+	if len(data.Name) > 0 {
+		return data.Name
+	}
+	if u, err := url.Parse(data.URL); err == nil && len(u.Host) > 0 {
+		return u.Host
+	}
+	return "unnamed"
+}
+
+// urlPattern 匹配错误信息中的 http 地址，引号和空白视为结束
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// redactError 去掉错误信息中 URL 的用户信息、查询参数和片段，拉取错误常带有完整的订阅地址和 token
+func redactError(message string) string {
+	return urlPattern.ReplaceAllStringFunc(message, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil {
+			raw, _, _ = strings.Cut(raw, "?")
+			raw, _, _ = strings.Cut(raw, "#")
+			return raw
+		}
+		u.User = nil
+		u.RawQuery = ""
+		u.Fragment = ""
+		return u.String()
+	})
+}
+
+// notify 向订阅了该触发的渠道发送通知，被限流的渠道跳过
+func (n *Notifier) notify(trigger Trigger, data any) {
+	now := n.now()
+	for _, c := range n.channels {
+		if len(c.triggers) > 0 && !slices.Contains(c.triggers, trigger) {
+			continue
+		}
+		if c.limiter != nil && !c.limiter.allow(now) {
+			logger.Warn("Notification rate limited", "sink", c.name, "trigger", string(trigger))
+			continue
+		}
+
+		notification, err := c.render(trigger, data, now)
+		if err != nil {
+			logger.Error("Failed to render notification", "sink", c.name, "trigger", string(trigger), "err", err.Error())
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = c.sink.Send(ctx, notification)
+		cancel()
+		if err != nil {
+			logger.Error("Failed to send notification", "sink", c.name, "trigger", string(trigger), "err", err.Error())
+			continue
+		}
+		logger.Info("Sent notification", "sink", c.name, "trigger", string(trigger))
+	}
+}
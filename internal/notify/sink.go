@@ -0,0 +1,195 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"zhouxin.learn/go/vxrayui/config"
+)
+
+// 通知渠道的类型
+const (
+	SinkWebhook  = "webhook"
+	SinkTelegram = "telegram"
+	SinkEmail    = "email"
+)
+
+const defaultTelegramApi = "https://api.telegram.org"
+
+// Sink 把通知发送到一个外部渠道
+type Sink interface {
+	Send(ctx context.Context, notification *Notification) error
+}
+
+func newSink(cfg *config.NotifySink) (Sink, error) {
+	switch cfg.Type {
+	case SinkWebhook:
+		if len(cfg.Url) == 0 {
+			return nil, errors.New("webhook needs a url")
+		}
+		return &webhookSink{url: cfg.Url, headers: cfg.Headers}, nil
+	case SinkTelegram:
+		if len(cfg.BotToken) == 0 || len(cfg.ChatId) == 0 {
+			return nil, errors.New("telegram needs bot_token and chat_id")
+		}
+		api := strings.TrimSuffix(cfg.ApiUrl, "/")
+		if len(api) == 0 {
+			api = defaultTelegramApi
+		}
+		return &telegramSink{api: api, token: cfg.BotToken, chatId: cfg.ChatId}, nil
+	case SinkEmail:
+		if cfg.Smtp == nil || len(cfg.Smtp.Host) == 0 || len(cfg.Smtp.From) == 0 || len(cfg.Smtp.To) == 0 {
+			return nil, errors.New("email needs smtp host, from and to")
+		}
+		return &emailSink{smtp: cfg.Smtp}, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+}
+
+// webhookSink 以 JSON POST 整条通知
+type webhookSink struct {
+	url     string
+	headers map[string]string
+}
+
+func (s *webhookSink) Send(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	return do(req)
+}
+
+// telegramSink 通过 Bot API 的 sendMessage 发送标题和消息
+type telegramSink struct {
+	api    string
+	token  string
+	chatId string
+}
+
+func (s *telegramSink) Send(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": s.chatId,
+		"text":    notification.Title + "\n" + notification.Message,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.api+"/bot"+s.token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(req)
+}
+
+// do 发送请求，非 2xx 响应返回带响应体开头的错误
+func do(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// emailSink 通过 SMTP 发送纯文本邮件
+type emailSink struct {
+	smtp *config.Smtp
+}
+
+func (s *emailSink) Send(ctx context.Context, notification *Notification) error {
+	port := s.smtp.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(s.smtp.Host, strconv.Itoa(port))
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{}
+	if port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.smtp.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.smtp.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && port != 465 {
+		if err := client.StartTLS(&tls.Config{ServerName: s.smtp.Host}); err != nil {
+			return err
+		}
+	}
+	if len(s.smtp.Username) > 0 {
+		if err := client.Auth(smtp.PlainAuth("", s.smtp.Username, s.smtp.Password, s.smtp.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.smtp.From); err != nil {
+		return err
+	}
+	for _, to := range s.smtp.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(notification)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message 生成邮件头和正文，标题按 RFC 2047 编码
+func (s *emailSink) message(notification *Notification) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.smtp.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.smtp.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", notification.Time.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(notification.Message, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
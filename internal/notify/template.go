@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"strings"
+	"time"
+)
+
+// 各触发的标题
+var titles = map[Trigger]string{
+	TriggerActiveChanged:       "Active outbound changed",
+	TriggerNoHealthy:           "No healthy nodes",
+	TriggerSubscriptionFailing: "Subscription failing",
+	TriggerQuota:               "Subscription quota",
+}
+
+// 默认的消息模板，模板数据是 Notification，.Data 为各触发的数据：
+// active_changed 和 no_healthy 为 event.Switch，subscription_failing 为 Failing，quota 为 event.Quota
+var defaultTemplates = map[Trigger]string{
+	TriggerActiveChanged:       `Active outbound switched to {{.Data.Name}}{{with .Data.Latency}} ({{.}}){{end}}`,
+	TriggerNoHealthy:           `No healthy nodes left{{with .Data.From}}, the last active outbound was {{.}}{{end}}`,
+	TriggerSubscriptionFailing: `Subscription {{.Data.Subscription}} has been failing for {{.Data.Duration}}: {{.Data.Error}}`,
+	TriggerQuota:               `{{.Data.Message}}`,
+}
+
+// render 用渠道的模板生成通知
+func (c *channel) render(trigger Trigger, data any, now time.Time) (*Notification, error) {
+	notification := &Notification{
+		Trigger: trigger,
+		Title:   titles[trigger],
+		Time:    now,
+		Data:    data,
+	}
+	var message strings.Builder
+	if err := c.templates[trigger].Execute(&message, notification); err != nil {
+		return nil, err
+	}
+	notification.Message = message.String()
+	return notification, nil
+}
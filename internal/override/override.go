@@ -0,0 +1,260 @@
+package override
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/internal/storage"
+)
+
+const (
+	StorageKeyPin       = "override.pin."
+	StorageKeyBlacklist = "override.blacklist."
+	StorageKeyTags      = "override.tags."
+)
+
+// Kind 是黑名单规则的匹配方式
+type Kind string
+
+const (
+	KindFingerprint  Kind = "fingerprint"
+	KindAddress      Kind = "address"
+	KindCIDR         Kind = "cidr"
+	KindDomain       Kind = "domain"
+	KindSubscription Kind = "subscription"
+)
+
+// TagScope 是标签的归属，订阅的标签作用于其下所有节点
+type TagScope string
+
+const (
+	TagScopeNode         TagScope = "node"
+	TagScopeSubscription TagScope = "subscription"
+)
+
+// Pin 让节点始终处于可用集合中，不受健康状态和筛选条件影响
+type Pin struct {
+	NodeID    string    `json:"node_id"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Rule 是一条黑名单规则，拉黑优先于置顶
+type Rule struct {
+	Kind      Kind      `json:"kind"`
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	prefix netip.Prefix
+}
+
+// Tags 是节点或订阅的自由标签
+type Tags struct {
+	Scope TagScope `json:"scope"`
+	ID    string   `json:"id"`
+	Tags  []string `json:"tags"`
+}
+
+// Target 是黑名单匹配的对象，订阅可以是名称或 URL
+type Target struct {
+	Fingerprint  string
+	Subscription string
+	Host         string
+	IPs          []netip.Addr
+}
+
+// NewRule 校验并规范化规则，域名后缀去掉开头的点并转为小写
+func NewRule(kind Kind, value string, reason string) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return nil, fmt.Errorf("empty %s rule", kind)
+	}
+
+	rule := &Rule{Kind: kind, Value: value, Reason: reason, CreatedAt: time.Now()}
+	switch kind {
+	case KindFingerprint, KindSubscription:
+	case KindAddress:
+		rule.Value = strings.ToLower(strings.Trim(value, "[]"))
+	case KindDomain:
+		rule.Value = strings.ToLower(strings.TrimPrefix(value, "."))
+	case KindCIDR:
+		if err := rule.parse(); err != nil {
+			return nil, err
+		}
+		rule.Value = rule.prefix.String()
+	default:
+		return nil, fmt.Errorf("unknown rule kind: %s", kind)
+	}
+	return rule, nil
+}
+
+func (rule *Rule) parse() error {
+	if rule.Kind != KindCIDR || rule.prefix.IsValid() {
+		return nil
+	}
+	prefix, err := netip.ParsePrefix(rule.Value)
+	if err != nil {
+		// 单个 IP 视为 /32 或 /128
+		ip, ipErr := netip.ParseAddr(rule.Value)
+		if ipErr != nil {
+			return fmt.Errorf("invalid cidr: %s", rule.Value)
+		}
+		prefix = netip.PrefixFrom(ip, ip.BitLen())
+	}
+	rule.prefix = prefix.Masked()
+	return nil
+}
+
+func (rule *Rule) Match(target Target) bool {
+	switch rule.Kind {
+	case KindFingerprint:
+		return rule.Value == target.Fingerprint
+	case KindSubscription:
+		return len(target.Subscription) > 0 && rule.Value == target.Subscription
+	case KindAddress:
+		if strings.EqualFold(rule.Value, target.Host) {
+			return true
+		}
+		ip, err := netip.ParseAddr(rule.Value)
+		return err == nil && slices.Contains(target.IPs, ip)
+	case KindDomain:
+		host := strings.ToLower(target.Host)
+		return host == rule.Value || strings.HasSuffix(host, "."+rule.Value)
+	case KindCIDR:
+		if rule.parse() != nil {
+			return false
+		}
+		if ip, err := netip.ParseAddr(target.Host); err == nil && rule.prefix.Contains(ip.Unmap()) {
+			return true
+		}
+		return slices.ContainsFunc(target.IPs, func(ip netip.Addr) bool { return rule.prefix.Contains(ip.Unmap()) })
+	}
+	return false
+}
+
+func (rule *Rule) key() string {
+	return StorageKeyBlacklist + string(rule.Kind) + "." + rule.Value
+}
+
+// Blacklist 是一次加载的全部规则
+type Blacklist []*Rule
+
+// Match 返回第一条匹配的规则
+func (blacklist Blacklist) Match(target Target) *Rule {
+	for _, rule := range blacklist {
+		if rule.Match(target) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Subscription 判断订阅是否被拉黑，names 可同时传入订阅名称和 URL
+func (blacklist Blacklist) Subscription(names ...string) *Rule {
+	for _, name := range names {
+		if rule := blacklist.Match(Target{Subscription: name}); rule != nil {
+			return rule
+		}
+	}
+	return nil
+}
+
+func LoadBlacklist() (Blacklist, error) {
+	return storage.List[*Rule](StorageKeyBlacklist)
+}
+
+func Block(rule *Rule) error {
+	return storage.Set(rule.key(), rule)
+}
+
+func Unblock(kind Kind, value string) error {
+	rule, err := NewRule(kind, value, "")
+	if err != nil {
+		return err
+	}
+	return storage.Delete(rule.key())
+}
+
+func LoadPins() (map[string]*Pin, error) {
+	pins, err := storage.List[*Pin](StorageKeyPin)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Pin, len(pins))
+	for _, pin := range pins {
+		byID[pin.NodeID] = pin
+	}
+	return byID, nil
+}
+
+func PinNode(id string, reason string) error {
+	return storage.Set(StorageKeyPin+id, &Pin{NodeID: id, Reason: reason, CreatedAt: time.Now()})
+}
+
+func UnpinNode(id string) error {
+	return storage.Delete(StorageKeyPin + id)
+}
+
+// LoadTags 返回按 scope 和 ID 索引的标签
+func LoadTags() (map[TagScope]map[string][]string, error) {
+	all, err := storage.List[*Tags](StorageKeyTags)
+	if err != nil {
+		return nil, err
+	}
+	byScope := map[TagScope]map[string][]string{
+		TagScopeNode:         {},
+		TagScopeSubscription: {},
+	}
+	for _, tags := range all {
+		if byID, ok := byScope[tags.Scope]; ok {
+			byID[tags.ID] = tags.Tags
+		}
+	}
+	return byScope, nil
+}
+
+// SetTags 覆盖节点或订阅的标签，空标签等同于删除
+func SetTags(scope TagScope, id string, tags []string) error {
+	if scope != TagScopeNode && scope != TagScopeSubscription {
+		return fmt.Errorf("unknown tag scope: %s", scope)
+	}
+	key := StorageKeyTags + string(scope) + "." + id
+
+	var normalized []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) == 0 {
+		return storage.Delete(key)
+	}
+	return storage.Set(key, &Tags{Scope: scope, ID: id, Tags: normalized})
+}
+
+// Overrides 是一次加载的全部用户覆盖
+type Overrides struct {
+	Pins      map[string]*Pin
+	Blacklist Blacklist
+	Tags      map[TagScope]map[string][]string
+}
+
+func Load() (*Overrides, error) {
+	pins, err := LoadPins()
+	if err != nil {
+		return nil, err
+	}
+	blacklist, err := LoadBlacklist()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := LoadTags()
+	if err != nil {
+		return nil, err
+	}
+	return &Overrides{Pins: pins, Blacklist: blacklist, Tags: tags}, nil
+}
@@ -0,0 +1,85 @@
+package override
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestRuleMatch(t *testing.T) {
+	target := Target{
+		Fingerprint:  "0123456789abcdef",
+		Subscription: "free",
+		Host:         "jp1.Example.com",
+		IPs:          []netip.Addr{netip.MustParseAddr("203.0.113.7")},
+	}
+
+	tests := []struct {
+		kind  Kind
+		value string
+		want  bool
+	}{
+		{KindFingerprint, "0123456789abcdef", true},
+		{KindFingerprint, "fedcba9876543210", false},
+		{KindSubscription, "free", true},
+		{KindSubscription, "paid", false},
+		{KindAddress, "JP1.example.com", true},
+		{KindAddress, "203.0.113.7", true},
+		{KindAddress, "203.0.113.8", false},
+		{KindDomain, ".example.com", true},
+		{KindDomain, "example.com", true},
+		{KindDomain, "ample.com", false},
+		{KindCIDR, "203.0.113.0/24", true},
+		{KindCIDR, "203.0.113.7", true},
+		{KindCIDR, "198.51.100.0/24", false},
+	}
+	for _, test := range tests {
+		rule, err := NewRule(test.kind, test.value, "")
+		if err != nil {
+			t.Fatalf("NewRule(%s, %q): %v", test.kind, test.value, err)
+		}
+		if got := rule.Match(target); got != test.want {
+			t.Errorf("%s %q Match = %v, want %v", test.kind, test.value, got, test.want)
+		}
+	}
+}
+
+func TestRuleMatchHostIP(t *testing.T) {
+	rule, err := NewRule(KindCIDR, "2001:db8::/32", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rule.Match(Target{Host: "2001:db8::1"}) {
+		t.Error("cidr does not match an ip host")
+	}
+}
+
+func TestNewRuleInvalid(t *testing.T) {
+	for _, test := range []struct {
+		kind  Kind
+		value string
+	}{
+		{KindCIDR, "203.0.113.0/33"},
+		{KindDomain, " "},
+		{"port", "443"},
+	} {
+		if _, err := NewRule(test.kind, test.value, ""); err == nil {
+			t.Errorf("NewRule(%s, %q) succeeded", test.kind, test.value)
+		}
+	}
+}
+
+func TestBlacklistSubscription(t *testing.T) {
+	rule, _ := NewRule(KindSubscription, "https://example.com/sub", "")
+	blacklist := Blacklist{rule}
+	if blacklist.Subscription("free", "https://example.com/sub") == nil {
+		t.Error("subscription url is not blacklisted")
+	}
+	if blacklist.Subscription("free", "") != nil {
+		t.Error("other subscription is blacklisted")
+	}
+
+	var empty Blacklist
+	if empty.Subscription("free") != nil {
+		t.Error("empty blacklist matches")
+	}
+}
@@ -0,0 +1,338 @@
+package scheduler
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+)
+
+// Prober 测量一个节点并保存结果，返回保存后的节点，用于安排下次测量；节点已被删除时返回 nil
+type Prober func(n *node.Node) *node.Node
+
+// Options 是调度参数，零值字段使用 DefaultOptions
+type Options struct {
+	ActiveInterval     time.Duration
+	CandidateInterval  time.Duration
+	QuarantineInterval time.Duration
+	Jitter             float64
+	Concurrency        int
+	SubscriptionRate   int
+	Budget             int
+	Tick               time.Duration
+}
+
+var DefaultOptions = Options{
+	ActiveInterval:     5 * time.Minute,
+	CandidateInterval:  30 * time.Minute,
+	QuarantineInterval: 6 * time.Hour,
+	Jitter:             0.2,
+	Concurrency:        4,
+	SubscriptionRate:   6,
+	Budget:             600,
+	Tick:               10 * time.Second,
+}
+
+// 测量优先级，数值小的先测
+const (
+	priorityActive = iota
+	priorityCandidate
+	priorityQuarantined
+)
+
+// Scheduler 按节点状态自适应地安排测量：可用节点频繁复测，候选节点较少，隔离节点很少。
+// 全局并发、每个订阅的速率和每小时总量都有上限，间隔带有随机抖动，避免探测流量像扫描
+type Scheduler struct {
+	probe   Prober
+	options Options
+
+	// 可替换以便测试
+	now    func() time.Time
+	after  func(d time.Duration) <-chan time.Time
+	list   func() ([]*node.Node, error)
+	random func() float64
+
+	mu sync.Mutex
+	// 节点下次测量时间，首次见到节点时由 LastCheck 推算
+	next     map[string]time.Time
+	inflight map[string]bool
+	// 每个订阅下一次测量最早可以开始的时间
+	nextSlot map[string]time.Time
+	// 最近一小时内的测量时间，用于总量限制
+	history []time.Time
+
+	semaphore chan struct{}
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	probes    sync.WaitGroup
+}
+
+func New(probe Prober, options Options) *Scheduler {
+	options = withDefaults(options)
+	return &Scheduler{
+		probe:     probe,
+		options:   options,
+		now:       time.Now,
+		after:     time.After,
+		list:      node.List,
+		random:    rand.Float64,
+		next:      make(map[string]time.Time),
+		inflight:  make(map[string]bool),
+		nextSlot:  make(map[string]time.Time),
+		semaphore: make(chan struct{}, options.Concurrency),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// NewFromConfig 使用配置文件中的调度参数
+func NewFromConfig(probe Prober) *Scheduler {
+	var options Options
+	if cfg := config.GetScheduler(); cfg != nil {
+		options = Options{
+			ActiveInterval:     cfg.ActiveInterval,
+			CandidateInterval:  cfg.CandidateInterval,
+			QuarantineInterval: cfg.QuarantineInterval,
+			Jitter:             cfg.Jitter,
+			Concurrency:        cfg.Concurrency,
+			SubscriptionRate:   cfg.SubscriptionRate,
+			Budget:             cfg.Budget,
+			Tick:               cfg.Tick,
+		}
+	}
+	return New(probe, options)
+}
+
+func withDefaults(options Options) Options {
+	if options.ActiveInterval <= 0 {
+		options.ActiveInterval = DefaultOptions.ActiveInterval
+	}
+	if options.CandidateInterval <= 0 {
+		options.CandidateInterval = DefaultOptions.CandidateInterval
+	}
+	if options.QuarantineInterval <= 0 {
+		options.QuarantineInterval = DefaultOptions.QuarantineInterval
+	}
+	if options.Jitter <= 0 || options.Jitter >= 1 {
+		options.Jitter = DefaultOptions.Jitter
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = DefaultOptions.Concurrency
+	}
+	if options.SubscriptionRate <= 0 {
+		options.SubscriptionRate = DefaultOptions.SubscriptionRate
+	}
+	if options.Budget <= 0 {
+		options.Budget = DefaultOptions.Budget
+	}
+	if options.Tick <= 0 {
+		options.Tick = DefaultOptions.Tick
+	}
+	return options
+}
+
+func (s *Scheduler) Run() {
+	// 与 Stop 互斥，Stop 之后调用的 Run 直接返回，Wait 不会漏掉之后才开始的 Run
+	s.mu.Lock()
+	select {
+	case <-s.stopChan:
+		s.mu.Unlock()
+		return
+	default:
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.options.Tick)
+	defer ticker.Stop()
+
+	s.tick()
+	for {
+		select {
+		case <-ticker.C:
+			s.tick()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Stop 停止调度并等待进行中的测量结束
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	close(s.stopChan)
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.probes.Wait()
+}
+
+// tick 找出到期的节点，在并发、订阅速率和总量限制内启动测量
+func (s *Scheduler) tick() {
+	nodes, err := s.list()
+	if err != nil {
+		logger.Error("Failed to list nodes for measurement", "err", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	due := s.due(nodes, now)
+	s.trimHistory(now)
+
+	var started int
+	for _, n := range due {
+		if len(s.history) >= s.options.Budget {
+			logger.Debug("Measurement budget exhausted", "due", len(due)-started)
+			return
+		}
+		slot, ok := s.subscriptionSlot(n.Subscription, now)
+		if !ok {
+			continue
+		}
+
+		select {
+		case s.semaphore <- struct{}{}:
+		default:
+			// 并发已满，剩余节点留到下一轮
+			return
+		}
+
+		s.inflight[n.ID] = true
+		s.nextSlot[n.Subscription] = slot.Add(s.subscriptionSpacing())
+		s.history = append(s.history, now)
+		started++
+
+		s.probes.Add(1)
+		go func(n *node.Node, delay time.Duration) {
+			defer s.probes.Done()
+			defer func() { <-s.semaphore }()
+
+			if delay > 0 {
+				select {
+				case <-s.after(delay):
+				case <-s.stopChan:
+					s.mu.Lock()
+					delete(s.inflight, n.ID)
+					s.mu.Unlock()
+					return
+				}
+			}
+			// 下次测量按测量后的状态安排，n 是测量前读取的节点
+			updated := s.probe(n)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.inflight, n.ID)
+			if updated == nil {
+				delete(s.next, n.ID)
+				return
+			}
+			s.next[n.ID] = s.schedule(updated, s.now())
+		}(n, slot.Sub(now))
+	}
+}
+
+// due 返回到期的节点，按优先级和逾期时长排序
+func (s *Scheduler) due(nodes []*node.Node, now time.Time) []*node.Node {
+	var due []*node.Node
+	seen := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		seen[n.ID] = true
+		if n.Blocked != nil || n.State == node.StateRetired || s.inflight[n.ID] {
+			continue
+		}
+		next, ok := s.next[n.ID]
+		if !ok {
+			next = s.schedule(n, n.LastCheck)
+			s.next[n.ID] = next
+		}
+		if !now.Before(next) {
+			due = append(due, n)
+		}
+	}
+
+	// 清理已删除节点的记录
+	for id := range s.next {
+		if !seen[id] {
+			delete(s.next, id)
+		}
+	}
+
+	slices.SortStableFunc(due, func(a, b *node.Node) int {
+		if pa, pb := priority(a), priority(b); pa != pb {
+			return pa - pb
+		}
+		return s.next[a.ID].Compare(s.next[b.ID])
+	})
+	return due
+}
+
+// schedule 由上次测量时间推算下次测量时间，从未测量的节点立即到期
+func (s *Scheduler) schedule(n *node.Node, last time.Time) time.Time {
+	if last.IsZero() {
+		return time.Time{}
+	}
+	next := last.Add(s.jitter(s.interval(n)))
+	if n.State == node.StateQuarantined && n.NextCheck.After(next) {
+		next = n.NextCheck
+	}
+	return next
+}
+
+// interval 是节点所处状态的测量间隔
+func (s *Scheduler) interval(n *node.Node) time.Duration {
+	switch {
+	case n.State == node.StateQuarantined:
+		return s.options.QuarantineInterval
+	case n.State == node.StateDegraded:
+		return s.options.ActiveInterval / 2
+	case n.State == node.StateHealthy || n.Pinned:
+		return s.options.ActiveInterval
+	}
+	return s.options.CandidateInterval
+}
+
+func (s *Scheduler) jitter(interval time.Duration) time.Duration {
+	factor := 1 + s.options.Jitter*(2*s.random()-1)
+	return time.Duration(float64(interval) * factor)
+}
+
+func priority(n *node.Node) int {
+	switch {
+	case n.State == node.StateHealthy || n.State == node.StateDegraded || n.Pinned:
+		return priorityActive
+	case n.State == node.StateQuarantined:
+		return priorityQuarantined
+	}
+	return priorityCandidate
+}
+
+// subscriptionSlot 返回订阅下一次测量的开始时间，落在本轮 Tick 之后时不允许测量。
+// 同一订阅的测量间隔不小于 1 分钟 / SubscriptionRate，间隔短于 Tick 时一轮内可以
+// 安排多次测量，延迟到各自的时间开始，速率不受 Tick 限制
+func (s *Scheduler) subscriptionSlot(subscription string, now time.Time) (time.Time, bool) {
+	slot := s.nextSlot[subscription]
+	if slot.Before(now) {
+		slot = now
+	}
+	return slot, slot.Before(now.Add(s.options.Tick))
+}
+
+func (s *Scheduler) subscriptionSpacing() time.Duration {
+	return time.Minute / time.Duration(s.options.SubscriptionRate)
+}
+
+func (s *Scheduler) trimHistory(now time.Time) {
+	cutoff := now.Add(-time.Hour)
+	i := 0
+	for i < len(s.history) && !s.history[i].After(cutoff) {
+		i++
+	}
+	s.history = s.history[i:]
+}
@@ -0,0 +1,282 @@
+package scheduler
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/internal/override"
+)
+
+// fakeScheduler keeps nodes as stored records: list returns copies, and probing records
+// the node, stamps LastCheck with the fake clock, applies measure and returns a copy
+type fakeScheduler struct {
+	*Scheduler
+	clock   time.Time
+	nodes   []*node.Node
+	measure func(n *node.Node)
+	mu      sync.Mutex
+	probed  []string
+	delays  []time.Duration
+}
+
+func newFakeScheduler(options Options, nodes ...*node.Node) *fakeScheduler {
+	f := &fakeScheduler{clock: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), nodes: nodes}
+	f.Scheduler = New(func(n *node.Node) *node.Node {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.probed = append(f.probed, n.ID)
+		i := slices.IndexFunc(f.nodes, func(stored *node.Node) bool { return stored.ID == n.ID })
+		if i < 0 {
+			return nil
+		}
+		stored := f.nodes[i]
+		stored.LastCheck = f.clock
+		if f.measure != nil {
+			f.measure(stored)
+		}
+		updated := *stored
+		return &updated
+	}, options)
+	f.now = func() time.Time { return f.clock }
+	f.after = func(d time.Duration) <-chan time.Time {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.delays = append(f.delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- f.clock.Add(d)
+		return ch
+	}
+	f.list = func() ([]*node.Node, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		nodes := make([]*node.Node, 0, len(f.nodes))
+		for _, stored := range f.nodes {
+			n := *stored
+			nodes = append(nodes, &n)
+		}
+		return nodes, nil
+	}
+	f.random = func() float64 { return 0.5 }
+	return f
+}
+
+// run ticks at the given offset and waits for the probes it started
+func (f *fakeScheduler) run(offset time.Duration) []string {
+	f.mu.Lock()
+	f.clock = f.clock.Add(offset)
+	f.probed = nil
+	f.mu.Unlock()
+
+	f.tick()
+	f.probes.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	probed := slices.Clone(f.probed)
+	slices.Sort(probed)
+	return probed
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	f := newFakeScheduler(Options{Concurrency: 1, SubscriptionRate: 1000},
+		&node.Node{ID: "candidate", Subscription: "a", State: node.StateNew},
+		&node.Node{ID: "quarantined", Subscription: "b", State: node.StateQuarantined},
+		&node.Node{ID: "healthy", Subscription: "c", State: node.StateHealthy},
+	)
+
+	for _, want := range []string{"healthy", "candidate", "quarantined"} {
+		if got := f.run(0); !slices.Equal(got, []string{want}) {
+			t.Fatalf("probed %v, want [%s]", got, want)
+		}
+	}
+}
+
+func TestSchedulerIntervals(t *testing.T) {
+	options := Options{
+		ActiveInterval:     5 * time.Minute,
+		CandidateInterval:  30 * time.Minute,
+		QuarantineInterval: 2 * time.Hour,
+		SubscriptionRate:   1000,
+	}
+	f := newFakeScheduler(options,
+		&node.Node{ID: "healthy", Subscription: "a", State: node.StateHealthy},
+		&node.Node{ID: "degraded", Subscription: "b", State: node.StateDegraded},
+		&node.Node{ID: "candidate", Subscription: "c", State: node.StateTesting},
+		&node.Node{ID: "quarantined", Subscription: "d", State: node.StateQuarantined},
+		&node.Node{ID: "retired", Subscription: "e", State: node.StateRetired},
+		&node.Node{ID: "blocked", Subscription: "f", State: node.StateHealthy, Blocked: &override.Rule{}},
+	)
+
+	steps := []struct {
+		offset time.Duration
+		want   []string
+	}{
+		{0, []string{"candidate", "degraded", "healthy", "quarantined"}},
+		{150 * time.Second, []string{"degraded"}},
+		{150 * time.Second, []string{"degraded", "healthy"}},
+		{25 * time.Minute, []string{"candidate", "degraded", "healthy"}},
+		{90 * time.Minute, []string{"candidate", "degraded", "healthy", "quarantined"}},
+	}
+	for i, step := range steps {
+		if got := f.run(step.offset); !slices.Equal(got, step.want) {
+			t.Errorf("step %d: probed %v, want %v", i, got, step.want)
+		}
+	}
+}
+
+func TestSchedulerQuarantineBackoff(t *testing.T) {
+	f := newFakeScheduler(Options{QuarantineInterval: time.Hour})
+	n := &node.Node{ID: "quarantined", State: node.StateQuarantined}
+	f.nodes = []*node.Node{n}
+
+	f.run(0)
+	// the lifecycle backoff wins when it is longer than the quarantine interval
+	n.NextCheck = f.clock.Add(3 * time.Hour)
+	f.next = map[string]time.Time{}
+
+	if got := f.run(2 * time.Hour); len(got) > 0 {
+		t.Errorf("probed %v before the lifecycle backoff", got)
+	}
+	if got := f.run(time.Hour); len(got) != 1 {
+		t.Errorf("probed %v, want the quarantined node", got)
+	}
+}
+
+func TestSchedulerMeasuredState(t *testing.T) {
+	options := Options{
+		ActiveInterval:     5 * time.Minute,
+		CandidateInterval:  30 * time.Minute,
+		QuarantineInterval: time.Hour,
+		SubscriptionRate:   1000,
+	}
+	f := newFakeScheduler(options,
+		&node.Node{ID: "new", Subscription: "a", State: node.StateNew},
+		&node.Node{ID: "healthy", Subscription: "b", State: node.StateHealthy},
+	)
+	// the measurement promotes the new node and quarantines the healthy one with a backoff
+	f.measure = func(n *node.Node) {
+		switch n.State {
+		case node.StateNew:
+			n.State = node.StateHealthy
+		case node.StateHealthy:
+			if n.ID == "healthy" {
+				n.State = node.StateQuarantined
+				n.NextCheck = f.clock.Add(3 * time.Hour)
+			}
+		}
+	}
+
+	if got := f.run(0); len(got) != 2 {
+		t.Fatalf("probed %v, want both nodes", got)
+	}
+	if got := f.run(5 * time.Minute); !slices.Equal(got, []string{"new"}) {
+		t.Errorf("probed %v, want the promoted node at the active interval", got)
+	}
+	if got := f.run(55 * time.Minute); slices.Contains(got, "healthy") {
+		t.Errorf("probed %v before the quarantine backoff", got)
+	}
+	if got := f.run(2 * time.Hour); !slices.Contains(got, "healthy") {
+		t.Errorf("probed %v, want the quarantined node after the backoff", got)
+	}
+}
+
+func TestSchedulerSubscriptionRate(t *testing.T) {
+	f := newFakeScheduler(Options{SubscriptionRate: 2},
+		&node.Node{ID: "a1", Subscription: "a"},
+		&node.Node{ID: "a2", Subscription: "a"},
+		&node.Node{ID: "a3", Subscription: "a"},
+		&node.Node{ID: "b1", Subscription: "b"},
+	)
+
+	if got := f.run(0); len(got) != 2 || !slices.Contains(got, "b1") {
+		t.Errorf("probed %v, want one node of each subscription", got)
+	}
+	if got := f.run(10 * time.Second); len(got) > 0 {
+		t.Errorf("probed %v within the subscription rate", got)
+	}
+	if got := f.run(20 * time.Second); len(got) != 1 {
+		t.Errorf("probed %v, want one more node of subscription a", got)
+	}
+}
+
+func TestSchedulerSubscriptionRateAboveTick(t *testing.T) {
+	// 12 per minute is one probe every 5s, two per 10s tick
+	f := newFakeScheduler(Options{SubscriptionRate: 12, Tick: 10 * time.Second},
+		&node.Node{ID: "a1", Subscription: "a"},
+		&node.Node{ID: "a2", Subscription: "a"},
+		&node.Node{ID: "a3", Subscription: "a"},
+		&node.Node{ID: "a4", Subscription: "a"},
+		&node.Node{ID: "a5", Subscription: "a"},
+	)
+
+	if got := f.run(0); len(got) != 2 {
+		t.Errorf("probed %v, want two nodes in the first tick", got)
+	}
+	if got := f.run(10 * time.Second); len(got) != 2 {
+		t.Errorf("probed %v, want two nodes in the second tick", got)
+	}
+	want := []time.Duration{5 * time.Second, 5 * time.Second}
+	if !slices.Equal(f.delays, want) {
+		t.Errorf("delays %v, want %v", f.delays, want)
+	}
+}
+
+func TestSchedulerBudget(t *testing.T) {
+	var nodes []*node.Node
+	for _, id := range []string{"a", "b", "c", "d"} {
+		nodes = append(nodes, &node.Node{ID: id, Subscription: id})
+	}
+	f := newFakeScheduler(Options{Budget: 3, CandidateInterval: 24 * time.Hour}, nodes...)
+
+	if got := f.run(0); len(got) != 3 {
+		t.Errorf("probed %v, want 3 within budget", got)
+	}
+	if got := f.run(30 * time.Minute); len(got) != 0 {
+		t.Errorf("probed %v after the budget is spent", got)
+	}
+	if got := f.run(31 * time.Minute); len(got) != 1 {
+		t.Errorf("probed %v, want the last node once the hour has passed", got)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	f := newFakeScheduler(Options{ActiveInterval: 10 * time.Minute, Jitter: 0.2})
+	n := &node.Node{ID: "a", State: node.StateHealthy}
+	last := f.clock
+
+	for _, test := range []struct {
+		random float64
+		want   time.Duration
+	}{
+		{0, 8 * time.Minute},
+		{0.5, 10 * time.Minute},
+		{1, 12 * time.Minute},
+	} {
+		f.random = func() float64 { return test.random }
+		if got := f.schedule(n, last).Sub(last); got != test.want {
+			t.Errorf("random %v: interval %v, want %v", test.random, got, test.want)
+		}
+	}
+}
+
+func TestSchedulerRunAfterStop(t *testing.T) {
+	f := newFakeScheduler(Options{}, &node.Node{ID: "a", Subscription: "a"})
+
+	f.Stop()
+	done := make(chan struct{})
+	go func() {
+		f.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run after Stop did not return")
+	}
+	if len(f.probed) != 0 {
+		t.Errorf("Run after Stop probed %v", f.probed)
+	}
+}
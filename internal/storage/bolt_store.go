@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/json"
 	"log"
 	"sync"
@@ -68,3 +69,53 @@ func Get[T any](key string) (T, error) {
 
 	return val, err
 }
+
+// Modify reads the value of key, lets fn change it and writes it back in one
+// transaction, so writes made between the read and the write are not lost. fn
+// gets the zero value when key is missing and returns false to write nothing.
+func Modify[T any](key string, fn func(val T) (T, bool)) error {
+	return vxrayDb.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(BucketNameVxray))
+		var val T
+		if data := b.Get([]byte(key)); data != nil {
+			if err := json.Unmarshal(data, &val); err != nil {
+				return err
+			}
+		}
+		val, ok := fn(val)
+		if !ok {
+			return nil
+		}
+		data, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+func Delete(key string) error {
+	return vxrayDb.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(BucketNameVxray))
+		return b.Delete([]byte(key))
+	})
+}
+
+// List returns all values whose key starts with prefix, in key order.
+func List[T any](prefix string) ([]T, error) {
+	var vals []T
+	err := vxrayDb.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte(BucketNameVxray)).Cursor()
+		p := []byte(prefix)
+		for k, data := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, data = c.Next() {
+			var val T
+			if err := json.Unmarshal(data, &val); err != nil {
+				return err
+			}
+			vals = append(vals, val)
+		}
+		return nil
+	})
+
+	return vals, err
+}
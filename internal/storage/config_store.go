@@ -0,0 +1,16 @@
+package storage
+
+import "zhouxin.learn/go/vxrayui/internal/types"
+
+const StorageKeyConfig = "subscription.config."
+
+// ConfigStore 按来源保存拉取到的订阅内容
+type ConfigStore struct{}
+
+func (ConfigStore) StoreConfig(cfg *types.ConfigMetadata) error {
+	return Set(StorageKeyConfig+cfg.ID, cfg)
+}
+
+func (ConfigStore) GetConfig(id string) (*types.ConfigMetadata, error) {
+	return Get[*types.ConfigMetadata](StorageKeyConfig + id)
+}
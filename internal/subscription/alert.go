@@ -0,0 +1,184 @@
+package subscription
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+)
+
+// 配额告警的类型
+const (
+	WarningUsage   = "usage"
+	WarningExpire  = "expire"
+	WarningExpired = "expired"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// Warning 是订阅流量或到期的告警
+type Warning struct {
+	Subscription string `json:"subscription"`
+	Kind         string `json:"kind"`
+	// 达到的用量阈值，仅 usage 类型
+	Threshold float64   `json:"threshold,omitempty"`
+	Used      int64     `json:"used"`
+	Total     int64     `json:"total"`
+	Expire    time.Time `json:"expire,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// AlertSettings 是配额告警和历史的设置
+type AlertSettings struct {
+	UsageThresholds []float64
+	ExpireWithin    time.Duration
+	History         int
+	Webhook         string
+}
+
+var DefaultAlertSettings = AlertSettings{
+	UsageThresholds: []float64{0.8, 0.9, 1},
+	ExpireWithin:    3 * 24 * time.Hour,
+	History:         100,
+}
+
+var (
+	quotaInitOnce sync.Once
+	alertSettings = DefaultAlertSettings
+)
+
+// InitQuota 读取配额告警配置，未配置的字段使用默认值
+func InitQuota() {
+	quotaInitOnce.Do(func() {
+		cfg := config.GetQuota()
+		if cfg == nil {
+			return
+		}
+		if len(cfg.UsageThresholds) > 0 {
+			alertSettings.UsageThresholds = slices.Sorted(slices.Values(cfg.UsageThresholds))
+		}
+		if cfg.ExpireWithin > 0 {
+			alertSettings.ExpireWithin = cfg.ExpireWithin
+		}
+		if cfg.History > 0 {
+			alertSettings.History = cfg.History
+		}
+		alertSettings.Webhook = cfg.Webhook
+	})
+}
+
+// Warnings 返回配额当前的告警：达到的最高用量阈值，以及已过期或即将到期
+func (quota *Quota) Warnings(now time.Time) []Warning {
+	return quota.warnings(alertSettings, now)
+}
+
+func (quota *Quota) warnings(settings AlertSettings, now time.Time) []Warning {
+	// 告警会发到事件流和 webhook，未命名订阅的 URL 需要脱敏
+	name := RedactName(quota.Subscription)
+	var warnings []Warning
+	if quota.Total > 0 {
+		usage := float64(quota.Used()) / float64(quota.Total)
+		var reached float64
+		for _, threshold := range settings.UsageThresholds {
+			if usage >= threshold {
+				reached = threshold
+			}
+		}
+		if reached > 0 {
+			warnings = append(warnings, Warning{
+				Kind:      WarningUsage,
+				Threshold: reached,
+				Message:   fmt.Sprintf("subscription %s used %.0f%% of its traffic", name, usage*100),
+			})
+		}
+	}
+	if !quota.Expire.IsZero() {
+		if remaining := quota.Expire.Sub(now); remaining <= 0 {
+			warnings = append(warnings, Warning{
+				Kind:    WarningExpired,
+				Message: fmt.Sprintf("subscription %s expired at %s", name, quota.Expire.Format(time.DateTime)),
+			})
+		} else if remaining <= settings.ExpireWithin {
+			warnings = append(warnings, Warning{
+				Kind:    WarningExpire,
+				Message: fmt.Sprintf("subscription %s expires in %s", name, remaining.Round(time.Minute)),
+			})
+		}
+	}
+
+	for i := range warnings {
+		warnings[i].Subscription = name
+		warnings[i].Used = quota.Used()
+		warnings[i].Total = quota.Total
+		warnings[i].Expire = quota.Expire
+	}
+	return warnings
+}
+
+// newWarnings 返回 current 中上一次配额 previous 没有的告警，即新越过的阈值；
+// 续费后用量下降或到期时间延后，再次越过时会重新告警
+func newWarnings(previous, current *Quota, settings AlertSettings, now time.Time) []Warning {
+	warnings := current.warnings(settings, now)
+	if previous == nil {
+		return warnings
+	}
+	// previous 按它被记录的时间判断，避免同一次临近到期反复告警
+	seen := previous.warnings(settings, previous.UpdatedAt)
+	return slices.DeleteFunc(warnings, func(warning Warning) bool {
+		return slices.ContainsFunc(seen, func(old Warning) bool {
+			if old.Kind != warning.Kind {
+				return false
+			}
+			if warning.Kind == WarningUsage {
+				return old.Threshold >= warning.Threshold
+			}
+			return old.Expire.Equal(warning.Expire)
+		})
+	})
+}
+
+// alert 记录告警日志并发布 subscription.quota 事件，配置了 webhook 时逐条发送
+func alert(warnings []Warning, webhook string) {
+	for _, warning := range warnings {
+		logger.Warn("Subscription quota warning", "subscription", warning.Subscription, "kind", warning.Kind, "message", warning.Message)
+		event.Publish(event.SubscriptionQuota, &event.Quota{
+			Subscription: warning.Subscription,
+			Kind:         warning.Kind,
+			Threshold:    warning.Threshold,
+			Used:         warning.Used,
+			Total:        warning.Total,
+			Expire:       warning.Expire,
+			Message:      warning.Message,
+		})
+		if len(webhook) == 0 {
+			continue
+		}
+		if err := postWarning(webhook, warning); err != nil {
+			logger.Error("Failed to send subscription quota warning", "subscription", warning.Subscription, "err", err.Error())
+		}
+	}
+}
+
+func postWarning(webhook string, warning Warning) error {
+	data, err := json.Marshal(warning)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
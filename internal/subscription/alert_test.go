@@ -0,0 +1,84 @@
+package subscription
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewWarnings(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	settings := AlertSettings{UsageThresholds: []float64{0.8, 0.9, 1}, ExpireWithin: 72 * time.Hour}
+	quota := func(used, total int64, expire time.Time, updatedAt time.Time) *Quota {
+		return &Quota{Subscription: "paid", Download: used, Total: total, Expire: expire, UpdatedAt: updatedAt}
+	}
+
+	tests := []struct {
+		name     string
+		previous *Quota
+		current  *Quota
+		want     []string
+	}{
+		{"first record over a threshold", nil, quota(85, 100, time.Time{}, now), []string{WarningUsage}},
+		{"below every threshold", quota(10, 100, time.Time{}, now), quota(50, 100, time.Time{}, now), nil},
+		{"same threshold again", quota(82, 100, time.Time{}, now), quota(88, 100, time.Time{}, now), nil},
+		{"next threshold crossed", quota(85, 100, time.Time{}, now), quota(95, 100, time.Time{}, now), []string{WarningUsage}},
+		{"renewed then crossed again", quota(5, 200, time.Time{}, now), quota(170, 200, time.Time{}, now), []string{WarningUsage}},
+		{"unlimited traffic", nil, quota(500, 0, time.Time{}, now), nil},
+		{"expiry enters the window", quota(0, 100, now.Add(96*time.Hour), now.Add(-48*time.Hour)), quota(0, 100, now.Add(96*time.Hour), now.Add(24*time.Hour)), []string{WarningExpire}},
+		{"expiry already warned", quota(0, 100, now.Add(48*time.Hour), now.Add(-time.Hour)), quota(0, 100, now.Add(48*time.Hour), now), nil},
+		{"expired", quota(0, 100, now.Add(time.Hour), now), quota(0, 100, now.Add(time.Hour), now.Add(2*time.Hour)), []string{WarningExpired}},
+		{"extended expiry warns again", quota(0, 100, now.Add(48*time.Hour), now), quota(0, 100, now.Add(60*time.Hour), now), []string{WarningExpire}},
+	}
+	for _, test := range tests {
+		warnings := newWarnings(test.previous, test.current, settings, test.current.UpdatedAt)
+		var kinds []string
+		for _, warning := range warnings {
+			kinds = append(kinds, warning.Kind)
+		}
+		if len(kinds) != len(test.want) {
+			t.Errorf("%s: warnings = %v, want %v", test.name, kinds, test.want)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != test.want[i] {
+				t.Errorf("%s: warnings = %v, want %v", test.name, kinds, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestWarningThreshold(t *testing.T) {
+	settings := AlertSettings{UsageThresholds: []float64{0.8, 0.9, 1}}
+	quota := &Quota{Subscription: "paid", Upload: 40, Download: 60, Total: 100}
+	warnings := quota.warnings(settings, time.Now())
+	if len(warnings) != 1 || warnings[0].Threshold != 1 || warnings[0].Used != 100 {
+		t.Errorf("warnings = %+v, want one at threshold 1", warnings)
+	}
+}
+
+func TestAlertWebhook(t *testing.T) {
+	received := make(chan Warning, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var warning Warning
+		if err := json.NewDecoder(r.Body).Decode(&warning); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- warning
+	}))
+	defer server.Close()
+
+	alert([]Warning{{Subscription: "paid", Kind: WarningExpire, Message: "expires soon"}}, server.URL)
+	select {
+	case warning := <-received:
+		if warning.Subscription != "paid" || warning.Kind != WarningExpire {
+			t.Errorf("webhook received %+v", warning)
+		}
+	default:
+		t.Error("webhook not called")
+	}
+}
@@ -0,0 +1,163 @@
+package subscription
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/storage"
+	"zhouxin.learn/go/vxrayui/pkg/random"
+)
+
+const StorageKeyBandit = "subscription.bandit."
+
+// 每次更新前旧证据乘以该折扣，约保留最近 50 个结果，订阅质量变化后后验能跟上
+const banditDiscount = 0.98
+
+// 并发的测量结果不能丢失更新
+var banditMutex sync.Mutex
+
+// Arm 是订阅在 Thompson 采样中的 Beta 后验，先验为 Beta(1, 1)。
+// 订阅的新节点首次测得可用记一次成功，被隔离或退役、以及拉取失败记一次失败
+type Arm struct {
+	Subscription string    `json:"subscription"`
+	Name         string    `json:"name"`
+	Alpha        float64   `json:"alpha"`
+	Beta         float64   `json:"beta"`
+	Pulls        int       `json:"pulls"`
+	Successes    int       `json:"successes"`
+	Failures     int       `json:"failures"`
+	LastPulled   time.Time `json:"last_pulled,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
+
+// Posterior 是订阅后验的摘要
+type Posterior struct {
+	*Arm
+	Mean float64 `json:"mean"`
+	// 均值的约 95% 区间
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+func newArm(subscription *config.Subscription) *Arm {
+	return &Arm{Subscription: subscription.Url, Name: subscription.Name, Alpha: 1, Beta: 1}
+}
+
+// Posterior 返回后验的均值和区间
+func (arm *Arm) Posterior() *Posterior {
+	n := arm.Alpha + arm.Beta
+	mean := arm.Alpha / n
+	deviation := math.Sqrt(arm.Alpha * arm.Beta / (n * n * (n + 1)))
+	return &Posterior{
+		Arm:  arm,
+		Mean: mean,
+		Low:  math.Max(0, mean-1.96*deviation),
+		High: math.Min(1, mean+1.96*deviation),
+	}
+}
+
+// update 折扣旧证据后记录一次结果
+func (arm *Arm) update(success bool, now time.Time) {
+	arm.Alpha = 1 + (arm.Alpha-1)*banditDiscount
+	arm.Beta = 1 + (arm.Beta-1)*banditDiscount
+	if success {
+		arm.Alpha++
+		arm.Successes++
+	} else {
+		arm.Beta++
+		arm.Failures++
+	}
+	arm.UpdatedAt = now
+}
+
+// pickArm 对每个后验采样一次，返回采样值最大的下标；没有数据的订阅采样分布最宽，自然得到探索
+func pickArm(arms []*Arm, sample func(alpha, beta float64) float64) int {
+	best, bestValue := 0, -1.0
+	for i, arm := range arms {
+		if value := sample(arm.Alpha, arm.Beta); value > bestValue {
+			best, bestValue = i, value
+		}
+	}
+	return best
+}
+
+// getArm 返回订阅的后验，没有记录时返回先验
+func getArm(subscription *config.Subscription) (*Arm, error) {
+	arm, err := storage.Get[*Arm](StorageKeyBandit + subscription.Url)
+	if err != nil || arm == nil {
+		return newArm(subscription), err
+	}
+	arm.Name = subscription.Name
+	return arm, nil
+}
+
+func saveArm(arm *Arm) error {
+	return storage.Set(StorageKeyBandit+arm.Subscription, arm)
+}
+
+// pickBandit 用 Thompson 采样从候选订阅中选择一个并记录拉取次数
+func pickBandit(subs []*config.Subscription) *config.Subscription {
+	banditMutex.Lock()
+	defer banditMutex.Unlock()
+
+	arms := make([]*Arm, len(subs))
+	for i, sub := range subs {
+		arm, err := getArm(sub)
+		if err != nil {
+			logger.Error("Failed to get subscription arm", "url", sub.Url, "err", err.Error())
+		}
+		arms[i] = arm
+	}
+
+	i := pickArm(arms, random.Beta)
+	arms[i].Pulls++
+	arms[i].LastPulled = time.Now()
+	if err := saveArm(arms[i]); err != nil {
+		logger.Error("Failed to save subscription arm", "url", subs[i].Url, "err", err.Error())
+	}
+	logger.Debug("Picked subscription by bandit", "url", subs[i].Url, "alpha", arms[i].Alpha, "beta", arms[i].Beta)
+	return subs[i]
+}
+
+// Reward 记录订阅 url 的一次结果，未配置的订阅忽略
+func Reward(url string, success bool) {
+	var subscription *config.Subscription
+	for _, sub := range config.GetSubscriptions() {
+		if sub.Url == url {
+			subscription = sub
+			break
+		}
+	}
+	if subscription == nil {
+		return
+	}
+
+	banditMutex.Lock()
+	defer banditMutex.Unlock()
+
+	arm, err := getArm(subscription)
+	if err != nil {
+		logger.Error("Failed to get subscription arm", "url", url, "err", err.Error())
+		return
+	}
+	arm.update(success, time.Now())
+	if err := saveArm(arm); err != nil {
+		logger.Error("Failed to save subscription arm", "url", url, "err", err.Error())
+	}
+}
+
+// Posteriors 返回所有配置订阅当前的后验，没有数据的订阅为先验
+func Posteriors() ([]*Posterior, error) {
+	var posteriors []*Posterior
+	for _, sub := range config.GetSubscriptions() {
+		arm, err := getArm(sub)
+		if err != nil {
+			return nil, err
+		}
+		posteriors = append(posteriors, arm.Posterior())
+	}
+	return posteriors, nil
+}
@@ -0,0 +1,57 @@
+package subscription
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/pkg/random"
+)
+
+func TestPickArm(t *testing.T) {
+	arms := []*Arm{
+		{Subscription: "weak", Alpha: 2, Beta: 30},
+		{Subscription: "strong", Alpha: 30, Beta: 5},
+		{Subscription: "new", Alpha: 1, Beta: 1},
+	}
+
+	mean := func(alpha, beta float64) float64 { return alpha / (alpha + beta) }
+	if got := pickArm(arms, mean); got != 1 {
+		t.Errorf("greedy pick = %s, want strong", arms[got].Subscription)
+	}
+
+	picks := make([]int, len(arms))
+	for range 5000 {
+		picks[pickArm(arms, random.Beta)]++
+	}
+	// 产出高的订阅被利用最多，没有数据的订阅仍被探索，产出低的订阅很少被选
+	if picks[1] < 3500 || picks[2] < 200 || picks[0] > picks[2] {
+		t.Errorf("picks weak/strong/new = %v", picks)
+	}
+}
+
+func TestArmUpdate(t *testing.T) {
+	arm := &Arm{Subscription: "paid", Alpha: 1, Beta: 1}
+	now := time.Now()
+	for range 20 {
+		arm.update(true, now)
+	}
+	if arm.Successes != 20 || arm.Posterior().Mean < 0.9 {
+		t.Fatalf("after 20 successes: %+v, mean %.2f", arm, arm.Posterior().Mean)
+	}
+
+	// 订阅失效后旧的成功被折扣，后验均值跟随新结果下降
+	for range 60 {
+		arm.update(false, now)
+	}
+	posterior := arm.Posterior()
+	if posterior.Mean > 0.3 {
+		t.Errorf("after 60 failures mean = %.2f, want <= 0.3", posterior.Mean)
+	}
+	if posterior.Low < 0 || posterior.High > 1 || posterior.Low > posterior.Mean || posterior.High < posterior.Mean {
+		t.Errorf("interval = [%.2f, %.2f], mean %.2f", posterior.Low, posterior.High, posterior.Mean)
+	}
+	if total := arm.Alpha + arm.Beta; total > 2+1/(1-banditDiscount)+1e-9 || math.IsNaN(total) {
+		t.Errorf("evidence %.1f exceeds the discounted bound", total)
+	}
+}
@@ -0,0 +1,101 @@
+package subscription
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/event"
+)
+
+// findSubscription 返回 url 对应的配置订阅，未配置时返回只有 Url 的订阅
+func findSubscription(url string) *config.Subscription {
+	for _, sub := range config.GetSubscriptions() {
+		if sub.Url == url {
+			return sub
+		}
+	}
+	return &config.Subscription{Url: url}
+}
+
+func contentHash(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// RedactURL 去掉 URL 中的用户信息、查询参数和片段，它们常带有订阅 token；无法解析时返回空字符串
+func RedactURL(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// SubscriptionID 由原始 URL 得出订阅的标识，不暴露 URL 中的 token
+func SubscriptionID(rawUrl string) string {
+	return contentHash([]byte(rawUrl))[:16]
+}
+
+// RedactName 脱敏订阅的显示名，未命名的订阅以 URL 作为名称
+func RedactName(name string) string {
+	if strings.Contains(name, "://") {
+		return RedactURL(name)
+	}
+	return name
+}
+
+// redactError 返回去掉订阅 URL 凭据的错误信息，http 请求错误（*url.Error）中的地址可能是重定向后的 URL
+func redactError(subscription *config.Subscription, err error) string {
+	message := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		redacted := *urlErr
+		redacted.URL = RedactURL(urlErr.URL)
+		message = strings.ReplaceAll(message, urlErr.Error(), redacted.Error())
+	}
+	return strings.ReplaceAll(message, subscription.Url, RedactURL(subscription.Url))
+}
+
+// publishFetched 发布 subscription.fetched，内容变化时再发布 subscription.changed；nodes 为解析出的节点数，
+// 轮询器在内容未变化时沿用上次解析的节点数。事件中的 URL 已脱敏
+func publishFetched(subscription *config.Subscription, hash string, nodes int, changed bool) {
+	data := &event.Subscription{
+		ID:    SubscriptionID(subscription.Url),
+		Name:  subscription.Name,
+		URL:   RedactURL(subscription.Url),
+		Hash:  hash,
+		Nodes: nodes,
+	}
+	event.Publish(event.SubscriptionFetched, data)
+	if changed {
+		event.Publish(event.SubscriptionChanged, data)
+	}
+}
+
+// publishFailed 发布 subscription.failed，URL 和错误信息已脱敏
+func publishFailed(subscription *config.Subscription, err error) {
+	event.Publish(event.SubscriptionFailed, &event.Subscription{
+		ID:    SubscriptionID(subscription.Url),
+		Name:  subscription.Name,
+		URL:   RedactURL(subscription.Url),
+		Error: redactError(subscription, err),
+	})
+}
+
+// changed 记录订阅内容的 hash，返回与本进程上次解析相比是否变化，首次解析不算变化
+func (p *SubscriptionParser) changed(url, hash string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hashes == nil {
+		p.hashes = make(map[string]string)
+	}
+	previous, ok := p.hashes[url]
+	p.hashes[url] = hash
+	return ok && previous != hash
+}
@@ -0,0 +1,124 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+)
+
+// 订阅的拉取方式
+const (
+	RouteDirect = "direct"
+	RouteProxy  = "proxy"
+	RouteNode   = "node"
+)
+
+const fetchTimeout = 30 * time.Second
+
+// 未配置拉取方式时先直连，失败后通过节点
+var defaultRoutes = []string{RouteDirect, RouteNode}
+
+// NodeClient 返回经由当前最优健康节点的 HTTP 客户端，用完后调用 release 释放节点实例
+type NodeClient func() (client *http.Client, release func(), err error)
+
+// SetNodeClient 设置 node 拉取方式使用的客户端，未设置时跳过该方式
+func (p *SubscriptionParser) SetNodeClient(nodeClient NodeClient) {
+	p.nodeClient = nodeClient
+}
+
+// Fetch 按订阅配置的来源和拉取方式获取 url 的内容，返回各份内容、合并后的 sha256 和响应头，
+// 目录和 git 来源的各文件按文件名顺序分别返回；失败时仍返回最后一个响应头，用于读取 Retry-After
+func (p *SubscriptionParser) Fetch(ctx context.Context, url string) ([][]byte, string, http.Header, error) {
+	subscription := findSubscription(url)
+	contents, header, err := p.load(ctx, subscription)
+	if err != nil {
+		return nil, "", header, err
+	}
+	recordQuota(subscription, header)
+	return contents, contentHash(joinContents(contents)), header, nil
+}
+
+// fetch 依次尝试订阅的拉取方式，返回第一个成功的内容和响应头；
+// 全部失败时返回最后一个收到的响应头
+func (p *SubscriptionParser) fetch(ctx context.Context, subscription *config.Subscription) ([]byte, http.Header, error) {
+	routes := subscription.Fetch
+	if len(routes) == 0 {
+		routes = defaultRoutes
+	}
+
+	var errs []error
+	var lastHeader http.Header
+	for _, route := range routes {
+		data, header, err := p.fetchVia(ctx, route, subscription)
+		if err == nil {
+			logger.Debug("Fetched subscription", "url", subscription.Url, "route", route, "size", len(data))
+			return data, header, nil
+		}
+		logger.Debug("Failed to fetch subscription", "url", subscription.Url, "route", route, "err", err.Error())
+		errs = append(errs, fmt.Errorf("%s: %w", route, err))
+		if header != nil {
+			lastHeader = header
+		}
+		// 已取消时不再尝试其它方式
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastHeader, errors.Join(errs...)
+}
+
+func (p *SubscriptionParser) fetchVia(ctx context.Context, route string, subscription *config.Subscription) ([]byte, http.Header, error) {
+	switch route {
+	case RouteDirect:
+		return get(ctx, &http.Client{Timeout: fetchTimeout}, subscription)
+	case RouteProxy:
+		if len(subscription.Proxy) == 0 {
+			return nil, nil, fmt.Errorf("no proxy configured")
+		}
+		proxyUrl, err := url.Parse(subscription.Proxy)
+		if err != nil {
+			return nil, nil, err
+		}
+		client := &http.Client{
+			Timeout:   fetchTimeout,
+			Transport: &http.Transport{Proxy: http.ProxyURL(proxyUrl)},
+		}
+		return get(ctx, client, subscription)
+	case RouteNode:
+		if p.nodeClient == nil {
+			return nil, nil, fmt.Errorf("no node client")
+		}
+		client, release, err := p.nodeClient()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer release()
+		return get(ctx, client, subscription)
+	}
+	return nil, nil, fmt.Errorf("unknown fetch route: %s", route)
+}
+
+func get(ctx context.Context, client *http.Client, subscription *config.Subscription) ([]byte, http.Header, error) {
+	req, err := newRequest(ctx, subscription)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.Header, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, resp.Header, err
+}
@@ -0,0 +1,133 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+)
+
+const fetchContent = "trojan://secret@example.com:443#node"
+
+func contentServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fetchContent))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// closedUrl is a url nothing listens on, like a blocked subscription host
+func closedUrl() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL + "/sub"
+}
+
+func TestFetchDirect(t *testing.T) {
+	server := contentServer(t)
+	data, _, err := NewSubscriptionParser().fetch(context.Background(), &config.Subscription{Url: server.URL, Fetch: []string{RouteDirect}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != fetchContent {
+		t.Errorf("content = %q", data)
+	}
+}
+
+func TestFetchFallbackToProxy(t *testing.T) {
+	// a forward proxy answers absolute-form requests for the blocked host itself
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte(fetchContent))
+	}))
+	defer proxy.Close()
+
+	url := closedUrl()
+	data, _, err := NewSubscriptionParser().fetch(context.Background(), &config.Subscription{
+		Url:   url,
+		Fetch: []string{RouteDirect, RouteProxy},
+		Proxy: proxy.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != fetchContent || proxied != url {
+		t.Errorf("content = %q through proxy for %q, want %q", data, proxied, url)
+	}
+}
+
+func TestFetchThroughNode(t *testing.T) {
+	server := contentServer(t)
+	released := false
+	parser := NewSubscriptionParser()
+	parser.SetNodeClient(func() (*http.Client, func(), error) {
+		return server.Client(), func() { released = true }, nil
+	})
+
+	data, _, err := parser.fetch(context.Background(), &config.Subscription{Url: server.URL, Fetch: []string{RouteNode}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != fetchContent || !released {
+		t.Errorf("content = %q, released = %v", data, released)
+	}
+}
+
+func TestFetchAllRoutesFail(t *testing.T) {
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	for _, subscription := range []*config.Subscription{
+		{Url: closedUrl()},
+		{Url: notFound.URL, Fetch: []string{RouteDirect, RouteProxy}},
+	} {
+		_, _, err := NewSubscriptionParser().fetch(context.Background(), subscription)
+		if err == nil {
+			t.Fatalf("fetch %s succeeded", subscription.Url)
+		}
+		// every route is reported, node has no client and proxy no upstream
+		routes := subscription.Fetch
+		if len(routes) == 0 {
+			routes = defaultRoutes
+		}
+		for _, route := range routes {
+			if !strings.Contains(err.Error(), route+":") {
+				t.Errorf("error %q does not report route %s", err, route)
+			}
+		}
+	}
+}
+
+func TestFetchCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		// the node route is never tried once the context is cancelled
+		_, _, err := NewSubscriptionParser().fetch(ctx, &config.Subscription{Url: server.URL, Fetch: []string{RouteDirect, RouteNode}})
+		errs <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "node client") {
+			t.Errorf("err = %v, want only the cancelled direct fetch", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetch did not stop after cancel")
+	}
+}
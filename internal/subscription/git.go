@@ -0,0 +1,254 @@
+package subscription
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+)
+
+const (
+	gitScheme         = "git+"
+	gitTimeout        = 2 * time.Minute
+	defaultGitHistory = 20
+)
+
+// 同一仓库的克隆、拉取和读取不能并发
+var gitMutex sync.Mutex
+
+// gitDir 返回 git 订阅克隆的存放目录
+var gitDir = func() string {
+	cfg := config.GetStorage()
+	if len(cfg.GitDir) > 0 {
+		return cfg.GitDir
+	}
+	return filepath.Join(filepath.Dir(cfg.Path), "git")
+}
+
+// gitRepo 是 git 订阅仓库的本地裸克隆，url 为去掉 git+ 前缀的仓库地址
+type gitRepo struct {
+	url string
+	dir string
+}
+
+// gitCommit 是修改过订阅文件的一次提交
+type gitCommit struct {
+	Hash string
+	Time time.Time
+}
+
+func openGitRepo(rawUrl string) *gitRepo {
+	url := strings.TrimPrefix(rawUrl, gitScheme)
+	return &gitRepo{
+		url: url,
+		dir: filepath.Join(gitDir(), fmt.Sprintf("%x", sha256.Sum256([]byte(url)))[:16]),
+	}
+}
+
+// git 在克隆目录中执行 git 命令，返回标准输出
+func (r *gitRepo) git(ctx context.Context, args ...string) ([]byte, error) {
+	return runGit(ctx, append([]string{"-C", r.dir}, args...)...)
+}
+
+func runGit(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", gitSubcommand(args), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// gitSubcommand 返回参数中的子命令，跳过 -C 和它的目录
+func gitSubcommand(args []string) string {
+	if len(args) > 2 && args[0] == "-C" {
+		return args[2]
+	}
+	return args[0]
+}
+
+// sync 首次使用时克隆仓库，之后拉取所有分支
+func (r *gitRepo) sync(ctx context.Context) error {
+	if _, err := os.Stat(r.dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(r.dir), 0700); err != nil {
+			return err
+		}
+		// -- 防止以 - 开头的地址被当作选项，例如 --upload-pack
+		_, err := runGit(ctx, "clone", "--bare", "--quiet", "--", r.url, r.dir)
+		return err
+	}
+	_, err := r.git(ctx, "fetch", "--quiet", "--prune", "origin", "+refs/heads/*:refs/heads/*")
+	return err
+}
+
+// log 返回 branch 上修改过 paths 的最近 n 次提交，从新到旧
+func (r *gitRepo) log(ctx context.Context, branch string, paths []string, n int) ([]gitCommit, error) {
+	// --end-of-options 防止以 - 开头的分支被当作选项，例如 --output
+	args := []string{"log", "--format=%H %ct", "-n", strconv.Itoa(n), "--end-of-options", branch, "--"}
+	output, err := r.git(ctx, append(args, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []gitCommit
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		hash, timestamp, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, gitCommit{Hash: hash, Time: time.Unix(seconds, 0)})
+	}
+	return commits, nil
+}
+
+// read 返回提交中各文件的内容，提交中不存在的文件跳过
+func (r *gitRepo) read(ctx context.Context, commit string, paths []string) [][]byte {
+	var contents [][]byte
+	for _, path := range paths {
+		data, err := r.git(ctx, "show", commit+":"+path)
+		if err != nil {
+			logger.Debug("Failed to read git subscription file", "repo", r.url, "commit", commit, "path", path, "err", err.Error())
+			continue
+		}
+		contents = append(contents, data)
+	}
+	return contents
+}
+
+// loadGit 同步 git 订阅仓库，返回最新提交中订阅文件的内容，并记录最近提交中节点的演变
+func loadGit(ctx context.Context, subscription *config.Subscription) ([][]byte, error) {
+	if len(subscription.Paths) == 0 {
+		return nil, fmt.Errorf("no paths in git subscription %s", subscription.Url)
+	}
+	branch := subscription.Branch
+	if len(branch) == 0 {
+		branch = "HEAD"
+	}
+	history := subscription.History
+	if history <= 0 {
+		history = defaultGitHistory
+	}
+
+	gitMutex.Lock()
+	defer gitMutex.Unlock()
+
+	repo := openGitRepo(subscription.Url)
+	if err := repo.sync(ctx); err != nil {
+		return nil, err
+	}
+	commits, err := repo.log(ctx, branch, subscription.Paths, history)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits touch %v in %s", subscription.Paths, repo.url)
+	}
+
+	contents := repo.read(ctx, commits[0].Hash, subscription.Paths)
+	if len(contents) == 0 {
+		return nil, fmt.Errorf("none of %v exists in %s", subscription.Paths, repo.url)
+	}
+	recordGitHistory(ctx, subscription, repo, commits)
+	return contents, nil
+}
+
+// recordGitHistory 统计节点在最近提交中的出现次数，最新提交未变化时跳过
+func recordGitHistory(ctx context.Context, subscription *config.Subscription, repo *gitRepo, commits []gitCommit) {
+	previous, err := node.GetSourceHistory(subscription.Url)
+	if err != nil {
+		logger.Error("Failed to get git subscription history", "url", subscription.Url, "err", err.Error())
+	}
+	if previous != nil && previous.Head == commits[0].Hash && len(previous.Revisions) == len(commits) {
+		return
+	}
+
+	history, persistence := analyzeHistory(commits, func(commit string) []string {
+		var ids []string
+		for _, content := range repo.read(ctx, commit, subscription.Paths) {
+			ids = append(ids, fingerprintContent(content, subscription.IsBase64)...)
+		}
+		return ids
+	})
+	history.Subscription = subscription.Url
+	for _, p := range persistence {
+		p.Subscription = subscription.Name
+		p.Source = subscription.Url
+	}
+	if err := node.SavePersistence(history, persistence); err != nil {
+		logger.Error("Failed to save git subscription history", "url", subscription.Url, "err", err.Error())
+		return
+	}
+	logger.Info("Recorded git subscription history", "url", subscription.Url, "commits", len(commits), "stability", history.Stability)
+}
+
+// analyzeHistory 按提交从旧到新比较节点集合，得到每次提交的增减和最新提交中各节点的出现次数；
+// fingerprints 返回一次提交中全部节点的指纹
+func analyzeHistory(commits []gitCommit, fingerprints func(commit string) []string) (*node.SourceHistory, []*node.Persistence) {
+	history := &node.SourceHistory{Head: commits[0].Hash, UpdatedAt: time.Now()}
+	seen := make(map[string]*node.Persistence)
+	var previous map[string]bool
+	for i := len(commits) - 1; i >= 0; i-- {
+		commit := commits[i]
+		current := make(map[string]bool)
+		for _, id := range fingerprints(commit.Hash) {
+			current[id] = true
+		}
+
+		revision := node.Revision{Commit: commit.Hash, Time: commit.Time, Nodes: len(current)}
+		for id := range current {
+			if !previous[id] {
+				revision.Added++
+			}
+			p, ok := seen[id]
+			if !ok {
+				p = &node.Persistence{ID: id, Commits: len(commits), Since: commit.Time}
+				seen[id] = p
+			}
+			p.Revisions++
+		}
+		for id := range previous {
+			if !current[id] {
+				revision.Removed++
+			}
+		}
+		history.Revisions = append([]node.Revision{revision}, history.Revisions...)
+		previous = current
+	}
+
+	// 只保留最新提交中仍存在的节点
+	var persistence []*node.Persistence
+	var total float64
+	for id := range previous {
+		persistence = append(persistence, seen[id])
+		total += seen[id].Score()
+	}
+	slices.SortFunc(persistence, func(a, b *node.Persistence) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(persistence) > 0 {
+		history.Stability = total / float64(len(persistence))
+	}
+	return history, persistence
+}
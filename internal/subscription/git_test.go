@@ -0,0 +1,132 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitFixture 创建一个本地裸仓库作为 git 订阅的远端，commit 向其推送一次提交
+type gitFixture struct {
+	t      *testing.T
+	remote string
+	work   string
+}
+
+func newGitFixture(t *testing.T) *gitFixture {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	fixture := &gitFixture{t: t, remote: filepath.Join(dir, "remote.git"), work: filepath.Join(dir, "work")}
+	fixture.run("", "init", "--bare", "--quiet", "--initial-branch=main", fixture.remote)
+	fixture.run("", "clone", "--quiet", fixture.remote, fixture.work)
+	return fixture
+}
+
+func (f *gitFixture) run(dir string, args ...string) {
+	f.t.Helper()
+	if len(dir) > 0 {
+		args = append([]string{"-C", dir}, args...)
+	}
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		f.t.Fatalf("git %v: %v: %s", args, err, output)
+	}
+}
+
+func (f *gitFixture) commit(files map[string]string) {
+	f.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(f.work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			f.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+	f.run(f.work, "add", "-A")
+	f.run(f.work, "commit", "--quiet", "-m", "update")
+	f.run(f.work, "push", "--quiet", "origin", "HEAD:main")
+}
+
+func TestGitRepo(t *testing.T) {
+	fixture := newGitFixture(t)
+	fixture.commit(map[string]string{"sub/vless.txt": "a\nb\n", "README.md": "feeds"})
+	fixture.commit(map[string]string{"README.md": "feeds, updated"})
+	fixture.commit(map[string]string{"sub/vless.txt": "a\nb\nc\n"})
+
+	cache := t.TempDir()
+	defaultGitDir := gitDir
+	gitDir = func() string { return cache }
+	t.Cleanup(func() { gitDir = defaultGitDir })
+	repo := openGitRepo("git+file://" + fixture.remote)
+	if err := repo.sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{"sub/vless.txt", "sub/missing.txt"}
+	commits, err := repo.log(context.Background(), "HEAD", paths, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("commits = %d, want 2 touching the subscription file", len(commits))
+	}
+	contents := repo.read(context.Background(), commits[0].Hash, paths)
+	if len(contents) != 1 || string(contents[0]) != "a\nb\nc\n" {
+		t.Errorf("head contents = %q", contents)
+	}
+
+	// 再次同步拉取新提交
+	fixture.commit(map[string]string{"sub/vless.txt": "c\nd\n"})
+	if err := repo.sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	commits, err = repo.log(context.Background(), "main", paths, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 3 {
+		t.Fatalf("commits after sync = %d, want 3", len(commits))
+	}
+
+	// 以 - 开头的分支不被当作选项，错误中是子命令
+	output := filepath.Join(t.TempDir(), "log")
+	if _, err := repo.log(context.Background(), "--output="+output, paths, 10); err == nil || !strings.HasPrefix(err.Error(), "git log:") {
+		t.Errorf("log of an option-like branch: %v", err)
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Error("branch was read as an option")
+	}
+
+	history, persistence := analyzeHistory(commits, func(commit string) []string {
+		var ids []string
+		for _, content := range repo.read(context.Background(), commit, paths) {
+			ids = append(ids, strings.Fields(string(content))...)
+		}
+		return ids
+	})
+
+	var changes []string
+	for _, revision := range history.Revisions {
+		changes = append(changes, fmt.Sprintf("%d/%d/%d", revision.Nodes, revision.Added, revision.Removed))
+	}
+	// 从新到旧：c d（+d -a -b），a b c（+c），a b（+a +b）
+	if got := strings.Join(changes, " "); got != "2/1/2 3/1/0 2/2/0" {
+		t.Errorf("revisions nodes/added/removed = %s", got)
+	}
+
+	if len(persistence) != 2 || persistence[0].ID != "c" || persistence[0].Revisions != 2 ||
+		persistence[1].ID != "d" || persistence[1].Revisions != 1 {
+		t.Fatalf("persistence of %d nodes is wrong", len(persistence))
+	}
+	if history.Head != commits[0].Hash || history.Stability != 0.5 {
+		t.Errorf("head = %s, stability = %v, want 0.5", history.Head, history.Stability)
+	}
+}
@@ -0,0 +1,195 @@
+package subscription
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/storage"
+)
+
+const StorageKeySource = "subscription.source."
+
+const (
+	defaultMinInterval = 5 * time.Minute
+	defaultMaxInterval = 24 * time.Hour
+	// 变化周期的指数滑动平均中新样本的权重
+	changePeriodWeight = 0.3
+)
+
+// sourceState 是需要跨重启保留的来源状态
+type sourceState struct {
+	Interval     time.Duration `json:"interval"`
+	ChangePeriod time.Duration `json:"change_period"`
+	Hash         string        `json:"hash"`
+	LastCheck    time.Time     `json:"last_check"`
+	LastChange   time.Time     `json:"last_change"`
+	NotBefore    time.Time     `json:"not_before"`
+	FailureCount int           `json:"failure_count"`
+	Nodes        int           `json:"nodes"`
+}
+
+func loadSourceState(url string) (*sourceState, error) {
+	return storage.Get[*sourceState](StorageKeySource + url)
+}
+
+func saveSourceState(url string, state *sourceState) error {
+	return storage.Set(StorageKeySource+url, state)
+}
+
+// apply 恢复上次保存的状态，没有记录时保持初始值
+func (source *SourceConfig) apply(state *sourceState) {
+	if state == nil {
+		return
+	}
+	source.Interval = state.Interval
+	source.ChangePeriod = state.ChangePeriod
+	source.Hash = state.Hash
+	source.LastCheck = state.LastCheck
+	source.LastChange = state.LastChange
+	source.NotBefore = state.NotBefore
+	source.FailureCount = state.FailureCount
+	source.Nodes = state.Nodes
+}
+
+func (source *SourceConfig) state() *sourceState {
+	return &sourceState{
+		Interval:     source.Interval,
+		ChangePeriod: source.ChangePeriod,
+		Hash:         source.Hash,
+		LastCheck:    source.LastCheck,
+		LastChange:   source.LastChange,
+		NotBefore:    source.NotBefore,
+		FailureCount: source.FailureCount,
+		Nodes:        source.Nodes,
+	}
+}
+
+// bounds 返回来源的最小和最大间隔，未配置时使用默认值
+func (source *SourceConfig) bounds() (time.Duration, time.Duration) {
+	minInterval, maxInterval := source.MinInterval, source.MaxInterval
+	if minInterval <= 0 {
+		minInterval = defaultMinInterval
+	}
+	if maxInterval < minInterval {
+		maxInterval = max(defaultMaxInterval, minInterval)
+	}
+	return minInterval, maxInterval
+}
+
+// observe 记录一次成功的拉取并重新计算间隔：
+// 内容变化的平均周期内拉取两次，价值高（0 到 1）的来源间隔缩短，价值低的延长，
+// Cache-Control 的 max-age 内不重复拉取，最后限制在 MinInterval 和 MaxInterval 之间
+func (source *SourceConfig) observe(now time.Time, hash string, header http.Header, value float64) (changed bool) {
+	minInterval, maxInterval := source.bounds()
+	changed = hash != source.Hash
+	switch {
+	case source.LastChange.IsZero():
+		// 首次拉取，还不知道变化周期
+		source.LastChange = now
+	case changed:
+		source.ChangePeriod = ewma(source.ChangePeriod, now.Sub(source.LastChange))
+		source.LastChange = now
+	default:
+		// 未变化的时间已超过估计的周期，周期至少有这么长
+		source.ChangePeriod = max(source.ChangePeriod, now.Sub(source.LastChange))
+	}
+	source.Hash = hash
+	source.LastCheck = now
+	source.FailureCount = 0
+
+	interval := minInterval
+	if source.ChangePeriod > 0 {
+		interval = source.ChangePeriod / 2
+	}
+	interval = time.Duration(float64(interval) * (1.5 - min(max(value, 0), 1)))
+	if maxAge, ok := cacheMaxAge(header); ok {
+		interval = max(interval, maxAge)
+	}
+	source.Interval = min(max(interval, minInterval), maxInterval)
+	source.NotBefore = retryAfter(header, now)
+	return changed
+}
+
+// observeFailure 记录一次失败的拉取，间隔按失败次数指数退避，Retry-After 优先
+func (source *SourceConfig) observeFailure(now time.Time, header http.Header) {
+	source.LastCheck = now
+	source.FailureCount++
+	source.NotBefore = retryAfter(header, now)
+}
+
+// nextCheck 返回下次拉取的时间
+func (source *SourceConfig) nextCheck() time.Time {
+	next := source.LastCheck.Add(source.calculateInterval())
+	if source.NotBefore.After(next) {
+		return source.NotBefore
+	}
+	return next
+}
+
+// calculateInterval 返回当前间隔：未拉取过为 0，失败后在学到的间隔上指数退避
+func (source *SourceConfig) calculateInterval() time.Duration {
+	if source.LastCheck.IsZero() {
+		return 0
+	}
+	minInterval, maxInterval := source.bounds()
+	interval := max(source.Interval, minInterval)
+	if source.FailureCount > 0 {
+		backoff := time.Duration(1<<min(source.FailureCount, 5)) * time.Minute
+		interval = min(maxInterval, interval+backoff)
+	}
+	return interval
+}
+
+func ewma(average, sample time.Duration) time.Duration {
+	if average <= 0 {
+		return sample
+	}
+	return time.Duration(changePeriodWeight*float64(sample) + (1-changePeriodWeight)*float64(average))
+}
+
+// cacheMaxAge 解析 Cache-Control 的 max-age，no-cache 和 no-store 视为没有
+func cacheMaxAge(header http.Header) (time.Duration, bool) {
+	var maxAge time.Duration
+	var found bool
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store":
+			return 0, false
+		case "max-age":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil || seconds <= 0 {
+				continue
+			}
+			maxAge, found = time.Duration(seconds)*time.Second, true
+		}
+	}
+	return maxAge, found
+}
+
+// retryAfter 解析 Retry-After 的秒数或 HTTP 日期，没有时返回零值
+func retryAfter(header http.Header, now time.Time) time.Time {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if len(value) == 0 {
+		return time.Time{}
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date
+	}
+	return time.Time{}
+}
+
+// sourceValue 返回来源的价值，即订阅选择后验的均值，没有配置或数据时为 0.5
+func sourceValue(url string) float64 {
+	arm, err := getArm(&config.Subscription{Url: url})
+	if err != nil {
+		return 0.5
+	}
+	return arm.Posterior().Mean
+}
@@ -0,0 +1,111 @@
+package subscription
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestObserveLearnsChangePeriod(t *testing.T) {
+	source := &SourceConfig{MinInterval: 10 * time.Minute, MaxInterval: 24 * time.Hour}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if !source.observe(now, "a", nil, 0.5) {
+		t.Fatal("first fetch is a change")
+	}
+	if source.Interval != 10*time.Minute {
+		t.Errorf("first interval = %v, want MinInterval", source.Interval)
+	}
+
+	// 内容每 4 小时变化一次，间隔收敛到 2 小时
+	for range 10 {
+		now = now.Add(4 * time.Hour)
+		source.observe(now, now.String(), nil, 0.5)
+	}
+	if source.ChangePeriod != 4*time.Hour || source.Interval != 2*time.Hour {
+		t.Errorf("change period = %v, interval = %v, want 4h and 2h", source.ChangePeriod, source.Interval)
+	}
+
+	// 长时间未变化，估计的周期随之延长
+	hash := source.Hash
+	now = now.Add(10 * time.Hour)
+	if source.observe(now, hash, nil, 0.5) {
+		t.Error("same hash reported as changed")
+	}
+	if source.ChangePeriod != 10*time.Hour || source.Interval != 5*time.Hour {
+		t.Errorf("unchanged: change period = %v, interval = %v, want 10h and 5h", source.ChangePeriod, source.Interval)
+	}
+
+	// 价值高的来源更频繁，价值低的更少
+	source.observe(now, hash, nil, 1)
+	if source.Interval != 5*time.Hour/2 {
+		t.Errorf("valuable interval = %v, want 2h30m", source.Interval)
+	}
+	source.observe(now, hash, nil, 0)
+	if source.Interval != 15*time.Hour/2 {
+		t.Errorf("worthless interval = %v, want 7h30m", source.Interval)
+	}
+}
+
+func TestObserveHeadersAndBounds(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := &SourceConfig{MinInterval: 10 * time.Minute, MaxInterval: 6 * time.Hour}
+
+	header := http.Header{"Cache-Control": {"public, max-age=3600"}}
+	source.observe(now, "a", header, 0.5)
+	if source.Interval != time.Hour {
+		t.Errorf("max-age interval = %v, want 1h", source.Interval)
+	}
+
+	header = http.Header{"Cache-Control": {"max-age=86400"}}
+	source.observe(now, "a", header, 0.5)
+	if source.Interval != 6*time.Hour {
+		t.Errorf("interval = %v, want MaxInterval 6h", source.Interval)
+	}
+
+	header = http.Header{"Cache-Control": {"no-cache, max-age=3600"}}
+	source.observe(now, "a", header, 0.5)
+	if source.Interval != 10*time.Minute {
+		t.Errorf("no-cache interval = %v, want MinInterval", source.Interval)
+	}
+
+	if next := source.nextCheck(); !next.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("next check = %v, want in 10m", next)
+	}
+}
+
+func TestObserveFailure(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := &SourceConfig{MinInterval: 10 * time.Minute, MaxInterval: time.Hour}
+	if source.calculateInterval() != 0 {
+		t.Error("a source never checked is due")
+	}
+
+	source.observeFailure(now, nil)
+	source.observeFailure(now, nil)
+	if got := source.nextCheck().Sub(now); got != 14*time.Minute {
+		t.Errorf("backoff after 2 failures = %v, want 14m", got)
+	}
+	for range 10 {
+		source.observeFailure(now, nil)
+	}
+	if got := source.nextCheck().Sub(now); got != 42*time.Minute {
+		t.Errorf("backoff = %v, want capped at 42m", got)
+	}
+
+	// Retry-After 优先，即使超过 MaxInterval
+	source.observeFailure(now, http.Header{"Retry-After": {"7200"}})
+	if got := source.nextCheck().Sub(now); got != 2*time.Hour {
+		t.Errorf("retry after seconds = %v, want 2h", got)
+	}
+	date := now.Add(3 * time.Hour)
+	source.observeFailure(now, http.Header{"Retry-After": {date.Format(http.TimeFormat)}})
+	if next := source.nextCheck(); !next.Equal(date) {
+		t.Errorf("retry after date = %v, want %v", next, date)
+	}
+
+	source.observe(now, "a", nil, 0.5)
+	if source.FailureCount != 0 || !source.NotBefore.IsZero() {
+		t.Errorf("success keeps failure state: %d, %v", source.FailureCount, source.NotBefore)
+	}
+}
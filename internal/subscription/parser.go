@@ -2,44 +2,133 @@ package subscription
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/base64"
 	"io"
-	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/xtls/xray-core/infra/conf"
 	"zhouxin.learn/go/vxrayui/config"
 	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/metrics"
 	"zhouxin.learn/go/vxrayui/internal/types"
 	"zhouxin.learn/go/vxrayui/pkg/counter"
 	"zhouxin.learn/go/vxrayui/pkg/xray"
 )
 
 // SubscriptionParser 用于解析订阅内容并生成 OutboundDetourConfig
-type SubscriptionParser struct{}
+type SubscriptionParser struct {
+	nodeClient NodeClient
+	// stdin 来源读取的输入
+	stdin io.Reader
+
+	mu sync.Mutex
+	// 各订阅上次解析的内容 hash，用于发布 subscription.changed
+	hashes map[string]string
+}
 
 // NewSubscriptionParser 创建一个新的 SubscriptionParser
 func NewSubscriptionParser() *SubscriptionParser {
-	return &SubscriptionParser{}
+	return &SubscriptionParser{stdin: os.Stdin}
 }
 
-// ParseSubscription 从订阅链接拉取内容并解析为 OutboundDetourConfig
+// ParseSubscription 读取订阅来源（远程、文件、目录、标准输入、git 仓库或网页）并解析为 OutboundDetourConfig
 func (p *SubscriptionParser) ParseSubscription(subscription *config.Subscription) []*conf.OutboundDetourConfig {
-	resp, err := http.Get(subscription.Url)
+	contents, header, err := p.load(context.Background(), subscription)
 	if err != nil {
-		logger.Error("Failed to fetch subscription", "err", err.Error())
+		logger.Error("Failed to fetch subscription", "url", subscription.Url, "err", err.Error())
+		publishFailed(subscription, err)
 		return nil
 	}
-	defer resp.Body.Close()
+	recordQuota(subscription, header)
+
+	outbounds := parseContents(subscription, contents)
+	hash := contentHash(joinContents(contents))
+	publishFetched(subscription, hash, len(outbounds), p.changed(subscription.Url, hash))
+	return outbounds
+}
+
+// Parse 解析 Fetch 返回的各份内容，与 ParseSubscription 一样逐份解析
+func (p *SubscriptionParser) Parse(subscription *config.Subscription, contents [][]byte) []*conf.OutboundDetourConfig {
+	return parseContents(subscription, contents)
+}
 
-	reader := decodeBody(resp.Body, subscription.IsBase64)
+// parseContents 按来源类型解析订阅的各份内容
+func parseContents(subscription *config.Subscription, contents [][]byte) []*conf.OutboundDetourConfig {
+	// 本次解析的计数，同时按订阅计入全局指标
+	stats := counter.Scope(counter.Labels{"subscription": MetricName(subscription)})
+	var outbounds []*conf.OutboundDetourConfig
+	for _, body := range contents {
+		if SourceType(subscription.Url) == SourceScrape {
+			outbounds = append(outbounds, parseSubscriptionContent(bytes.NewReader(body), stats)...)
+			continue
+		}
+		outbounds = append(outbounds, parseBody(body, subscription.IsBase64, stats)...)
+	}
+	return outbounds
+}
+
+// parseBody 解析一份订阅内容，解析失败计入 stats
+func parseBody(body []byte, isBase64 bool, stats *counter.Registry) []*conf.OutboundDetourConfig {
+	reader := decodeBody(bytes.NewReader(body), isBase64)
 	if reader == nil {
 		logger.Error("Failed to decode subscription body")
 		return nil
 	}
 
-	return parseSubscriptionContent(reader)
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		logger.Error("Failed to read subscription body", "err", err.Error())
+		return nil
+	}
+	if isDocumentContent(content) {
+		return parseDocumentContent(content, stats)
+	}
+	return parseSubscriptionContent(bytes.NewReader(content), stats)
+}
+
+// fingerprintContent 返回一份订阅内容中全部节点的指纹，历史内容的解析失败不计入指标
+func fingerprintContent(body []byte, isBase64 bool) []string {
+	var ids []string
+	for _, outbound := range parseBody(body, isBase64, counter.NewRegistry()) {
+		if id, err := xray.Fingerprint(*outbound); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// isDocumentContent 判断订阅内容是否为整体解析的配置：JSON（xray / sing-box）或 Clash YAML
+func isDocumentContent(content []byte) bool {
+	content = bytes.TrimSpace(content)
+	return bytes.HasPrefix(content, []byte("{")) ||
+		bytes.HasPrefix(content, []byte("proxies:")) || bytes.Contains(content, []byte("\nproxies:"))
+}
+
+// parseDocumentContent 解析 xray / sing-box JSON 配置中的代理出站
+func parseDocumentContent(content []byte, stats *counter.Registry) []*conf.OutboundDetourConfig {
+	xrayConfig, err := xray.ConvertShareLinksToXrayJson(string(content))
+	if err != nil {
+		logger.Error("Failed to parse subscription document", "err", err.Error())
+		stats.Add(metrics.SubscriptionParseErrors, 1, counter.Labels{"reason": "document"})
+		return nil
+	}
+
+	var outbounds []*conf.OutboundDetourConfig
+	for i := range xrayConfig.OutboundConfigs {
+		outbound := &xrayConfig.OutboundConfigs[i]
+		if !isProxyProtocol(outbound.Protocol) {
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+
+	logger.Info("Parsed outbounds from subscription document", "total", len(outbounds))
+	return outbounds
 }
 
 // decodeBody 根据是否 base64 解码返回正确的 reader
@@ -50,8 +139,10 @@ func decodeBody(body io.Reader, isBase64 bool) io.Reader {
 	return body
 }
 
-// parseSubscriptionContent 解析订阅内容
-func parseSubscriptionContent(reader io.Reader) []*conf.OutboundDetourConfig {
+// parseSubscriptionContent 解析订阅内容，无效的行计入 stats
+func parseSubscriptionContent(reader io.Reader, stats *counter.Registry) []*conf.OutboundDetourConfig {
+	// 仅统计这份内容
+	stats = stats.Scope(nil)
 	scanner := bufio.NewScanner(reader)
 	var outbounds []*conf.OutboundDetourConfig
 
@@ -63,7 +154,7 @@ func parseSubscriptionContent(reader io.Reader) []*conf.OutboundDetourConfig {
 
 		if !isValidLink(line) {
 			logger.Error("Unsupported subscription", "url", line)
-			counter.Incr("subscription.invalid", 1)
+			stats.Add(metrics.SubscriptionParseErrors, 1, counter.Labels{"reason": "unsupported"})
 			continue
 		}
 
@@ -73,7 +164,7 @@ func parseSubscriptionContent(reader io.Reader) []*conf.OutboundDetourConfig {
 		link, err := url.Parse(line)
 		if err != nil {
 			logger.Error("Invalid Url in subscription", "url", line, "err", err.Error())
-			counter.Incr("subscription.invalid", 1)
+			stats.Add(metrics.SubscriptionParseErrors, 1, counter.Labels{"reason": "invalid_url"})
 			continue
 		}
 		shareLink := xray.XrayShareLink{
@@ -83,7 +174,7 @@ func parseSubscriptionContent(reader io.Reader) []*conf.OutboundDetourConfig {
 		outbound, err := shareLink.Outbound()
 		if err != nil {
 			logger.Error("Failed to parse outbound from link", "link", line, "err", err.Error())
-			counter.Incr("subscription.parse.error", 1)
+			stats.Add(metrics.SubscriptionParseErrors, 1, counter.Labels{"reason": "outbound"})
 			continue
 		}
 
@@ -95,19 +186,24 @@ func parseSubscriptionContent(reader io.Reader) []*conf.OutboundDetourConfig {
 		logger.Error("Error reading subscription", "err", err.Error())
 	}
 
-	cnt := counter.Get("subscription.invalid") + counter.Get("subscription.parse.error")
+	cnt := stats.Sum(metrics.SubscriptionParseErrors)
 	logger.Info("Parsed outbounds from subscription result", "total", len(outbounds), "invalid", cnt)
 	return outbounds
 }
 
-func (p *SubscriptionParser) Fetch(url string) ([]byte, string, error) {
-	return nil, "", nil
-}
-
 func (p *SubscriptionParser) Validate(data []byte) bool {
 	return true
 }
 
+// isProxyProtocol 判断出站是否为代理节点，排除 freedom / blackhole 等内置出站
+func isProxyProtocol(protocol string) bool {
+	switch protocol {
+	case "vmess", "vless", "shadowsocks", "trojan", "socks", "wireguard":
+		return true
+	}
+	return false
+}
+
 // isValidLink 判断是否为支持的协议链接
 func isValidLink(link string) bool {
 	for _, scheme := range types.SupportedSchemes {
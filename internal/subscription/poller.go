@@ -1,151 +1,386 @@
 package subscription
 
 import (
-	"crypto/sha256"
-	"fmt"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/xtls/xray-core/infra/conf"
+	"zhouxin.learn/go/vxrayui/config"
 	"zhouxin.learn/go/vxrayui/internal/decision"
 	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/node"
+	"zhouxin.learn/go/vxrayui/internal/override"
+	"zhouxin.learn/go/vxrayui/internal/scheduler"
 	"zhouxin.learn/go/vxrayui/internal/types"
 )
 
+// Fetcher 拉取来源内容、校验并解析，SubscriptionParser 是其实现
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) ([][]byte, string, http.Header, error)
+	Validate(data []byte) bool
+	Parse(subscription *config.Subscription, contents [][]byte) []*conf.OutboundDetourConfig
+}
+
+// clock 提供当前时间和定时，测试中替换为假时钟
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Poller 按各来源学到的间隔拉取内容。来源状态由 mu 保护，运行中可以增删来源；
+// 拉取在 Run 的 context 下进行，取消 context 或调用 Stop 会中断进行中的请求
 type Poller struct {
-	parser   *SubscriptionParser
-	storage  types.Storage
-	engine   *decision.Engine
-	sources  map[string]*SourceConfig
-	stopChan chan struct{}
-	wg       sync.WaitGroup
+	fetcher Fetcher
+	storage types.Storage
+	engine  *decision.Engine
+
+	// 可替换以便测试
+	clock         clock
+	value         func(url string) float64
+	subscription  func(url string) *config.Subscription
+	loadBlacklist func() (override.Blacklist, error)
+	loadState     func(url string) (*sourceState, error)
+	saveState     func(url string, state *sourceState) error
+	saveNodes     func(subscription *config.Subscription, outbounds []*conf.OutboundDetourConfig)
+
+	mu      sync.Mutex
+	sources map[string]*SourceConfig
+	// 新增来源时唤醒等待中的 Run
+	wake chan struct{}
+
+	stopCtx context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+
+	// 随轮询器启动和停止的节点测量调度器，可为空
+	scheduler *scheduler.Scheduler
 }
 
+// SourceConfig 是轮询来源的配置和学到的状态，状态按 URL 持久化，重启后继续使用
 type SourceConfig struct {
-	URL          string
-	MinInterval  time.Duration
-	MaxInterval  time.Duration
+	URL         string
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// 当前的轮询间隔，由内容变化周期、来源价值和响应头得出
+	Interval time.Duration
+	// 内容变化间隔的滑动平均
+	ChangePeriod time.Duration
+	Hash         string
 	LastCheck    time.Time
+	LastChange   time.Time
+	// Retry-After 要求的最早时间
+	NotBefore    time.Time
 	FailureCount int
+	// 上次内容变化时解析出的节点数
+	Nodes int
 }
 
+const (
+	// 轮询器两次检查之间的最长等待
+	maxPollWait = time.Minute
+	// 同时拉取的来源数
+	maxConcurrentPolls = 5
+)
+
+// NewPoller 创建轮询器，sources 被复制，之后通过 AddSource 和 RemoveSource 修改
 func NewPoller(
-	parser *SubscriptionParser,
+	fetcher Fetcher,
 	store types.Storage,
 	engine *decision.Engine,
 	sources map[string]*SourceConfig,
 ) *Poller {
-	return &Poller{
-		parser:   parser,
-		storage:  store,
-		engine:   engine,
-		sources:  sources,
-		stopChan: make(chan struct{}),
+	stopCtx, stop := context.WithCancel(context.Background())
+	p := &Poller{
+		fetcher:       fetcher,
+		storage:       store,
+		engine:        engine,
+		clock:         systemClock{},
+		value:         sourceValue,
+		subscription:  findSubscription,
+		loadBlacklist: override.LoadBlacklist,
+		loadState:     loadSourceState,
+		saveState:     saveSourceState,
+		saveNodes:     saveNodes,
+		sources:       make(map[string]*SourceConfig),
+		wake:          make(chan struct{}, 1),
+		stopCtx:       stopCtx,
+		stop:          stop,
+	}
+	for url, source := range sources {
+		source := *source
+		source.URL = url
+		p.sources[url] = &source
 	}
+	return p
 }
 
-func (p *Poller) Run() {
+// PollSources 返回配置中需要轮询的订阅：启用的远程、git 和网页订阅，
+// 本地文件和目录由 Watcher 监视，标准输入只在导入时读取
+func PollSources() map[string]*SourceConfig {
+	sources := make(map[string]*SourceConfig)
+	for _, sub := range config.GetSubscriptions() {
+		if !sub.Enabled {
+			continue
+		}
+		switch SourceType(sub.Url) {
+		case SourceRemote, SourceGit, SourceScrape:
+			sources[sub.Url] = &SourceConfig{}
+		}
+	}
+	return sources
+}
+
+// SetScheduler 设置随轮询器一起运行的测量调度器，需在 Run 之前调用
+func (p *Poller) SetScheduler(s *scheduler.Scheduler) {
+	p.scheduler = s
+}
+
+// AddSource 添加或替换来源并恢复其保存的状态，到期的来源在下一轮拉取
+func (p *Poller) AddSource(url string, source SourceConfig) {
+	source.URL = url
+	p.restore(&source)
+
+	p.mu.Lock()
+	p.sources[url] = &source
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RemoveSource 移除来源，进行中的拉取结果被丢弃
+func (p *Poller) RemoveSource(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sources, url)
+}
+
+// Sources 返回所有来源当前状态的副本，按 URL 排序
+func (p *Poller) Sources() []SourceConfig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sources := make([]SourceConfig, 0, len(p.sources))
+	for _, source := range p.sources {
+		sources = append(sources, *source)
+	}
+	slices.SortFunc(sources, func(a, b SourceConfig) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+	return sources
+}
+
+// Run 拉取到期的来源直到 ctx 取消或 Stop 被调用，返回前等待进行中的拉取结束；
+// Stop 之后调用的 Run 直接返回
+func (p *Poller) Run(ctx context.Context) {
+	// 与 Stop 互斥，Stop 取消后不会再有 Add，Wait 不会提前返回
+	p.mu.Lock()
+	if p.stopCtx.Err() != nil {
+		p.mu.Unlock()
+		return
+	}
 	p.wg.Add(1)
+	p.mu.Unlock()
 	defer p.wg.Done()
 
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopCancel := context.AfterFunc(p.stopCtx, cancel)
+	defer stopCancel()
+
+	if p.scheduler != nil {
+		go p.scheduler.Run()
+		defer p.scheduler.Stop()
+	}
+
+	// 恢复 NewPoller 传入的来源保存的状态
+	p.mu.Lock()
+	for _, source := range p.sources {
+		p.restore(source)
+	}
+	p.mu.Unlock()
 
 	for {
+		p.pollAllSources(ctx)
 		select {
-		case <-ticker.C:
-			p.pollAllSources()
-		case <-p.stopChan:
+		case <-p.clock.After(p.nextPoll(p.clock.Now())):
+		case <-p.wake:
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// Stop 取消进行中的拉取并等待 Run 返回
 func (p *Poller) Stop() {
-	close(p.stopChan)
+	p.mu.Lock()
+	p.stop()
+	p.mu.Unlock()
 	p.wg.Wait()
 }
 
-func (p *Poller) pollAllSources() {
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, 5) // 并发限制
+// restore 读取来源保存的状态，没有记录时保持初始值
+func (p *Poller) restore(source *SourceConfig) {
+	state, err := p.loadState(source.URL)
+	if err != nil {
+		logger.Error("Failed to restore source state", "url", source.URL, "err", err.Error())
+		return
+	}
+	source.apply(state)
+}
+
+// nextPoll 返回距最早到期来源的等待时长
+func (p *Poller) nextPoll(now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	wait := maxPollWait
+	for _, source := range p.sources {
+		wait = min(wait, source.nextCheck().Sub(now))
+	}
+	return max(wait, time.Second)
+}
 
+// due 返回到期且未被拉黑的来源，订阅按名称或 URL 拉黑
+func (p *Poller) due(now time.Time, blacklist override.Blacklist) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var urls []string
 	for url, source := range p.sources {
-		if time.Since(source.LastCheck) < p.calculateInterval(source) {
+		if now.Before(source.nextCheck()) {
+			continue
+		}
+		if sub := p.subscription(url); blacklist.Subscription(sub.Name, sub.Url) != nil {
 			continue
 		}
+		urls = append(urls, url)
+	}
+	slices.Sort(urls)
+	return urls
+}
+
+func (p *Poller) pollAllSources(ctx context.Context) {
+	blacklist, err := p.loadBlacklist()
+	if err != nil {
+		logger.Error("Failed to load blacklist", "err", err.Error())
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxConcurrentPolls)
+	for _, url := range p.due(p.clock.Now(), blacklist) {
+		if ctx.Err() != nil {
+			break
+		}
 
 		wg.Add(1)
 		semaphore <- struct{}{}
 
-		go func(url string, source *SourceConfig) {
+		go func(url string) {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			p.pollSingleSource(url, source)
-		}(url, source)
+			p.pollSingleSource(ctx, url)
+		}(url)
 	}
 
 	wg.Wait()
 }
 
-func (p *Poller) pollSingleSource(url string, source *SourceConfig) {
-	source.LastCheck = time.Now()
-
-	data, hash, err := p.parser.Fetch(url)
-	if err != nil {
-		source.FailureCount++
-		logger.Error("Failed to fetch %s (attempt %d): %v", url, source.FailureCount, err)
+// pollSingleSource 在锁外拉取来源，再在锁内更新其状态；拉取期间来源被移除时丢弃结果。
+// 内容变化时保存并解析内容，节点入库后随来源状态记录节点数
+func (p *Poller) pollSingleSource(ctx context.Context, url string) {
+	contents, hash, header, fetchErr := p.fetcher.Fetch(ctx, url)
+	if ctx.Err() != nil {
+		// 停止时被中断的拉取不计为失败
+		logger.Debug("Source fetch cancelled", "url", url)
 		return
 	}
+	value := 0.5
+	if fetchErr == nil {
+		value = p.value(url)
+	}
+	now := p.clock.Now()
 
-	// 检查内容是否变化
-	storedCfg, _ := p.storage.GetConfig(url)
-	if storedCfg != nil && storedCfg.Valid {
-		if storedHash := sha256.Sum256(storedCfg.Content); fmt.Sprintf("%x", storedHash) == hash {
-			return // 内容未变化
-		}
+	p.mu.Lock()
+	source, ok := p.sources[url]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	changed := false
+	if fetchErr != nil {
+		source.observeFailure(now, header)
+	} else {
+		changed = source.observe(now, hash, header, value)
 	}
+	state := source.state()
+	p.mu.Unlock()
 
-	// 验证并存储新配置
-	if valid := p.parser.Validate(data); valid {
-		newCfg := &types.ConfigMetadata{
-			ID:          url,
-			Content:     data,
-			LastUpdated: time.Now(),
-			Valid:       true,
-			SourceURL:   url,
-		}
-
-		if err := p.storage.StoreConfig(newCfg); err != nil {
-			logger.Error("Failed to store config from %s: %v", url, err)
-			return
+	subscription := p.subscription(url)
+	if fetchErr == nil && changed {
+		logger.Info("Source changed", "url", url, "interval", state.Interval, "change_period", state.ChangePeriod)
+		state.Nodes = p.update(subscription, contents, now)
+		p.mu.Lock()
+		if source, ok := p.sources[url]; ok {
+			source.Nodes = state.Nodes
 		}
+		p.mu.Unlock()
+	}
 
-		source.FailureCount = 0 // 重置失败计数
-		// p.stats.RecordValidation(url, true)
-	} else {
-		// p.stats.RecordValidation(url, false)
+	if err := p.saveState(url, state); err != nil {
+		logger.Error("Failed to save source state", "url", url, "err", err.Error())
+	}
+	if fetchErr != nil {
+		logger.Error("Failed to fetch source", "url", url, "attempt", state.FailureCount, "err", fetchErr.Error())
+		publishFailed(subscription, fetchErr)
+		return
 	}
+	if !changed {
+		// 内容未变化时只延长间隔
+		logger.Debug("Source unchanged", "url", url, "interval", state.Interval)
+	}
+	publishFetched(subscription, hash, state.Nodes, changed)
 }
 
-func (p *Poller) calculateInterval(source *SourceConfig) time.Duration {
-	// 基于失败次数的指数退避
-	baseInterval := source.MinInterval
-	if source.FailureCount > 0 {
-		backoff := time.Duration(1<<min(time.Duration(source.FailureCount), 5)) * time.Minute
-		baseInterval = min(source.MaxInterval, baseInterval+backoff)
+// update 保存变化的内容，再逐份解析出节点入库，返回解析出的节点数；无效的内容不保存也不解析。
+// 多份内容合并后校验和保存
+func (p *Poller) update(subscription *config.Subscription, contents [][]byte, now time.Time) int {
+	data := joinContents(contents)
+	if !p.fetcher.Validate(data) {
+		logger.Error("Invalid source content", "url", subscription.Url)
+		return 0
+	}
+	cfg := &types.ConfigMetadata{
+		ID:          subscription.Url,
+		Content:     data,
+		LastUpdated: now,
+		Valid:       true,
+		SourceURL:   subscription.Url,
+	}
+	if err := p.storage.StoreConfig(cfg); err != nil {
+		logger.Error("Failed to store config", "url", subscription.Url, "err", err.Error())
 	}
 
-	// 基于统计数据的动态调整
-	// validRate := p.stats.GetValidityRate(source.URL)
-	// adjustment := 1.0 + (1.0 - validRate) // 有效性越低，检查越频繁
-	// return time.Duration(float64(baseInterval) * adjustment)
-	return baseInterval
+	outbounds := p.fetcher.Parse(subscription, contents)
+	p.saveNodes(subscription, outbounds)
+	return len(outbounds)
 }
 
-func min(a, b time.Duration) time.Duration {
-	if a < b {
-		return a
-	}
-	return b
+// saveNodes 保存订阅的节点并解析其域名
+func saveNodes(subscription *config.Subscription, outbounds []*conf.OutboundDetourConfig) {
+	node.ResolveNodes(node.Save(subscription, outbounds))
 }
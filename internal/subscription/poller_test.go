@@ -0,0 +1,452 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/event"
+	"zhouxin.learn/go/vxrayui/internal/override"
+	"zhouxin.learn/go/vxrayui/internal/types"
+)
+
+// fakeClock only moves when advanced; every After call is reported on sleeping
+// so a test knows the poller finished a round and is waiting
+type fakeClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	waiters  []fakeWaiter
+	sleeping chan time.Duration
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		sleeping: make(chan time.Duration, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), ch: ch})
+	select {
+	case c.sleeping <- d:
+	default:
+	}
+	return ch
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var pending []fakeWaiter
+	for _, w := range c.waiters {
+		if c.now.Before(w.deadline) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// wait blocks until the poller goes to sleep again
+func (c *fakeClock) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.sleeping:
+	case <-time.After(5 * time.Second):
+		t.Fatal("poller did not go to sleep")
+	}
+}
+
+// step advances a minute at a time, the longest the poller sleeps, letting it poll after each
+func (c *fakeClock) step(t *testing.T, minutes int) {
+	t.Helper()
+	for range minutes {
+		c.advance(time.Minute)
+		c.wait(t)
+	}
+}
+
+// fakeFetcher serves a fixed body per url, or blocks until cancelled when block is set
+type fakeFetcher struct {
+	mu      sync.Mutex
+	bodies  map[string]string
+	calls   map[string]int
+	block   bool
+	started chan string
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, url string) ([][]byte, string, http.Header, error) {
+	f.mu.Lock()
+	f.calls[url]++
+	body, ok := f.bodies[url]
+	block := f.block
+	f.mu.Unlock()
+
+	if block {
+		f.started <- url
+		<-ctx.Done()
+		return nil, "", nil, ctx.Err()
+	}
+	if !ok {
+		return nil, "", nil, errors.New("not found")
+	}
+	return [][]byte{[]byte(body)}, fmt.Sprintf("hash-%s", body), nil, nil
+}
+
+func (f *fakeFetcher) Validate(data []byte) bool {
+	return len(data) > 0
+}
+
+// Parse returns an outbound per field
+func (f *fakeFetcher) Parse(subscription *config.Subscription, contents [][]byte) []*conf.OutboundDetourConfig {
+	var outbounds []*conf.OutboundDetourConfig
+	for _, data := range contents {
+		for _, field := range strings.Fields(string(data)) {
+			outbounds = append(outbounds, &conf.OutboundDetourConfig{Tag: field})
+		}
+	}
+	return outbounds
+}
+
+func (f *fakeFetcher) count(url string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[url]
+}
+
+type memoryStorage struct {
+	mu      sync.Mutex
+	configs map[string]*types.ConfigMetadata
+	states  map[string]*sourceState
+	// saved outbound tags by subscription url
+	nodes map[string][]string
+}
+
+func (s *memoryStorage) StoreConfig(cfg *types.ConfigMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs[cfg.ID] = cfg
+	return nil
+}
+
+func (s *memoryStorage) GetConfig(id string) (*types.ConfigMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.configs[id], nil
+}
+
+func newTestPoller(fetcher *fakeFetcher, clock *fakeClock, sources map[string]*SourceConfig) (*Poller, *memoryStorage) {
+	store := &memoryStorage{
+		configs: make(map[string]*types.ConfigMetadata),
+		states:  make(map[string]*sourceState),
+		nodes:   make(map[string][]string),
+	}
+	p := NewPoller(fetcher, store, nil, sources)
+	p.clock = clock
+	p.value = func(string) float64 { return 0.5 }
+	p.subscription = func(url string) *config.Subscription { return &config.Subscription{Name: url, Url: url} }
+	p.loadBlacklist = func() (override.Blacklist, error) { return nil, nil }
+	p.loadState = func(url string) (*sourceState, error) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return store.states[url], nil
+	}
+	p.saveState = func(url string, state *sourceState) error {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		store.states[url] = state
+		return nil
+	}
+	p.saveNodes = func(subscription *config.Subscription, outbounds []*conf.OutboundDetourConfig) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		var tags []string
+		for _, outbound := range outbounds {
+			tags = append(tags, outbound.Tag)
+		}
+		store.nodes[subscription.Url] = tags
+	}
+	return p, store
+}
+
+func TestPollerPollsDueSources(t *testing.T) {
+	fetcher := &fakeFetcher{
+		bodies: map[string]string{"fast": "a", "slow": "b"},
+		calls:  make(map[string]int),
+	}
+	clock := newFakeClock()
+	p, store := newTestPoller(fetcher, clock, map[string]*SourceConfig{
+		"fast":   {MinInterval: 10 * time.Minute, MaxInterval: time.Hour},
+		"slow":   {MinInterval: 30 * time.Minute, MaxInterval: time.Hour},
+		"broken": {MinInterval: 10 * time.Minute, MaxInterval: time.Hour},
+	})
+
+	events := event.Subscribe(100, "subscription")
+	defer events.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	clock.wait(t)
+	if fetcher.count("fast") != 1 || fetcher.count("slow") != 1 || fetcher.count("broken") != 1 {
+		t.Fatalf("first round calls = %d, %d, %d, want every source once", fetcher.count("fast"), fetcher.count("slow"), fetcher.count("broken"))
+	}
+	published := make(map[event.Type]int)
+	for range 5 {
+		published[(<-events.Events()).Type]++
+	}
+	if published[event.SubscriptionFetched] != 2 || published[event.SubscriptionChanged] != 2 || published[event.SubscriptionFailed] != 1 {
+		t.Errorf("published = %v", published)
+	}
+	if cfg, _ := store.GetConfig("fast"); cfg == nil || string(cfg.Content) != "a" {
+		t.Errorf("stored config = %+v", cfg)
+	}
+
+	clock.step(t, 9)
+	if fetcher.count("fast") != 1 {
+		t.Errorf("fast polled %d times before its interval", fetcher.count("fast"))
+	}
+	clock.step(t, 1)
+	if fetcher.count("fast") != 2 || fetcher.count("slow") != 1 {
+		t.Errorf("after 10m calls fast = %d, slow = %d, want 2 and 1", fetcher.count("fast"), fetcher.count("slow"))
+	}
+
+	// the failing source backs off 2m on top of its interval
+	clock.step(t, 2)
+	if fetcher.count("broken") != 2 {
+		t.Errorf("broken polled %d times after 12m, want 2", fetcher.count("broken"))
+	}
+	for _, source := range p.Sources() {
+		if source.URL == "broken" && source.FailureCount != 2 {
+			t.Errorf("broken failure count = %d, want 2", source.FailureCount)
+		}
+	}
+
+	store.mu.Lock()
+	saved := store.states["fast"]
+	store.mu.Unlock()
+	if saved == nil || saved.Hash != "hash-a" {
+		t.Errorf("saved state = %+v", saved)
+	}
+}
+
+func TestPollerSkipsBlacklisted(t *testing.T) {
+	clock := newFakeClock()
+	p, _ := newTestPoller(&fakeFetcher{}, clock, map[string]*SourceConfig{"a": {}, "b": {}, "c": {}})
+	p.subscription = func(url string) *config.Subscription { return &config.Subscription{Name: "sub-" + url, Url: url} }
+
+	blacklist := override.Blacklist{
+		{Kind: override.KindSubscription, Value: "sub-a"},
+		{Kind: override.KindSubscription, Value: "b"},
+	}
+	if got := p.due(clock.Now(), blacklist); !slices.Equal(got, []string{"c"}) {
+		t.Errorf("due = %v, want the source blacklisted by neither name nor url", got)
+	}
+}
+
+func TestPollerStopCancelsFetch(t *testing.T) {
+	fetcher := &fakeFetcher{
+		calls:   make(map[string]int),
+		block:   true,
+		started: make(chan string, 1),
+	}
+	p, store := newTestPoller(fetcher, newFakeClock(), map[string]*SourceConfig{"hang": {}})
+
+	go p.Run(context.Background())
+	<-fetcher.started
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not cancel the in-flight fetch")
+	}
+
+	// a cancelled fetch is not a failure
+	if source := p.Sources()[0]; source.FailureCount != 0 || !source.LastCheck.IsZero() {
+		t.Errorf("cancelled fetch recorded: %+v", source)
+	}
+	if len(store.states) != 0 {
+		t.Errorf("cancelled fetch saved state: %v", store.states)
+	}
+}
+
+func TestPollerConcurrentEdits(t *testing.T) {
+	fetcher := &fakeFetcher{bodies: make(map[string]string), calls: make(map[string]int)}
+	for i := range 10 {
+		fetcher.bodies[fmt.Sprintf("source-%d", i)] = fmt.Sprint(i)
+	}
+	clock := newFakeClock()
+	p, _ := newTestPoller(fetcher, clock, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			for range 20 {
+				p.AddSource(url, SourceConfig{MinInterval: time.Minute})
+				p.Sources()
+				p.RemoveSource(url)
+			}
+			p.AddSource(url, SourceConfig{MinInterval: time.Minute})
+		}(fmt.Sprintf("source-%d", i))
+	}
+	for range 5 {
+		clock.advance(time.Minute)
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if sources := p.Sources(); len(sources) != 10 {
+		t.Errorf("sources = %d, want 10", len(sources))
+	}
+}
+
+func TestPollerParsesChangedContent(t *testing.T) {
+	fetcher := &fakeFetcher{
+		bodies: map[string]string{"feed": "a b"},
+		calls:  make(map[string]int),
+	}
+	clock := newFakeClock()
+	p, store := newTestPoller(fetcher, clock, map[string]*SourceConfig{
+		"feed": {MinInterval: 10 * time.Minute, MaxInterval: 10 * time.Minute},
+	})
+
+	events := event.Subscribe(100, event.SubscriptionFetched)
+	defer events.Close()
+	fetched := func() *event.Subscription {
+		t.Helper()
+		select {
+		case e := <-events.Events():
+			return e.Data.(*event.Subscription)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no subscription.fetched")
+			return nil
+		}
+	}
+	saved := func() []string {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		tags := store.nodes["feed"]
+		delete(store.nodes, "feed")
+		return tags
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	clock.wait(t)
+	if data := fetched(); data.Nodes != 2 {
+		t.Errorf("first fetch nodes = %d, want 2", data.Nodes)
+	}
+	if tags := saved(); strings.Join(tags, " ") != "a b" {
+		t.Errorf("saved nodes = %v", tags)
+	}
+
+	// unchanged content is not parsed again and keeps its node count
+	clock.step(t, 10)
+	if data := fetched(); data.Nodes != 2 {
+		t.Errorf("unchanged fetch nodes = %d, want 2", data.Nodes)
+	}
+	if tags := saved(); tags != nil {
+		t.Errorf("unchanged content saved %v", tags)
+	}
+
+	fetcher.mu.Lock()
+	fetcher.bodies["feed"] = "a b c"
+	fetcher.mu.Unlock()
+	clock.step(t, 10)
+	if data := fetched(); data.Nodes != 3 {
+		t.Errorf("changed fetch nodes = %d, want 3", data.Nodes)
+	}
+	if tags := saved(); len(tags) != 3 {
+		t.Errorf("changed content saved %v", tags)
+	}
+
+	store.mu.Lock()
+	state := store.states["feed"]
+	store.mu.Unlock()
+	if state == nil || state.Nodes != 3 {
+		t.Errorf("saved state = %+v, want 3 nodes", state)
+	}
+}
+
+// a Run started after Stop, e.g. `go p.Run(ctx)` losing the race with shutdown, must not poll
+func TestPollerRunAfterStop(t *testing.T) {
+	fetcher := &fakeFetcher{bodies: map[string]string{"feed": "a"}, calls: make(map[string]int)}
+	p, _ := newTestPoller(fetcher, newFakeClock(), map[string]*SourceConfig{"feed": {}})
+
+	p.Stop()
+	done := make(chan struct{})
+	go func() {
+		p.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run after Stop did not return")
+	}
+	if fetcher.count("feed") != 0 {
+		t.Errorf("Run after Stop polled %d times", fetcher.count("feed"))
+	}
+}
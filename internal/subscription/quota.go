@@ -0,0 +1,140 @@
+package subscription
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/storage"
+)
+
+const (
+	StorageKeyQuota        = "subscription.quota."
+	StorageKeyQuotaHistory = "subscription.quota_history."
+
+	userInfoHeader = "Subscription-Userinfo"
+)
+
+// Quota 是订阅响应头 subscription-userinfo 中的流量和到期信息，字节为单位
+type Quota struct {
+	Subscription string    `json:"subscription"`
+	Upload       int64     `json:"upload"`
+	Download     int64     `json:"download"`
+	Total        int64     `json:"total"`
+	Expire       time.Time `json:"expire,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Used 返回已用流量
+func (quota *Quota) Used() int64 {
+	return quota.Upload + quota.Download
+}
+
+// ParseUserInfo 解析 upload=1; download=2; total=3; expire=1700000000，expire 为 0 或缺失表示不过期
+func ParseUserInfo(value string) (*Quota, error) {
+	quota := &Quota{}
+	var found bool
+	for _, field := range strings.Split(value, ";") {
+		key, text, ok := strings.Cut(strings.TrimSpace(field), "=")
+		text = strings.TrimSpace(text)
+		if !ok || len(text) == 0 {
+			continue
+		}
+		// 部分机场返回浮点数
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", key, text)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "upload":
+			quota.Upload = int64(number)
+		case "download":
+			quota.Download = int64(number)
+		case "total":
+			quota.Total = int64(number)
+		case "expire":
+			if number > 0 {
+				quota.Expire = time.Unix(int64(number), 0)
+			}
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no quota in %q", value)
+	}
+	return quota, nil
+}
+
+// recordQuota 保存响应头中的订阅配额并追加到历史，对新越过的阈值告警，没有该响应头时不做处理
+func recordQuota(subscription *config.Subscription, header http.Header) {
+	value := header.Get(userInfoHeader)
+	if len(value) == 0 {
+		return
+	}
+	quota, err := ParseUserInfo(value)
+	if err != nil {
+		logger.Debug("Failed to parse subscription userinfo", "subscription", subscription.Name, "err", err.Error())
+		return
+	}
+
+	quota.Subscription = QuotaKey(subscription)
+	quota.UpdatedAt = time.Now()
+
+	previous, err := GetQuota(quota.Subscription)
+	if err != nil {
+		logger.Error("Failed to get subscription quota", "subscription", subscription.Name, "err", err.Error())
+	}
+	if err := SaveQuota(quota); err != nil {
+		logger.Error("Failed to save subscription quota", "subscription", subscription.Name, "err", err.Error())
+	}
+	if err := appendQuotaHistory(quota, alertSettings.History); err != nil {
+		logger.Error("Failed to save subscription quota history", "subscription", subscription.Name, "err", err.Error())
+	}
+	alert(newWarnings(previous, quota, alertSettings, quota.UpdatedAt), alertSettings.Webhook)
+}
+
+// QuotaKey 返回订阅配额的存储名，未命名的订阅使用 URL
+func QuotaKey(subscription *config.Subscription) string {
+	if len(subscription.Name) > 0 {
+		return subscription.Name
+	}
+	return subscription.Url
+}
+
+// MetricName 返回订阅在指标中的名称，与 QuotaKey 一致，未命名的订阅使用脱敏的 URL
+func MetricName(subscription *config.Subscription) string {
+	return RedactName(QuotaKey(subscription))
+}
+
+func SaveQuota(quota *Quota) error {
+	return storage.Set(StorageKeyQuota+quota.Subscription, quota)
+}
+
+func GetQuota(subscription string) (*Quota, error) {
+	return storage.Get[*Quota](StorageKeyQuota + subscription)
+}
+
+// GetQuotaHistory 返回订阅的配额历史，按时间从旧到新
+func GetQuotaHistory(subscription string) ([]*Quota, error) {
+	return storage.Get[[]*Quota](StorageKeyQuotaHistory + subscription)
+}
+
+// appendQuotaHistory 追加一条配额记录，只保留最近 limit 条
+func appendQuotaHistory(quota *Quota, limit int) error {
+	history, err := GetQuotaHistory(quota.Subscription)
+	if err != nil {
+		return err
+	}
+	history = append(history, quota)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return storage.Set(StorageKeyQuotaHistory+quota.Subscription, history)
+}
@@ -0,0 +1,45 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUserInfo(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Quota
+	}{
+		{
+			"upload=455727941; download=6174315083; total=1073741824000; expire=1706716800",
+			Quota{Upload: 455727941, Download: 6174315083, Total: 1073741824000, Expire: time.Unix(1706716800, 0)},
+		},
+		{
+			"upload=0;download=1.5e9;total=0;expire=",
+			Quota{Download: 1500000000},
+		},
+		{
+			"Upload=1; Download=2; Total=3; Expire=0; unknown",
+			Quota{Upload: 1, Download: 2, Total: 3},
+		},
+	}
+	for _, test := range tests {
+		quota, err := ParseUserInfo(test.value)
+		if err != nil {
+			t.Errorf("ParseUserInfo(%q): %v", test.value, err)
+			continue
+		}
+		if quota.Upload != test.want.Upload || quota.Download != test.want.Download ||
+			quota.Total != test.want.Total || !quota.Expire.Equal(test.want.Expire) {
+			t.Errorf("ParseUserInfo(%q) = %+v, want %+v", test.value, *quota, test.want)
+		}
+	}
+}
+
+func TestParseUserInfoInvalid(t *testing.T) {
+	for _, value := range []string{"", "plan=pro", "upload=abc"} {
+		if _, err := ParseUserInfo(value); err == nil {
+			t.Errorf("ParseUserInfo(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"zhouxin.learn/go/vxrayui/config"
+)
+
+// 常见客户端的 User-Agent，机场按 UA 返回对应格式的订阅
+var UserAgentPresets = map[string]string{
+	"clash":        "ClashforWindows/0.20.39",
+	"clash-meta":   "clash.meta/v1.19.0",
+	"v2rayn":       "v2rayN/7.0.0",
+	"v2rayng":      "v2rayNG/1.9.0",
+	"sing-box":     "sing-box 1.10.0",
+	"shadowrocket": "Shadowrocket/2070 CFNetwork/1496.0.7 Darwin/23.5.0",
+	"quantumult-x": "Quantumult%20X/1.4.1",
+}
+
+const defaultTokenParam = "token"
+
+// newRequest 按订阅配置设置 User-Agent、请求头和认证信息
+func newRequest(ctx context.Context, subscription *config.Subscription) (*http.Request, error) {
+	rawUrl := subscription.Url
+	auth := subscription.Auth
+	if auth != nil && auth.Type == "query" {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			return nil, err
+		}
+		param := auth.Param
+		if len(param) == 0 {
+			param = defaultTokenParam
+		}
+		query := u.Query()
+		query.Set(param, auth.Token)
+		u.RawQuery = query.Encode()
+		rawUrl = u.String()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if userAgent := subscription.UserAgent; len(userAgent) > 0 {
+		if preset, ok := UserAgentPresets[strings.ToLower(userAgent)]; ok {
+			userAgent = preset
+		}
+		req.Header.Set("User-Agent", userAgent)
+	}
+	for key, value := range subscription.Headers {
+		req.Header.Set(key, value)
+	}
+
+	if auth != nil {
+		switch auth.Type {
+		case "basic":
+			req.SetBasicAuth(auth.Username, auth.Password)
+		case "bearer":
+			req.Header.Set("Authorization", "Bearer "+auth.Token)
+		case "query":
+		default:
+			return nil, fmt.Errorf("unknown auth type: %s", auth.Type)
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,101 @@
+package subscription
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zhouxin.learn/go/vxrayui/config"
+)
+
+func TestNewRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription *config.Subscription
+		url          string
+		header       map[string]string
+	}{
+		{
+			name:         "preset user agent and headers",
+			subscription: &config.Subscription{Url: "https://example.com/sub", UserAgent: "Clash-Meta", Headers: map[string]string{"X-Device": "router"}},
+			url:          "https://example.com/sub",
+			header:       map[string]string{"User-Agent": UserAgentPresets["clash-meta"], "X-Device": "router"},
+		},
+		{
+			name:         "literal user agent",
+			subscription: &config.Subscription{Url: "https://example.com/sub", UserAgent: "my-client/1.0"},
+			url:          "https://example.com/sub",
+			header:       map[string]string{"User-Agent": "my-client/1.0"},
+		},
+		{
+			name:         "basic auth",
+			subscription: &config.Subscription{Url: "https://example.com/sub", Auth: &config.SubscriptionAuth{Type: "basic", Username: "user", Password: "pass"}},
+			url:          "https://example.com/sub",
+			header:       map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+		},
+		{
+			name:         "bearer auth",
+			subscription: &config.Subscription{Url: "https://example.com/sub", Auth: &config.SubscriptionAuth{Type: "bearer", Token: "secret"}},
+			url:          "https://example.com/sub",
+			header:       map[string]string{"Authorization": "Bearer secret"},
+		},
+		{
+			name:         "query token",
+			subscription: &config.Subscription{Url: "https://example.com/sub?flag=clash", Auth: &config.SubscriptionAuth{Type: "query", Token: "a b"}},
+			url:          "https://example.com/sub?flag=clash&token=a+b",
+		},
+		{
+			name:         "query token with custom param",
+			subscription: &config.Subscription{Url: "https://example.com/sub", Auth: &config.SubscriptionAuth{Type: "query", Param: "key", Token: "secret"}},
+			url:          "https://example.com/sub?key=secret",
+		},
+	}
+	for _, test := range tests {
+		req, err := newRequest(context.Background(), test.subscription)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if req.URL.String() != test.url {
+			t.Errorf("%s: url = %s, want %s", test.name, req.URL, test.url)
+		}
+		for key, want := range test.header {
+			if got := req.Header.Get(key); got != want {
+				t.Errorf("%s: %s = %q, want %q", test.name, key, got, want)
+			}
+		}
+	}
+}
+
+func TestNewRequestUnknownAuth(t *testing.T) {
+	_, err := newRequest(context.Background(), &config.Subscription{Url: "https://example.com/sub", Auth: &config.SubscriptionAuth{Type: "digest"}})
+	if err == nil {
+		t.Error("unknown auth type accepted")
+	}
+}
+
+func TestFetchSendsRequestOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != UserAgentPresets["v2rayn"] || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Subscription-Userinfo", "upload=1; download=2; total=10; expire=0")
+		w.Write([]byte(fetchContent))
+	}))
+	defer server.Close()
+
+	_, header, err := NewSubscriptionParser().fetch(context.Background(), &config.Subscription{
+		Url:       server.URL,
+		Fetch:     []string{RouteDirect},
+		UserAgent: "v2rayn",
+		Auth:      &config.SubscriptionAuth{Type: "bearer", Token: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get(userInfoHeader) == "" {
+		t.Error("userinfo header is not returned")
+	}
+}
@@ -0,0 +1,103 @@
+package subscription
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"zhouxin.learn/go/vxrayui/internal/types"
+)
+
+const scrapeScheme = "scrape+"
+
+var (
+	// 换行和块级标签转为换行，其余标签直接去掉，Telegram 把链接中的 emoji 包在 <i><b> 中
+	lineTagPattern = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|pre|blockquote|tr|h[1-6])>`)
+	tagPattern     = regexp.MustCompile(`<[^>]*>`)
+	// 链接在空白、引号、尖括号和反引号处结束
+	linkPattern = regexp.MustCompile(`(?i)\b(` + schemePattern() + `)://[^\s"'<>` + "`" + `]+`)
+	// 换行后的续行只由 URL 字符组成
+	continuationPattern = regexp.MustCompile(`^[A-Za-z0-9+/=%&?#@:;,._~!$*()\[\]-]+`)
+)
+
+func schemePattern() string {
+	var schemes []string
+	for _, scheme := range types.SupportedSchemes {
+		schemes = append(schemes, scheme.String())
+	}
+	return strings.Join(schemes, "|")
+}
+
+// scrapeLinks 从网页或任意文本中提取支持的分享链接，按出现顺序去重；
+// 处理 HTML 标签和实体，以及被换行截断的链接
+func scrapeLinks(content string) []string {
+	text := lineTagPattern.ReplaceAllString(content, "\n")
+	text = tagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var links []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		matches := linkPattern.FindAllStringIndex(line, -1)
+		for j, match := range matches {
+			link := line[match[0]:match[1]]
+			// 行尾的链接不完整时拼接下一行
+			for j == len(matches)-1 && match[1] == len(line) && i+1 < len(lines) && !completeLink(link) {
+				next := strings.TrimSpace(lines[i+1])
+				continuation := continuationPattern.FindString(next)
+				if len(continuation) == 0 || linkPattern.MatchString(next) {
+					break
+				}
+				link += continuation
+				i++
+				if len(continuation) < len(next) {
+					break
+				}
+			}
+
+			link = trimLink(link)
+			if !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+			}
+		}
+	}
+	return links
+}
+
+// completeLink 判断链接是否完整：vmess 的 base64 能解出 JSON，其它链接带有端口
+func completeLink(link string) bool {
+	scheme, rest, _ := strings.Cut(link, "://")
+	if strings.EqualFold(scheme, "vmess") {
+		payload, _, _ := strings.Cut(rest, "#")
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if data, err := encoding.DecodeString(payload); err == nil && json.Valid(data) {
+				return true
+			}
+		}
+		return false
+	}
+	u, err := url.Parse(link)
+	return err == nil && len(u.Port()) > 0
+}
+
+// trimLink 去掉链接后紧跟的标点，括号只在不成对时去掉
+func trimLink(link string) string {
+	for len(link) > 0 {
+		last := link[len(link)-1]
+		switch {
+		case strings.IndexByte(".,;:!?", last) >= 0:
+		case last == ')' && strings.Count(link, "(") < strings.Count(link, ")"):
+		case last == ']' && strings.Count(link, "[") < strings.Count(link, "]"):
+		default:
+			return link
+		}
+		link = link[:len(link)-1]
+	}
+	return link
+}
@@ -0,0 +1,92 @@
+package subscription
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"zhouxin.learn/go/vxrayui/config"
+)
+
+const vmessPayload = "eyJ2IjoiMiIsInBzIjoid3JhcHBlZCIsImFkZCI6InZtLmV4YW1wbGUuY29tIiwicG9ydCI6IjQ0MyIsImlkIjoiMjc4NDg3MzktN2U2Mi00MTM4LTlmZDMtMDk4YTYzOTY0YjZiIiwibmV0Ijoid3MiLCJ0bHMiOiJ0bHMifQ=="
+
+// telegramPage 模仿 Telegram 频道网页预览 t.me/s/<channel> 的消息结构
+const telegramPage = `<div class="tgme_widget_message_text js-message_text" dir="auto">
+<b>New configs</b> 🔥<br/>
+<code>vless://8dc5b94a-382f-4d34-b44e-d78ba12aee1a@www.speedtest.net:8880?path=%2Fws&amp;security=none&amp;type=ws#<i class="emoji"><b>🇨🇦</b></i>Canada</code><br/><br/>
+Also try trojan://secret@tr.example.com:443#backup.<br/>
+<a href="https://t.me/v2ray_configs_pool">@v2ray_configs_pool</a>
+</div>
+<div class="tgme_widget_message_text">vless://8dc5b94a-382f-4d34-b44e-d78ba12aee1a@www.speedtest.net:8880?path=%2Fws&amp;security=none&amp;type=ws#🇨🇦Canada</div>`
+
+func TestScrapeLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "telegram preview",
+			content: telegramPage,
+			want: []string{
+				"vless://8dc5b94a-382f-4d34-b44e-d78ba12aee1a@www.speedtest.net:8880?path=%2Fws&security=none&type=ws#🇨🇦Canada",
+				"trojan://secret@tr.example.com:443#backup",
+			},
+		},
+		{
+			name:    "link in prose ending with a backtick",
+			content: "👉 `ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@ss.example.com:8388#👉🆔@v2ray_configs_pool📡🇨🇦®️Canada©️Toronto🅿️ping:15.62ms` (fast)",
+			want:    []string{"ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@ss.example.com:8388#👉🆔@v2ray_configs_pool📡🇨🇦®️Canada©️Toronto🅿️ping:15.62ms"},
+		},
+		{
+			name:    "wrapped vmess",
+			content: "config:\nvmess://" + vmessPayload[:60] + "\n" + vmessPayload[60:120] + "\n" + vmessPayload[120:] + "\nenjoy",
+			want:    []string{"vmess://" + vmessPayload},
+		},
+		{
+			name:    "query wrapped after the port",
+			content: "vless://id@v.example.com\n:443?type=tcp#wrapped\nsecond line",
+			want:    []string{"vless://id@v.example.com:443?type=tcp#wrapped"},
+		},
+		{
+			name:    "complete link is not joined",
+			content: "trojan://secret@tr.example.com:443\nnext",
+			want:    []string{"trojan://secret@tr.example.com:443"},
+		},
+		{
+			name:    "unsupported schemes",
+			content: "https://example.com hysteria2://secret@h.example.com:443 wireguard://x",
+		},
+	}
+	for _, test := range tests {
+		got := scrapeLinks(test.content)
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: links = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLoadScrapeSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/s/v2ray_configs_pool" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(telegramPage))
+	}))
+	defer server.Close()
+
+	contents, _, err := NewSubscriptionParser().load(context.Background(), &config.Subscription{
+		Url:   "scrape+" + server.URL + "/s/v2ray_configs_pool",
+		Fetch: []string{RouteDirect},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 1 || strings.Count(string(contents[0]), "\n") != 1 {
+		t.Errorf("contents = %q, want two links", contents)
+	}
+}
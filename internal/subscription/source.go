@@ -0,0 +1,138 @@
+package subscription
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"zhouxin.learn/go/vxrayui/config"
+)
+
+// 订阅来源类型，由订阅 Url 决定
+const (
+	// http:// 或 https:// 远程订阅
+	SourceRemote = "remote"
+	// file:// 指向的单个文件
+	SourceFile = "file"
+	// file:// 指向的目录，读取其中的 *.txt / *.yaml / *.json
+	SourceDirectory = "directory"
+	// stdin:// 或 -，命令行从标准输入导入
+	SourceStdin = "stdin"
+	// git+https:// 或 git+file:// 仓库中 Paths 列出的文件
+	SourceGit = "git"
+	// scrape+https:// 网页，例如 Telegram 频道预览 https://t.me/s/<channel>，提取其中的分享链接
+	SourceScrape = "scrape"
+)
+
+const (
+	fileScheme  = "file://"
+	stdinScheme = "stdin://"
+)
+
+// 目录来源读取的文件扩展名
+var sourceExtensions = []string{".txt", ".yaml", ".yml", ".json"}
+
+// SourceType 返回订阅 Url 的来源类型，file:// 路径不存在时按文件处理，读取时报错
+func SourceType(rawUrl string) string {
+	switch {
+	case rawUrl == "-" || strings.HasPrefix(rawUrl, stdinScheme):
+		return SourceStdin
+	case strings.HasPrefix(rawUrl, gitScheme):
+		return SourceGit
+	case strings.HasPrefix(rawUrl, scrapeScheme):
+		return SourceScrape
+	case strings.HasPrefix(rawUrl, fileScheme):
+		if info, err := os.Stat(localPath(rawUrl)); err == nil && info.IsDir() {
+			return SourceDirectory
+		}
+		return SourceFile
+	}
+	return SourceRemote
+}
+
+// localPath 返回 file:// 的本地路径，支持 file:///abs/path 和 file://relative/path
+func localPath(rawUrl string) string {
+	return filepath.FromSlash(strings.TrimPrefix(rawUrl, fileScheme))
+}
+
+// load 按来源类型读取订阅，目录来源每个文件是一份独立的内容，其它来源只有一份；
+// 网页来源的内容是提取出的分享链接，每行一个；只有远程和网页来源有响应头
+func (p *SubscriptionParser) load(ctx context.Context, subscription *config.Subscription) ([][]byte, http.Header, error) {
+	switch SourceType(subscription.Url) {
+	case SourceStdin:
+		data, err := io.ReadAll(p.stdin)
+		return [][]byte{data}, nil, err
+	case SourceFile:
+		data, err := os.ReadFile(localPath(subscription.Url))
+		return [][]byte{data}, nil, err
+	case SourceGit:
+		contents, err := loadGit(ctx, subscription)
+		return contents, nil, err
+	case SourceDirectory:
+		files, err := sourceFiles(localPath(subscription.Url))
+		if err != nil {
+			return nil, nil, err
+		}
+		var contents [][]byte
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, nil, err
+			}
+			contents = append(contents, data)
+		}
+		if len(contents) == 0 {
+			return nil, nil, fmt.Errorf("no subscription files in %s", subscription.Url)
+		}
+		return contents, nil, nil
+	}
+
+	if SourceType(subscription.Url) == SourceScrape {
+		page := *subscription
+		page.Url = strings.TrimPrefix(subscription.Url, scrapeScheme)
+		data, header, err := p.fetch(ctx, &page)
+		if err != nil {
+			return nil, header, err
+		}
+		links := scrapeLinks(string(data))
+		if len(links) == 0 {
+			return nil, nil, fmt.Errorf("no share links in %s", page.Url)
+		}
+		return [][]byte{[]byte(strings.Join(links, "\n"))}, header, nil
+	}
+
+	data, header, err := p.fetch(ctx, subscription)
+	if err != nil {
+		return nil, header, err
+	}
+	return [][]byte{data}, header, nil
+}
+
+// sourceFiles 返回目录中可读取的订阅文件，按文件名排序，不递归
+func sourceFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if slices.Contains(sourceExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// joinContents 把多份内容合并为一份，用于计算整体的哈希
+func joinContents(contents [][]byte) []byte {
+	return bytes.Join(contents, []byte("\n"))
+}
@@ -0,0 +1,140 @@
+package subscription
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSourceType(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "links.txt")
+	writeFile(t, file, fetchContent)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/sub", SourceRemote},
+		{"file://" + file, SourceFile},
+		{"file://" + dir, SourceDirectory},
+		{"file://" + filepath.Join(dir, "missing.txt"), SourceFile},
+		{"stdin://", SourceStdin},
+		{"-", SourceStdin},
+	}
+	for _, test := range tests {
+		if got := SourceType(test.url); got != test.want {
+			t.Errorf("SourceType(%q) = %s, want %s", test.url, got, test.want)
+		}
+	}
+}
+
+func TestLoadLocalSources(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "b.yaml"), "proxies: []")
+	writeFile(t, filepath.Join(dir, "a.txt"), fetchContent)
+	writeFile(t, filepath.Join(dir, "c.json"), "{}")
+	writeFile(t, filepath.Join(dir, "notes.md"), "ignored")
+	writeFile(t, filepath.Join(dir, ".hidden.txt"), "ignored")
+	if err := os.Mkdir(filepath.Join(dir, "nested.txt"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := NewSubscriptionParser()
+	contents, _, err := parser.load(context.Background(), &config.Subscription{Url: "file://" + dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{fetchContent, "proxies: []", "{}"}
+	if len(contents) != len(want) {
+		t.Fatalf("loaded %d files, want %d", len(contents), len(want))
+	}
+	for i := range want {
+		if string(contents[i]) != want[i] {
+			t.Errorf("file %d = %q, want %q", i, contents[i], want[i])
+		}
+	}
+
+	contents, _, err = parser.load(context.Background(), &config.Subscription{Url: "file://" + filepath.Join(dir, "a.txt")})
+	if err != nil || len(contents) != 1 || string(contents[0]) != fetchContent {
+		t.Errorf("file source = %q, %v", contents, err)
+	}
+
+	parser.stdin = strings.NewReader(fetchContent)
+	contents, _, err = parser.load(context.Background(), &config.Subscription{Url: "-"})
+	if err != nil || len(contents) != 1 || string(contents[0]) != fetchContent {
+		t.Errorf("stdin source = %q, %v", contents, err)
+	}
+
+	if _, _, err := parser.load(context.Background(), &config.Subscription{Url: "file://" + t.TempDir()}); err == nil {
+		t.Error("empty directory loaded")
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "links.txt")
+	writeFile(t, file, fetchContent)
+	subscriptions := []*config.Subscription{
+		{Name: "dir", Url: "file://" + dir, Enabled: true},
+		{Name: "file", Url: "file://" + file, Enabled: true},
+		{Name: "remote", Url: "https://example.com/sub", Enabled: true},
+	}
+
+	var changed []string
+	watcher := NewWatcher(time.Second, func(sub *config.Subscription) {
+		changed = append(changed, sub.Name)
+	})
+	watcher.subscriptions = func() []*config.Subscription { return subscriptions }
+
+	watcher.poll(false)
+	watcher.poll(true)
+	if len(changed) != 0 {
+		t.Fatalf("unchanged sources reported: %v", changed)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	watcher.poll(true)
+	if strings.Join(changed, ",") != "dir,file" {
+		t.Errorf("changed = %v, want [dir file]", changed)
+	}
+
+	changed = nil
+	writeFile(t, filepath.Join(dir, "more.json"), "{}")
+	watcher.poll(true)
+	if strings.Join(changed, ",") != "dir" {
+		t.Errorf("changed = %v, want [dir]", changed)
+	}
+}
+
+func TestParsePerFile(t *testing.T) {
+	// base64 bodies with padding cannot be decoded once joined
+	var contents [][]byte
+	for _, link := range []string{"trojan://secret@example.com:443#a1", "trojan://secret@example.org:443#b1"} {
+		contents = append(contents, []byte(base64.StdEncoding.EncodeToString([]byte(link))))
+	}
+	if !strings.HasSuffix(string(contents[0]), "=") {
+		t.Fatalf("body %s has no padding", contents[0])
+	}
+
+	outbounds := NewSubscriptionParser().Parse(&config.Subscription{Url: "file:///sub", IsBase64: true}, contents)
+	if len(outbounds) != 2 {
+		t.Errorf("parsed %d outbounds, want one per file", len(outbounds))
+	}
+}
@@ -2,31 +2,28 @@ package subscription
 
 import (
 	"zhouxin.learn/go/vxrayui/config"
-	"zhouxin.learn/go/vxrayui/internal/stats"
-	"zhouxin.learn/go/vxrayui/pkg/random"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+	"zhouxin.learn/go/vxrayui/internal/override"
 )
 
+// PickSubscription 用 Thompson 采样选择一个启用且未被拉黑的订阅，兼顾探索新订阅和利用产出高的订阅，
+// 没有可选订阅时返回 nil
 func PickSubscription() *config.Subscription {
+	blacklist, err := override.LoadBlacklist()
+	if err != nil {
+		logger.Error("Failed to load blacklist", "err", err.Error())
+	}
+
 	var subs []*config.Subscription
-	var weights []int
 	for _, sub := range config.GetSubscriptions() {
-		if !sub.Enabled {
+		if !sub.Enabled || blacklist.Subscription(sub.Name, sub.Url) != nil {
 			continue
 		}
-
-		scheme := sub.Scheme
-		if scheme == "" {
-			scheme = config.DefalutScheme
-		}
-
-		yieldRate, _ := stats.GetSchemeYieldRate(sub.Scheme)
 		subs = append(subs, sub)
-		if yieldRate == nil || yieldRate.Total == 0 {
-			weights = append(weights, 0)
-		} else {
-			weights = append(weights, yieldRate.Yiled*10000/yieldRate.Total)
-		}
 	}
 
-	return random.Pick(subs, weights)
+	if len(subs) == 0 {
+		return nil
+	}
+	return pickBandit(subs)
 }
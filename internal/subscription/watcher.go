@@ -0,0 +1,138 @@
+package subscription
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"zhouxin.learn/go/vxrayui/config"
+	"zhouxin.learn/go/vxrayui/internal/logger"
+)
+
+const defaultWatchInterval = 10 * time.Second
+
+// fileState 是文件的修改时间和大小，任一变化即视为内容变化
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher 轮询本地文件和目录订阅，内容变化时回调 onChange；
+// 目录中文件的新增、删除和修改都会触发
+type Watcher struct {
+	interval time.Duration
+	onChange func(*config.Subscription)
+	states   map[string]map[string]fileState
+	mu       sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+
+	subscriptions func() []*config.Subscription
+}
+
+// NewWatcher 创建监视配置中本地订阅的 Watcher，interval 为 0 时使用默认值
+func NewWatcher(interval time.Duration, onChange func(*config.Subscription)) *Watcher {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	return &Watcher{
+		interval:      interval,
+		onChange:      onChange,
+		states:        make(map[string]map[string]fileState),
+		stopChan:      make(chan struct{}),
+		subscriptions: config.GetSubscriptions,
+	}
+}
+
+func (w *Watcher) Run() {
+	// 与 Stop 互斥，Stop 之后调用的 Run 直接返回，Wait 不会漏掉之后才开始的 Run
+	w.mu.Lock()
+	select {
+	case <-w.stopChan:
+		w.mu.Unlock()
+		return
+	default:
+	}
+	w.wg.Add(1)
+	w.mu.Unlock()
+	defer w.wg.Done()
+
+	// 记录初始状态，启动时已解析过的内容不重复回调
+	w.poll(false)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.poll(true)
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	close(w.stopChan)
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+// poll 检查所有启用的本地订阅，notify 为 true 时对变化的订阅回调
+func (w *Watcher) poll(notify bool) {
+	for _, sub := range w.subscriptions() {
+		if !sub.Enabled {
+			continue
+		}
+		sourceType := SourceType(sub.Url)
+		if sourceType != SourceFile && sourceType != SourceDirectory {
+			continue
+		}
+
+		state, err := snapshot(sub.Url, sourceType)
+		if err != nil {
+			logger.Debug("Failed to stat subscription source", "url", sub.Url, "err", err.Error())
+		}
+		previous, seen := w.states[sub.Url]
+		w.states[sub.Url] = state
+		if notify && seen && !sameState(previous, state) {
+			logger.Info("Subscription source changed", "url", sub.Url)
+			w.onChange(sub)
+		}
+	}
+}
+
+// snapshot 返回来源中各文件的状态，读取失败时返回空状态，恢复后视为变化
+func snapshot(rawUrl, sourceType string) (map[string]fileState, error) {
+	files := []string{localPath(rawUrl)}
+	if sourceType == SourceDirectory {
+		var err error
+		if files, err = sourceFiles(localPath(rawUrl)); err != nil {
+			return nil, err
+		}
+	}
+
+	state := make(map[string]fileState, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		state[file] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return state, nil
+}
+
+func sameState(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, state := range a {
+		if other, ok := b[file]; !ok || !other.modTime.Equal(state.modTime) || other.size != state.size {
+			return false
+		}
+	}
+	return true
+}
@@ -1,30 +1,333 @@
 package counter
 
 import (
+	"slices"
+	"strings"
 	"sync"
-	"sync/atomic"
+	"time"
 )
 
-var globalCounter safeCounter
+// Kind is the type of a metric
+type Kind string
 
-type safeCounter struct{ counts sync.Map }
+const (
+	KindCounter   Kind = "counter"
+	KindGauge     Kind = "gauge"
+	KindHistogram Kind = "histogram"
+)
 
-func Incr(key string, delta int64) {
-	val, _ := globalCounter.counts.LoadOrStore(key, new(int64))
-	atomic.AddInt64(val.(*int64), delta)
+// Labels are the name/value pairs identifying one series of a metric
+type Labels map[string]string
+
+// key returns the canonical form of the labels, sorted by name
+func (labels Labels) key() string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(labels[name]))
+		b.WriteByte('"')
+	}
+	return b.String()
 }
 
-func Get(key string) int64 {
-	val, ok := globalCounter.counts.Load(key)
+func (labels Labels) clone() Labels {
+	if len(labels) == 0 {
+		return nil
+	}
+	clone := make(Labels, len(labels))
+	for name, value := range labels {
+		clone[name] = value
+	}
+	return clone
+}
+
+// Sample is one series of a gauge computed by a collector
+type Sample struct {
+	Labels Labels
+	Value  float64
+}
+
+// merge returns labels with the scope labels added; labels win on conflict
+func (labels Labels) merge(scope Labels) Labels {
+	if len(scope) == 0 {
+		return labels
+	}
+	merged := make(Labels, len(labels)+len(scope))
+	for name, value := range scope {
+		merged[name] = value
+	}
+	for name, value := range labels {
+		merged[name] = value
+	}
+	return merged
+}
+
+// contains reports whether labels has every pair of match
+func (labels Labels) contains(match Labels) bool {
+	for name, value := range match {
+		if v, ok := labels[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry holds named metrics. A metric's kind is fixed by its first use;
+// using it as another kind is ignored.
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]*metric
+	// set for scopes, which forward counts and observations to their parent
+	parent *Registry
+	labels Labels
+	// replaceable in tests
+	now func() time.Time
+}
+
+type metric struct {
+	name    string
+	kind    Kind
+	help    string
+	buckets []float64
+	// computes a gauge on export instead of storing it
+	collect func() []Sample
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labels Labels
+	// counter value
+	count int64
+	// gauge value, or histogram sum
+	value float64
+	// histogram observations per bucket, not cumulative, the last one is +Inf
+	buckets []uint64
+	total   uint64
+	// counter additions per minute, for Window and Rate
+	window *window
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*metric), now: time.Now}
+}
+
+// Scope returns an empty registry whose counters and histograms also record into r,
+// with labels added to every series. Use one per unit of work, such as a parse run,
+// to read its own counts while r keeps the totals.
+func (r *Registry) Scope(labels Labels) *Registry {
+	scope := NewRegistry()
+	scope.parent = r
+	scope.labels = labels.clone()
+	scope.now = r.now
+	return scope
+}
+
+// metric returns the metric called name, creating it with kind when missing;
+// it returns nil when name is already used by another kind
+func (r *Registry) metric(name string, kind Kind) *metric {
+	r.mu.RLock()
+	m, ok := r.metrics[name]
+	r.mu.RUnlock()
+	if !ok {
+		r.mu.Lock()
+		if m, ok = r.metrics[name]; !ok {
+			m = &metric{name: name, kind: kind, series: make(map[string]*series)}
+			if kind == KindHistogram {
+				m.buckets = DefaultBuckets
+			}
+			r.metrics[name] = m
+		}
+		r.mu.Unlock()
+	}
+	if m.kind != kind {
+		return nil
+	}
+	return m
+}
+
+// get returns the series for labels, creating it when missing; the caller holds m.mu
+func (m *metric) get(labels Labels) *series {
+	key := labels.key()
+	s, ok := m.series[key]
 	if !ok {
+		s = &series{labels: labels.clone()}
+		switch m.kind {
+		case KindCounter:
+			s.window = &window{}
+		case KindHistogram:
+			s.buckets = make([]uint64, len(m.buckets)+1)
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Describe sets the help text of a metric, declaring it with kind when missing
+func (r *Registry) Describe(name string, kind Kind, help string) {
+	if m := r.metric(name, kind); m != nil {
+		m.mu.Lock()
+		m.help = help
+		m.mu.Unlock()
+	}
+}
+
+// Add adds delta to a counter series
+func (r *Registry) Add(name string, delta int64, labels Labels) {
+	if r.parent != nil {
+		r.parent.Add(name, delta, labels.merge(r.labels))
+	}
+	m := r.metric(name, KindCounter)
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	s := m.get(labels)
+	s.count += delta
+	s.window.add(r.now(), delta)
+	m.mu.Unlock()
+}
+
+// Value returns a counter series, 0 when missing
+func (r *Registry) Value(name string, labels Labels) int64 {
+	m := r.metric(name, KindCounter)
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.series[labels.key()]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// Sum returns the total of all series of a counter
+func (r *Registry) Sum(name string) int64 {
+	m := r.metric(name, KindCounter)
+	if m == nil {
 		return 0
 	}
-	return atomic.LoadInt64(val.(*int64))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sum int64
+	for _, s := range m.series {
+		sum += s.count
+	}
+	return sum
+}
+
+// Set sets a gauge series; it is not forwarded by scopes
+func (r *Registry) Set(name string, value float64, labels Labels) {
+	m := r.metric(name, KindGauge)
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.get(labels).value = value
+	m.mu.Unlock()
 }
 
+// Collect makes a gauge computed by collect on every export, replacing the series set with Set
+func (r *Registry) Collect(name string, help string, collect func() []Sample) {
+	m := r.metric(name, KindGauge)
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.help = help
+	m.collect = collect
+	m.mu.Unlock()
+}
+
+// Reset removes every metric; the parent of a scope keeps its totals
+func (r *Registry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = make(map[string]*metric)
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process-wide registry used by the package functions
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Incr adds delta to the unlabeled counter key
+func Incr(key string, delta int64) {
+	defaultRegistry.Add(key, delta, nil)
+}
+
+// Get returns the unlabeled counter key
+func Get(key string) int64 {
+	return defaultRegistry.Value(key, nil)
+}
+
+// Reset removes every metric of the default registry
 func Reset() {
-	globalCounter.counts.Range(func(key, _ interface{}) bool {
-		globalCounter.counts.Delete(key)
-		return true
-	})
+	defaultRegistry.Reset()
+}
+
+// Add adds delta to a counter series of the default registry
+func Add(name string, delta int64, labels Labels) {
+	defaultRegistry.Add(name, delta, labels)
+}
+
+// Value returns a counter series of the default registry
+func Value(name string, labels Labels) int64 {
+	return defaultRegistry.Value(name, labels)
+}
+
+// Sum returns the total of all series of a counter of the default registry
+func Sum(name string) int64 {
+	return defaultRegistry.Sum(name)
+}
+
+// Set sets a gauge series of the default registry
+func Set(name string, value float64, labels Labels) {
+	defaultRegistry.Set(name, value, labels)
+}
+
+// Observe records a histogram observation in the default registry
+func Observe(name string, value float64, labels Labels) {
+	defaultRegistry.Observe(name, value, labels)
+}
+
+// Describe sets the help text of a metric of the default registry
+func Describe(name string, kind Kind, help string) {
+	defaultRegistry.Describe(name, kind, help)
+}
+
+// Collect registers a computed gauge in the default registry
+func Collect(name string, help string, collect func() []Sample) {
+	defaultRegistry.Collect(name, help, collect)
+}
+
+// Scope returns a scope of the default registry
+func Scope(labels Labels) *Registry {
+	return defaultRegistry.Scope(labels)
+}
+
+// Window returns the matching counter additions of the default registry in the last d
+func Window(name string, match Labels, d time.Duration) int64 {
+	return defaultRegistry.Window(name, match, d)
+}
+
+// Rate returns the per-second rate of Window in the default registry
+func Rate(name string, match Labels, d time.Duration) float64 {
+	return defaultRegistry.Rate(name, match, d)
 }
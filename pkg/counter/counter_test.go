@@ -0,0 +1,222 @@
+package counter
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLabeledCounters(t *testing.T) {
+	r := NewRegistry()
+	r.Add("fetches_total", 1, Labels{"result": "success"})
+	r.Add("fetches_total", 2, Labels{"result": "failure"})
+	r.Add("fetches_total", 1, Labels{"result": "success"})
+	r.Add("flat", 5, nil)
+
+	if got := r.Value("fetches_total", Labels{"result": "success"}); got != 2 {
+		t.Errorf("success = %d, want 2", got)
+	}
+	if got := r.Sum("fetches_total"); got != 4 {
+		t.Errorf("sum = %d, want 4", got)
+	}
+	if got := r.Value("flat", nil); got != 5 {
+		t.Errorf("flat = %d, want 5", got)
+	}
+	if got := r.Value("missing", Labels{"a": "b"}); got != 0 {
+		t.Errorf("missing = %d", got)
+	}
+
+	// a name keeps the kind of its first use
+	r.Set("flat", 1, nil)
+	r.Observe("flat", 1, nil)
+	if got := r.Value("flat", nil); got != 5 {
+		t.Errorf("flat after misuse = %d, want 5", got)
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				r.Add("probes_total", 1, Labels{"outcome": "success"})
+				r.Observe("latency_seconds", 0.1, nil)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := r.Value("probes_total", Labels{"outcome": "success"}); got != 1000 {
+		t.Errorf("probes = %d, want 1000", got)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	r.Describe("subscription.parse.error", KindCounter, "Lines that failed to parse.")
+	r.Add("subscription.parse.error", 3, Labels{"reason": `bad "link"`})
+	r.Set("active_latency_seconds", 0.25, nil)
+	r.Collect("nodes_healthy", "Healthy nodes.", func() []Sample {
+		return []Sample{
+			{Labels: Labels{"country": "US"}, Value: 2},
+			{Labels: Labels{"country": "JP"}, Value: 1},
+		}
+	})
+	r.Histogram("probe_seconds", "Probe latency.", []float64{0.5, 0.1})
+	for _, value := range []float64{0.05, 0.1, 0.3, 2} {
+		r.Observe("probe_seconds", value, Labels{"protocol": "vless"})
+	}
+
+	var b strings.Builder
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE active_latency_seconds gauge
+active_latency_seconds 0.25
+# HELP nodes_healthy Healthy nodes.
+# TYPE nodes_healthy gauge
+nodes_healthy{country="JP"} 1
+nodes_healthy{country="US"} 2
+# HELP probe_seconds Probe latency.
+# TYPE probe_seconds histogram
+probe_seconds_bucket{protocol="vless",le="0.1"} 2
+probe_seconds_bucket{protocol="vless",le="0.5"} 3
+probe_seconds_bucket{protocol="vless",le="+Inf"} 4
+probe_seconds_sum{protocol="vless"} 2.45
+probe_seconds_count{protocol="vless"} 4
+# HELP subscription_parse_error Lines that failed to parse.
+# TYPE subscription_parse_error counter
+subscription_parse_error{reason="bad \"link\""} 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestScope(t *testing.T) {
+	r := NewRegistry()
+	r.Add("parse_errors_total", 5, Labels{"subscription": "a", "reason": "outbound"})
+
+	run := r.Scope(Labels{"subscription": "a"})
+	run.Add("parse_errors_total", 1, Labels{"reason": "outbound"})
+	line := run.Scope(nil)
+	line.Add("parse_errors_total", 2, Labels{"reason": "unsupported"})
+
+	if got := line.Sum("parse_errors_total"); got != 2 {
+		t.Errorf("inner scope = %d, want 2", got)
+	}
+	if got := run.Sum("parse_errors_total"); got != 3 {
+		t.Errorf("run scope = %d, want 3", got)
+	}
+	if got := r.Value("parse_errors_total", Labels{"subscription": "a", "reason": "outbound"}); got != 6 {
+		t.Errorf("parent outbound = %d, want 6", got)
+	}
+	if got := r.Value("parse_errors_total", Labels{"subscription": "a", "reason": "unsupported"}); got != 2 {
+		t.Errorf("parent unsupported = %d, want 2", got)
+	}
+
+	run.Reset()
+	if got := run.Sum("parse_errors_total"); got != 0 {
+		t.Errorf("run after reset = %d", got)
+	}
+	if got := r.Sum("parse_errors_total"); got != 8 {
+		t.Errorf("parent after scope reset = %d, want 8", got)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+
+	r.Add("fetches_total", 3, Labels{"subscription": "a", "result": "failure"})
+	now = now.Add(10 * time.Minute)
+	r.Add("fetches_total", 1, Labels{"subscription": "a", "result": "failure"})
+	r.Add("fetches_total", 4, Labels{"subscription": "b", "result": "failure"})
+
+	if got := r.Window("fetches_total", Labels{"subscription": "a"}, 5*time.Minute); got != 1 {
+		t.Errorf("a in 5m = %d, want 1", got)
+	}
+	if got := r.Window("fetches_total", Labels{"subscription": "a"}, 15*time.Minute); got != 4 {
+		t.Errorf("a in 15m = %d, want 4", got)
+	}
+	if got := r.Window("fetches_total", nil, time.Hour); got != 8 {
+		t.Errorf("all in 1h = %d, want 8", got)
+	}
+	if got := r.Rate("fetches_total", Labels{"subscription": "b"}, time.Minute); got != 4.0/60 {
+		t.Errorf("b rate = %v, want %v", got, 4.0/60)
+	}
+
+	// slots older than the window are not counted, even when reused
+	now = now.Add(55 * time.Minute)
+	if got := r.Window("fetches_total", nil, time.Hour); got != 5 {
+		t.Errorf("all in 1h after 55m = %d, want 5", got)
+	}
+	now = now.Add(time.Hour)
+	r.Add("fetches_total", 1, Labels{"subscription": "a", "result": "failure"})
+	if got := r.Window("fetches_total", nil, 2*time.Hour); got != 1 {
+		t.Errorf("all after 2h = %d, want 1", got)
+	}
+	if got := r.Sum("fetches_total"); got != 9 {
+		t.Errorf("sum = %d, want 9", got)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+	r.Add("fetches_total", 2, Labels{"result": "success"})
+	r.Histogram("probe_seconds", "", []float64{0.1, 0.5})
+	r.Observe("probe_seconds", 0.3, nil)
+	r.Set("active_latency_seconds", 0.3, nil)
+
+	data, err := json.Marshal(r.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot *Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	restored := NewRegistry()
+	restored.now = func() time.Time { return now }
+	restored.Add("fetches_total", 1, Labels{"result": "success"})
+	restored.Restore(snapshot)
+
+	if got := restored.Value("fetches_total", Labels{"result": "success"}); got != 3 {
+		t.Errorf("fetches = %d, want 3", got)
+	}
+	if got := restored.Window("fetches_total", nil, time.Hour); got != 3 {
+		t.Errorf("fetches in 1h = %d, want 3", got)
+	}
+	if got := restored.Window("fetches_total", nil, 10*time.Minute); got != 1 {
+		t.Errorf("fetches in 10m = %d, want 1", got)
+	}
+
+	var b strings.Builder
+	if err := restored.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	if out := b.String(); !strings.Contains(out, `probe_seconds_bucket{le="0.5"} 1`) || strings.Contains(out, "active_latency_seconds") {
+		t.Errorf("restored exposition:\n%s", out)
+	}
+
+	// window counts older than MaxWindow are dropped
+	now = now.Add(time.Hour)
+	late := NewRegistry()
+	late.now = func() time.Time { return now }
+	late.Restore(snapshot)
+	if got := late.Window("fetches_total", nil, time.Hour); got != 0 {
+		t.Errorf("late window = %d, want 0", got)
+	}
+	if got := late.Sum("fetches_total"); got != 2 {
+		t.Errorf("late sum = %d, want 2", got)
+	}
+}
@@ -0,0 +1,44 @@
+package counter
+
+import "sort"
+
+// DefaultBuckets are the upper bounds used by histograms without their own, in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram declares a histogram with the given bucket upper bounds, sorted ascending.
+// Buckets cannot change once the histogram has observations.
+func (r *Registry) Histogram(name string, help string, buckets []float64) {
+	m := r.metric(name, KindHistogram)
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.help = help
+	if len(m.series) == 0 && len(buckets) > 0 {
+		m.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(m.buckets)
+	}
+}
+
+// Observe records one observation in a histogram series
+func (r *Registry) Observe(name string, value float64, labels Labels) {
+	if r.parent != nil {
+		r.parent.Observe(name, value, labels.merge(r.labels))
+	}
+	m := r.metric(name, KindHistogram)
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.get(labels)
+	s.buckets[sort.SearchFloat64s(m.buckets, value)]++
+	s.value += value
+	s.total++
+}
+
+// Histogram declares a histogram in the default registry
+func Histogram(name string, help string, buckets []float64) {
+	defaultRegistry.Histogram(name, help, buckets)
+}
@@ -0,0 +1,152 @@
+package counter
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of WritePrometheus output
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus writes every metric in the Prometheus text exposition format,
+// metrics and series sorted by name. Characters not allowed in metric and label
+// names, such as the dots of flat counter keys, are written as underscores.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.RLock()
+	metrics := make([]*metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mu.RUnlock()
+	slices.SortFunc(metrics, func(a, b *metric) int { return strings.Compare(a.name, b.name) })
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (m *metric) write(w *bufio.Writer) {
+	m.mu.Lock()
+	collect := m.collect
+	m.mu.Unlock()
+	// collectors run unlocked, they may take time or use the registry
+	var collected []Sample
+	if collect != nil {
+		collected = collect()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := sanitizeName(m.name)
+	if len(m.help) > 0 {
+		w.WriteString("# HELP " + name + " " + escapeHelp(m.help) + "\n")
+	}
+	w.WriteString("# TYPE " + name + " " + string(m.kind) + "\n")
+
+	if collect != nil {
+		slices.SortFunc(collected, func(a, b Sample) int { return strings.Compare(a.Labels.key(), b.Labels.key()) })
+		for _, sample := range collected {
+			writeSample(w, name, sample.Labels, "", formatFloat(sample.Value))
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		s := m.series[key]
+		switch m.kind {
+		case KindCounter:
+			writeSample(w, name, s.labels, "", strconv.FormatInt(s.count, 10))
+		case KindGauge:
+			writeSample(w, name, s.labels, "", formatFloat(s.value))
+		case KindHistogram:
+			var cumulative uint64
+			for i, count := range s.buckets {
+				cumulative += count
+				le := "+Inf"
+				if i < len(m.buckets) {
+					le = formatFloat(m.buckets[i])
+				}
+				writeSample(w, name+"_bucket", s.labels, `le="`+le+`"`, strconv.FormatUint(cumulative, 10))
+			}
+			writeSample(w, name+"_sum", s.labels, "", formatFloat(s.value))
+			writeSample(w, name+"_count", s.labels, "", strconv.FormatUint(s.total, 10))
+		}
+	}
+}
+
+// writeSample writes one line, extra is an already formatted label appended last
+func writeSample(w *bufio.Writer, name string, labels Labels, extra string, value string) {
+	w.WriteString(name)
+	pairs := sanitizeLabels(labels).key()
+	if len(extra) > 0 {
+		if len(pairs) > 0 {
+			pairs += ","
+		}
+		pairs += extra
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + pairs + "}")
+	}
+	w.WriteString(" " + value + "\n")
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sanitizeName replaces characters outside [a-zA-Z0-9_:] with underscores
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func sanitizeLabels(labels Labels) Labels {
+	if len(labels) == 0 {
+		return labels
+	}
+	sanitized := make(Labels, len(labels))
+	for name, value := range labels {
+		sanitized[strings.ReplaceAll(sanitizeName(name), ":", "_")] = value
+	}
+	return sanitized
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+// WritePrometheus writes the default registry in the Prometheus text format
+func WritePrometheus(w io.Writer) error {
+	return defaultRegistry.WritePrometheus(w)
+}
@@ -0,0 +1,129 @@
+package counter
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Snapshot holds the counters and histograms of a registry, for persisting them
+// across restarts. Gauges describe the present and are left out.
+type Snapshot struct {
+	Time    time.Time         `json:"time"`
+	Metrics []*MetricSnapshot `json:"metrics"`
+}
+
+type MetricSnapshot struct {
+	Name    string            `json:"name"`
+	Kind    Kind              `json:"kind"`
+	Buckets []float64         `json:"buckets,omitempty"`
+	Series  []*SeriesSnapshot `json:"series"`
+}
+
+type SeriesSnapshot struct {
+	Labels Labels `json:"labels,omitempty"`
+	// counter value
+	Count int64 `json:"count,omitempty"`
+	// counter additions keyed by minute since the epoch
+	Window map[int64]int64 `json:"window,omitempty"`
+	// histogram sum, bucket observations (not cumulative, the last one is +Inf) and count
+	Sum     float64  `json:"sum,omitempty"`
+	Buckets []uint64 `json:"buckets,omitempty"`
+	Total   uint64   `json:"total,omitempty"`
+}
+
+// Snapshot copies the counters and histograms of r
+func (r *Registry) Snapshot() *Snapshot {
+	r.mu.RLock()
+	metrics := make([]*metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		if m.kind != KindGauge {
+			metrics = append(metrics, m)
+		}
+	}
+	r.mu.RUnlock()
+	slices.SortFunc(metrics, func(a, b *metric) int { return strings.Compare(a.name, b.name) })
+
+	snapshot := &Snapshot{Time: r.now()}
+	for _, m := range metrics {
+		snapshot.Metrics = append(snapshot.Metrics, m.snapshot())
+	}
+	return snapshot
+}
+
+func (m *metric) snapshot() *MetricSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ms := &MetricSnapshot{Name: m.name, Kind: m.kind}
+	if m.kind == KindHistogram {
+		ms.Buckets = slices.Clone(m.buckets)
+	}
+	for _, s := range m.series {
+		ss := &SeriesSnapshot{Labels: s.labels.clone()}
+		switch m.kind {
+		case KindCounter:
+			ss.Count = s.count
+			for i, count := range s.window.counts {
+				if count != 0 {
+					if ss.Window == nil {
+						ss.Window = make(map[int64]int64)
+					}
+					ss.Window[s.window.minutes[i]] = count
+				}
+			}
+		case KindHistogram:
+			ss.Sum = s.value
+			ss.Buckets = slices.Clone(s.buckets)
+			ss.Total = s.total
+		}
+		ms.Series = append(ms.Series, ss)
+	}
+	slices.SortFunc(ms.Series, func(a, b *SeriesSnapshot) int { return strings.Compare(a.Labels.key(), b.Labels.key()) })
+	return ms
+}
+
+// Restore adds a snapshot to r. Metrics used as another kind and histogram series
+// whose buckets differ from those declared are skipped; window counts older than
+// MaxWindow are dropped.
+func (r *Registry) Restore(snapshot *Snapshot) {
+	if snapshot == nil {
+		return
+	}
+	oldest := minuteOf(r.now()) - windowSlots + 1
+	for _, ms := range snapshot.Metrics {
+		if ms.Kind != KindCounter && ms.Kind != KindHistogram {
+			continue
+		}
+		m := r.metric(ms.Name, ms.Kind)
+		if m == nil {
+			continue
+		}
+		m.mu.Lock()
+		if ms.Kind == KindHistogram && len(m.series) == 0 && len(ms.Buckets) > 0 {
+			m.buckets = slices.Clone(ms.Buckets)
+		}
+		for _, ss := range ms.Series {
+			switch ms.Kind {
+			case KindCounter:
+				s := m.get(ss.Labels)
+				s.count += ss.Count
+				for minute, count := range ss.Window {
+					if minute >= oldest {
+						s.window.addMinute(minute, count)
+					}
+				}
+			case KindHistogram:
+				if !slices.Equal(ms.Buckets, m.buckets) || len(ss.Buckets) != len(m.buckets)+1 {
+					continue
+				}
+				s := m.get(ss.Labels)
+				for i, count := range ss.Buckets {
+					s.buckets[i] += count
+				}
+				s.value += ss.Sum
+				s.total += ss.Total
+			}
+		}
+		m.mu.Unlock()
+	}
+}
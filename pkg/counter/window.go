@@ -0,0 +1,77 @@
+package counter
+
+import "time"
+
+const (
+	windowResolution = time.Minute
+	windowSlots      = 60
+	// MaxWindow is the longest duration Window and Rate look back
+	MaxWindow = windowResolution * windowSlots
+)
+
+// window is a ring of per-minute counts covering the last MaxWindow
+type window struct {
+	counts [windowSlots]int64
+	// the minute, in minutes since the epoch, each slot counts
+	minutes [windowSlots]int64
+}
+
+func minuteOf(t time.Time) int64 {
+	return t.Unix() / int64(windowResolution/time.Second)
+}
+
+func (w *window) add(now time.Time, delta int64) {
+	w.addMinute(minuteOf(now), delta)
+}
+
+func (w *window) addMinute(minute int64, delta int64) {
+	i := minute % windowSlots
+	if w.minutes[i] != minute {
+		w.minutes[i] = minute
+		w.counts[i] = 0
+	}
+	w.counts[i] += delta
+}
+
+// sum returns the counts of the last d, including the current partial minute
+func (w *window) sum(now time.Time, d time.Duration) int64 {
+	n := int64((d + windowResolution - 1) / windowResolution)
+	n = min(max(n, 1), windowSlots)
+	current := minuteOf(now)
+	var sum int64
+	for minute := current - n + 1; minute <= current; minute++ {
+		if i := minute % windowSlots; w.minutes[i] == minute {
+			sum += w.counts[i]
+		}
+	}
+	return sum
+}
+
+// Window returns what was added in the last d to the series of a counter whose
+// labels contain match, nil matching every series. It has minute resolution and
+// looks back at most MaxWindow.
+func (r *Registry) Window(name string, match Labels, d time.Duration) int64 {
+	m := r.metric(name, KindCounter)
+	if m == nil {
+		return 0
+	}
+	now := r.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sum int64
+	for _, s := range m.series {
+		if s.labels.contains(match) {
+			sum += s.window.sum(now, d)
+		}
+	}
+	return sum
+}
+
+// Rate returns Window per second
+func (r *Registry) Rate(name string, match Labels, d time.Duration) float64 {
+	d = min(d, MaxWindow)
+	if d <= 0 {
+		return 0
+	}
+	return float64(r.Window(name, match, d)) / d.Seconds()
+}
@@ -0,0 +1,169 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	defaultPort    = "53"
+	defaultTimeout = 5 * time.Second
+	maxMessageSize = 4096
+)
+
+// Record is one A/AAAA answer, TTL is 0 when the resolver doesn't expose it.
+type Record struct {
+	IP  string `json:"ip"`
+	TTL uint32 `json:"ttl"`
+}
+
+type Resolver interface {
+	Resolve(ctx context.Context, host string) ([]Record, error)
+}
+
+// SystemResolver uses the operating system resolver, which hides TTLs.
+type SystemResolver struct{}
+
+func (SystemResolver) Resolve(ctx context.Context, host string) ([]Record, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []Record{{IP: ip.Unmap().String()}}, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, ip := range ips {
+		records = append(records, Record{IP: ip.Unmap().String()})
+	}
+	return records, nil
+}
+
+// Client queries a DNS server directly over UDP to learn the TTLs.
+type Client struct {
+	Server string
+}
+
+// NewClient accepts "1.1.1.1" or "1.1.1.1:53".
+func NewClient(server string) *Client {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, defaultPort)
+	}
+	return &Client{Server: server}
+}
+
+func (c *Client) Resolve(ctx context.Context, host string) ([]Record, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []Record{{IP: ip.Unmap().String()}}, nil
+	}
+
+	var records []Record
+	var errs []error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := c.query(ctx, host, qtype)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		records = append(records, answers...)
+	}
+	if len(records) == 0 {
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return nil, fmt.Errorf("no records for %s", host)
+	}
+	return records, nil
+}
+
+func (c *Client) query(ctx context.Context, host string, qtype dnsmessage.Type) ([]Record, error) {
+	name, err := dnsmessage.NewName(fqdn(host))
+	if err != nil {
+		return nil, err
+	}
+
+	id := uint16(rand.UintN(1 << 16))
+	request := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packet, err := request.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", c.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+
+		var response dnsmessage.Message
+		if err := response.Unpack(buffer[:n]); err != nil {
+			return nil, err
+		}
+		// ignore stray datagrams from earlier queries
+		if response.Header.ID != id || !response.Header.Response {
+			continue
+		}
+		if response.Header.RCode != dnsmessage.RCodeSuccess {
+			return nil, fmt.Errorf("dns %s %s: %s", qtype, host, response.Header.RCode)
+		}
+		return answerRecords(response.Answers), nil
+	}
+}
+
+// answerRecords keeps the A/AAAA answers, a CNAME chain is followed by the server.
+func answerRecords(answers []dnsmessage.Resource) []Record {
+	var records []Record
+	for _, answer := range answers {
+		var ip netip.Addr
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			ip = netip.AddrFrom16(body.AAAA)
+		default:
+			continue
+		}
+		records = append(records, Record{IP: ip.Unmap().String(), TTL: answer.Header.TTL})
+	}
+	return records
+}
+
+// fqdn returns host as a fully qualified name
+func fqdn(host string) string {
+	if len(host) > 0 && host[len(host)-1] == '.' {
+		return host
+	}
+	return host + "."
+}
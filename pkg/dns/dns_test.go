@@ -0,0 +1,88 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveDNS answers A queries with 203.0.113.1 (ttl 300) and AAAA queries
+// with no records.
+func serveDNS(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buffer := make([]byte, maxMessageSize)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			var request dnsmessage.Message
+			if err := request.Unpack(buffer[:n]); err != nil {
+				continue
+			}
+
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: request.Header.ID, Response: true},
+				Questions: request.Questions,
+			}
+			question := request.Questions[0]
+			if question.Type == dnsmessage.TypeA {
+				response.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+					Body:   &dnsmessage.AResource{A: [4]byte{203, 0, 113, 1}},
+				}}
+			}
+			packet, err := response.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packet, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestClientResolve(t *testing.T) {
+	client := NewClient(serveDNS(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	records, err := client.Resolve(ctx, "cdn.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0] != (Record{IP: "203.0.113.1", TTL: 300}) {
+		t.Errorf("Resolve = %v", records)
+	}
+}
+
+func TestClientResolveIP(t *testing.T) {
+	records, err := NewClient("127.0.0.1").Resolve(context.Background(), "2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].IP != "2001:db8::1" {
+		t.Errorf("Resolve = %v", records)
+	}
+}
+
+func TestNewClientDefaultPort(t *testing.T) {
+	if server := NewClient("1.1.1.1").Server; server != "1.1.1.1:53" {
+		t.Errorf("Server = %s", server)
+	}
+	if server := NewClient("[2606:4700::1111]:5353").Server; server != "[2606:4700::1111]:5353" {
+		t.Errorf("Server = %s", server)
+	}
+}
@@ -0,0 +1,446 @@
+// Package expr implements a small filter language over named attributes:
+//
+//	protocol in (vless, trojan) and security == reality and country != CN and latency < 500ms and udp
+//
+// Operators are `==` (or `=`), `!=`, `<`, `<=`, `>`, `>=`, `~` (regexp),
+// `in (a, b)` and `not in (a, b)`, combined with `and`, `or`, `not` and
+// parentheses. A bare attribute name tests a boolean attribute.
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Kind int
+
+const (
+	String Kind = iota
+	Number
+	// Duration literals take a unit (500ms, 2s, 1h, 3d), bare numbers are milliseconds
+	Duration
+	Bool
+	// List attributes match when any element matches, e.g. tags
+	List
+)
+
+// Schema declares the attributes an expression may use.
+type Schema map[string]Kind
+
+// Attributes supplies values of the schema kinds: string, float64,
+// time.Duration, bool and []string. ok is false for unknown values,
+// every comparison with an unknown value is false.
+type Attributes interface {
+	Attribute(name string) (value any, ok bool)
+}
+
+type Expr struct {
+	text string
+	root node
+}
+
+// Parse compiles text against schema, an empty text matches everything.
+func Parse(text string, schema Schema) (*Expr, error) {
+	tokens, err := lex(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, schema: schema}
+
+	var root node = constant(true)
+	if len(tokens) > 0 {
+		root, err = p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos < len(tokens) {
+			return nil, fmt.Errorf("unexpected %q at %d", tokens[p.pos].text, tokens[p.pos].pos)
+		}
+	}
+	return &Expr{text: text, root: root}, nil
+}
+
+func (e *Expr) Match(attributes Attributes) bool {
+	if e == nil {
+		return true
+	}
+	return e.root.eval(attributes)
+}
+
+func (e *Expr) String() string {
+	return e.text
+}
+
+type node interface {
+	eval(attributes Attributes) bool
+}
+
+type constant bool
+
+func (c constant) eval(Attributes) bool { return bool(c) }
+
+type and []node
+
+func (n and) eval(attributes Attributes) bool {
+	for _, child := range n {
+		if !child.eval(attributes) {
+			return false
+		}
+	}
+	return true
+}
+
+type or []node
+
+func (n or) eval(attributes Attributes) bool {
+	for _, child := range n {
+		if child.eval(attributes) {
+			return true
+		}
+	}
+	return false
+}
+
+type not struct{ node }
+
+func (n not) eval(attributes Attributes) bool { return !n.node.eval(attributes) }
+
+// comparison is `name op value`, or `name in (values)` with op "in"
+type comparison struct {
+	name   string
+	kind   Kind
+	op     string
+	values []any
+	regexp *regexp.Regexp
+}
+
+func (c *comparison) eval(attributes Attributes) bool {
+	value, ok := attributes.Attribute(c.name)
+	if !ok || value == nil {
+		return false
+	}
+
+	switch c.op {
+	case "in":
+		return slices.ContainsFunc(c.values, func(literal any) bool { return equal(c.kind, value, literal) })
+	case "not in":
+		return !slices.ContainsFunc(c.values, func(literal any) bool { return equal(c.kind, value, literal) })
+	case "==":
+		return equal(c.kind, value, c.values[0])
+	case "!=":
+		return !equal(c.kind, value, c.values[0])
+	case "~":
+		if list, ok := value.([]string); ok {
+			return slices.ContainsFunc(list, c.regexp.MatchString)
+		}
+		text, ok := value.(string)
+		return ok && c.regexp.MatchString(text)
+	}
+
+	order, ok := compare(value, c.values[0])
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func equal(kind Kind, value any, literal any) bool {
+	switch kind {
+	case String:
+		text, ok := value.(string)
+		return ok && strings.EqualFold(text, literal.(string))
+	case List:
+		list, ok := value.([]string)
+		return ok && slices.ContainsFunc(list, func(item string) bool { return strings.EqualFold(item, literal.(string)) })
+	}
+	order, ok := compare(value, literal)
+	return ok && order == 0
+}
+
+func compare(value any, literal any) (int, bool) {
+	switch value := value.(type) {
+	case float64:
+		return cmp(value, literal.(float64)), true
+	case time.Duration:
+		return cmp(value, literal.(time.Duration)), true
+	case bool:
+		if value == literal.(bool) {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func cmp[T float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	schema Schema
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) next() (*token, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	return t, nil
+}
+
+func (p *parser) keyword(words ...string) bool {
+	t := p.peek()
+	if t == nil || t.kind != wordToken {
+		return false
+	}
+	if slices.Contains(words, strings.ToLower(t.text)) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) symbol(symbols ...string) bool {
+	t := p.peek()
+	if t != nil && t.kind == symbolToken && slices.Contains(symbols, t.text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	var children or
+	for {
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		if !p.keyword("or") && !p.symbol("||") {
+			break
+		}
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return children, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var children and
+	for {
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		if !p.keyword("and") && !p.symbol("&&") {
+			break
+		}
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return children, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.keyword("not") || p.symbol("!") {
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return not{child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.symbol("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, p.expected(")")
+		}
+		return inner, nil
+	}
+
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind != wordToken {
+		return nil, fmt.Errorf("expected attribute at %d, got %q", t.pos, t.text)
+	}
+	name := strings.ToLower(t.text)
+	kind, ok := p.schema[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown attribute %q at %d", t.text, t.pos)
+	}
+	c := &comparison{name: name, kind: kind}
+
+	switch {
+	case p.keyword("in"):
+		c.op = "in"
+		c.values, err = p.parseList(kind)
+		return c, err
+	case p.peekNotIn():
+		c.op = "not in"
+		c.values, err = p.parseList(kind)
+		return c, err
+	case p.symbol("==", "="):
+		c.op = "=="
+	case p.symbol("!=", "<", "<=", ">", ">=", "~"):
+		c.op = p.tokens[p.pos-1].text
+	default:
+		// a bare boolean attribute
+		if kind != Bool {
+			return nil, fmt.Errorf("attribute %q is not a boolean, expected an operator after it", name)
+		}
+		c.op = "=="
+		c.values = []any{true}
+		return c, nil
+	}
+
+	if c.op == "~" {
+		if kind != String && kind != List {
+			return nil, fmt.Errorf("operator ~ needs a string attribute, %q is not", name)
+		}
+		literal, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		c.regexp, err = regexp.Compile("(?i)" + literal.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp at %d: %w", literal.pos, err)
+		}
+		return c, nil
+	}
+	if strings.ContainsAny(c.op, "<>") && (kind == String || kind == List || kind == Bool) {
+		return nil, fmt.Errorf("operator %s needs a number or duration attribute, %q is not", c.op, name)
+	}
+
+	value, err := p.parseValue(kind)
+	if err != nil {
+		return nil, err
+	}
+	c.values = []any{value}
+	return c, nil
+}
+
+func (p *parser) peekNotIn() bool {
+	if p.pos+1 < len(p.tokens) &&
+		p.tokens[p.pos].kind == wordToken && strings.EqualFold(p.tokens[p.pos].text, "not") &&
+		p.tokens[p.pos+1].kind == wordToken && strings.EqualFold(p.tokens[p.pos+1].text, "in") {
+		p.pos += 2
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseList(kind Kind) ([]any, error) {
+	if !p.symbol("(") {
+		return nil, p.expected("(")
+	}
+	var values []any
+	for {
+		value, err := p.parseValue(kind)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.symbol(")") {
+			return values, nil
+		}
+		if !p.symbol(",") {
+			return nil, p.expected(", or )")
+		}
+	}
+}
+
+func (p *parser) parseValue(kind Kind) (any, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == symbolToken {
+		return nil, fmt.Errorf("expected value at %d, got %q", t.pos, t.text)
+	}
+
+	value, err := parseLiteral(kind, t.text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q at %d: %w", t.text, t.pos, err)
+	}
+	return value, nil
+}
+
+func parseLiteral(kind Kind, text string) (any, error) {
+	switch kind {
+	case Number:
+		if percent, ok := strings.CutSuffix(text, "%"); ok {
+			value, err := strconv.ParseFloat(percent, 64)
+			return value / 100, err
+		}
+		return strconv.ParseFloat(text, 64)
+	case Duration:
+		return parseDuration(text)
+	case Bool:
+		return strconv.ParseBool(text)
+	}
+	return text, nil
+}
+
+// parseDuration extends time.ParseDuration with days, bare numbers are milliseconds
+func parseDuration(text string) (time.Duration, error) {
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return time.Duration(number * float64(time.Millisecond)), nil
+	}
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		number, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(number * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(text)
+}
+
+func (p *parser) expected(what string) error {
+	if t := p.peek(); t != nil {
+		return fmt.Errorf("expected %s at %d, got %q", what, t.pos, t.text)
+	}
+	return fmt.Errorf("expected %s at end of filter", what)
+}
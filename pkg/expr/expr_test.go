@@ -0,0 +1,118 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSchema = Schema{
+	"protocol":     String,
+	"security":     String,
+	"country":      String,
+	"name":         String,
+	"latency":      Duration,
+	"age":          Duration,
+	"success_rate": Number,
+	"udp":          Bool,
+	"tags":         List,
+}
+
+type testAttributes map[string]any
+
+func (attributes testAttributes) Attribute(name string) (any, bool) {
+	value, ok := attributes[name]
+	return value, ok
+}
+
+var testNode = testAttributes{
+	"protocol":     "vless",
+	"security":     "reality",
+	"country":      "JP",
+	"name":         "Tokyo 01",
+	"latency":      320 * time.Millisecond,
+	"age":          72 * time.Hour,
+	"success_rate": 0.95,
+	"udp":          true,
+	"tags":         []string{"streaming", "fast"},
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"", true},
+		{"protocol in (vless, trojan) and security == reality and country != CN and latency < 500ms and udp", true},
+		{"protocol in (vmess, trojan)", false},
+		{"protocol not in (vmess, trojan)", true},
+		{"PROTOCOL = VLESS", true},
+		{"country == cn or latency <= 320", true},
+		{"not udp", false},
+		{"!(country == JP)", false},
+		{"latency > 1s", false},
+		{"age >= 3d", true},
+		{"age < 2d", false},
+		{"success_rate >= 90%", true},
+		{"success_rate > 0.99", false},
+		{"tags == fast", true},
+		{"tags != fast", false},
+		{"tags in (gaming, streaming)", true},
+		{"tags ~ ^stream", true},
+		{`name ~ "tokyo \d+"`, true},
+		{`name == 'Tokyo 01'`, true},
+		{"udp == false", false},
+		{"country == CN or country == JP and udp", true},
+		{"(country == CN or country == JP) and not udp", false},
+		{"country == JP && latency < 500ms || protocol == vmess", true},
+	}
+	for _, test := range tests {
+		expr, err := Parse(test.filter, testSchema)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.filter, err)
+			continue
+		}
+		if got := expr.Match(testNode); got != test.want {
+			t.Errorf("Match(%q) = %v, want %v", test.filter, got, test.want)
+		}
+	}
+}
+
+func TestMatchUnknownValue(t *testing.T) {
+	unmeasured := testAttributes{"protocol": "vless"}
+	for _, filter := range []string{"latency < 500ms", "latency >= 500ms", "latency != 500ms", "country in (JP)", "udp"} {
+		expr, err := Parse(filter, testSchema)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", filter, err)
+		}
+		if expr.Match(unmeasured) {
+			t.Errorf("Match(%q) on unknown value = true, want false", filter)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		filter string
+		err    string
+	}{
+		{"speed > 1", "unknown attribute"},
+		{"country", "not a boolean"},
+		{"country < JP", "needs a number or duration"},
+		{"latency < fast", "invalid value"},
+		{"latency ~ 1", "needs a string"},
+		{"protocol in vless", "expected ("},
+		{"protocol in (vless", "expected , or )"},
+		{"(udp", "expected )"},
+		{"udp udp", "unexpected"},
+		{"country == ", "unexpected end"},
+		{`name == "tokyo`, "unterminated"},
+		{"name ~ (", "invalid regexp"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.filter, testSchema)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", test.filter, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	// quoted strings are words that never act as keywords or symbols
+	quotedToken
+	symbolToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// longest symbols first
+var symbols = []string{"==", "!=", "<=", ">=", "&&", "||", "=", "<", ">", "~", "!", "(", ")", ","}
+
+func lex(text string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(text); {
+		r := rune(text[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '"' || r == '\'':
+			end := strings.IndexByte(text[i+1:], text[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{kind: quotedToken, text: text[i+1 : i+1+end], pos: i})
+			i += end + 2
+			continue
+		}
+
+		if symbol := matchSymbol(text[i:]); len(symbol) > 0 {
+			tokens = append(tokens, token{kind: symbolToken, text: symbol, pos: i})
+			i += len(symbol)
+			continue
+		}
+
+		start := i
+		for i < len(text) && !unicode.IsSpace(rune(text[i])) && !strings.ContainsRune(`"'`, rune(text[i])) && len(matchSymbol(text[i:])) == 0 {
+			i++
+		}
+		tokens = append(tokens, token{kind: wordToken, text: text[start:i], pos: start})
+	}
+	return tokens, nil
+}
+
+func matchSymbol(text string) string {
+	for _, symbol := range symbols {
+		if strings.HasPrefix(text, symbol) {
+			return symbol
+		}
+	}
+	return ""
+}
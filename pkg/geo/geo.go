@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"strings"
+)
+
+// Info is what the offline databases know about an IP.
+type Info struct {
+	Country      string `json:"country,omitempty"`
+	City         string `json:"city,omitempty"`
+	ASN          uint   `json:"asn,omitempty"`
+	Organization string `json:"organization,omitempty"`
+}
+
+type Database interface {
+	// Lookup returns nil when the database has no data for ip
+	Lookup(ip netip.Addr) (*Info, error)
+	Close() error
+}
+
+// Open picks the reader by file extension: MaxMind `.mmdb` or xray `geoip.dat`.
+func Open(path string) (Database, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		return OpenMMDB(path)
+	case ".dat":
+		return OpenGeoIPDat(path)
+	}
+	return nil, fmt.Errorf("unsupport geo database: %s", path)
+}
+
+// Databases combines several databases, e.g. a city and an ASN mmdb,
+// the first database that knows a field wins.
+type Databases []Database
+
+// OpenAll opens every path, databases opened before a failure are closed.
+func OpenAll(paths []string) (Databases, error) {
+	var databases Databases
+	for _, path := range paths {
+		database, err := Open(path)
+		if err != nil {
+			databases.Close()
+			return nil, err
+		}
+		databases = append(databases, database)
+	}
+	return databases, nil
+}
+
+func (databases Databases) Lookup(ip netip.Addr) (*Info, error) {
+	var merged *Info
+	for _, database := range databases {
+		info, err := database.Lookup(ip)
+		if err != nil {
+			return nil, err
+		}
+		if info == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &Info{}
+		}
+		merged.merge(info)
+	}
+	return merged, nil
+}
+
+func (databases Databases) Close() error {
+	var errs []error
+	for _, database := range databases {
+		if err := database.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("close geo databases: %v", errs)
+	}
+	return nil
+}
+
+func (info *Info) merge(other *Info) {
+	if len(info.Country) == 0 {
+		info.Country = other.Country
+	}
+	if len(info.City) == 0 {
+		info.City = other.City
+	}
+	if info.ASN == 0 {
+		info.ASN = other.ASN
+	}
+	if len(info.Organization) == 0 {
+		info.Organization = other.Organization
+	}
+}
@@ -0,0 +1,176 @@
+package geo
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xtls/xray-core/app/router"
+	"google.golang.org/protobuf/proto"
+)
+
+// testdata/city.mmdb:
+//
+//	203.0.113.0/28  JP Tokyo
+//	203.0.113.16/28 US Los Angeles
+//	2001:db8::/48   DE
+//
+// testdata/asn.mmdb:
+//
+//	203.0.113.0/24 AS64500 Example Net
+//	2001:db8::/32  AS64501 Example Six
+func openTestdata(t *testing.T, names ...string) Databases {
+	t.Helper()
+
+	var paths []string
+	for _, name := range names {
+		paths = append(paths, filepath.Join("testdata", name))
+	}
+	databases, err := OpenAll(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { databases.Close() })
+	return databases
+}
+
+func writeGeoIPDat(t *testing.T) string {
+	t.Helper()
+
+	list := &router.GeoIPList{Entry: []*router.GeoIP{
+		{CountryCode: "JP", Cidr: []*router.CIDR{
+			{Ip: netip.MustParseAddr("203.0.113.0").AsSlice(), Prefix: 28},
+			// v4 networks written as v4-mapped v6
+			{Ip: netip.MustParseAddr("::ffff:198.51.100.0").AsSlice(), Prefix: 120},
+		}},
+		{CountryCode: "DE", Cidr: []*router.CIDR{
+			{Ip: netip.MustParseAddr("2001:db8::").AsSlice(), Prefix: 32},
+		}},
+		// not countries
+		{CountryCode: "PRIVATE", Cidr: []*router.CIDR{
+			{Ip: netip.MustParseAddr("10.0.0.0").AsSlice(), Prefix: 8},
+		}},
+		{CountryCode: "US", ReverseMatch: true, Cidr: []*router.CIDR{
+			{Ip: netip.MustParseAddr("192.0.2.0").AsSlice(), Prefix: 24},
+		}},
+	}}
+	data, err := proto.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "geoip.dat")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMMDB(t *testing.T) {
+	city := openTestdata(t, "city.mmdb")
+	asn := openTestdata(t, "asn.mmdb")
+
+	tests := []struct {
+		db   Databases
+		ip   string
+		want *Info
+	}{
+		{city, "203.0.113.1", &Info{Country: "JP", City: "Tokyo"}},
+		{city, "::ffff:203.0.113.17", &Info{Country: "US", City: "Los Angeles"}},
+		{city, "2001:db8::1", &Info{Country: "DE"}},
+		{city, "203.0.113.100", nil},
+		{asn, "203.0.113.100", &Info{ASN: 64500, Organization: "Example Net"}},
+		{asn, "2001:db8:1::1", &Info{ASN: 64501, Organization: "Example Six"}},
+		{asn, "192.0.2.1", nil},
+	}
+	for _, tt := range tests {
+		got, err := tt.db.Lookup(netip.MustParseAddr(tt.ip))
+		if err != nil {
+			t.Fatalf("Lookup(%s): %v", tt.ip, err)
+		}
+		if !equalInfo(got, tt.want) {
+			t.Errorf("Lookup(%s) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestGeoIPDat(t *testing.T) {
+	databases, err := OpenAll([]string{writeGeoIPDat(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer databases.Close()
+
+	tests := []struct {
+		ip   string
+		want *Info
+	}{
+		{"203.0.113.15", &Info{Country: "JP"}},
+		{"203.0.113.16", nil},
+		{"198.51.100.1", &Info{Country: "JP"}},
+		{"2001:db8:ffff::1", &Info{Country: "DE"}},
+		{"10.1.2.3", nil},
+		{"192.0.2.1", nil},
+	}
+	for _, tt := range tests {
+		got, err := databases.Lookup(netip.MustParseAddr(tt.ip))
+		if err != nil {
+			t.Fatalf("Lookup(%s): %v", tt.ip, err)
+		}
+		if !equalInfo(got, tt.want) {
+			t.Errorf("Lookup(%s) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// the first database that knows a field wins
+func TestDatabasesMerge(t *testing.T) {
+	databases := append(openTestdata(t, "asn.mmdb", "city.mmdb"), mustOpen(t, writeGeoIPDat(t)))
+
+	tests := []struct {
+		ip   string
+		want *Info
+	}{
+		{"203.0.113.1", &Info{Country: "JP", City: "Tokyo", ASN: 64500, Organization: "Example Net"}},
+		{"203.0.113.100", &Info{ASN: 64500, Organization: "Example Net"}},
+		{"198.51.100.1", &Info{Country: "JP"}},
+		{"2001:db8::1", &Info{Country: "DE", ASN: 64501, Organization: "Example Six"}},
+		{"192.0.2.1", nil},
+	}
+	for _, tt := range tests {
+		got, err := databases.Lookup(netip.MustParseAddr(tt.ip))
+		if err != nil {
+			t.Fatalf("Lookup(%s): %v", tt.ip, err)
+		}
+		if !equalInfo(got, tt.want) {
+			t.Errorf("Lookup(%s) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open(filepath.Join("testdata", "city.csv")); err == nil {
+		t.Error("opened a database with an unknown extension")
+	}
+	if _, err := OpenAll([]string{filepath.Join("testdata", "city.mmdb"), filepath.Join("testdata", "missing.mmdb")}); err == nil {
+		t.Error("opened a missing database")
+	}
+}
+
+func mustOpen(t *testing.T, path string) Database {
+	t.Helper()
+
+	database, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func equalInfo(got, want *Info) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	return *got == *want
+}
@@ -0,0 +1,68 @@
+package geo
+
+import (
+	"net/netip"
+	"os"
+
+	"github.com/xtls/xray-core/app/router"
+	"go4.org/netipx"
+	"google.golang.org/protobuf/proto"
+)
+
+// GeoIPDat answers country lookups from xray's geoip.dat, it has no city or ASN.
+type GeoIPDat struct {
+	countries map[string]*netipx.IPSet
+}
+
+func OpenGeoIPDat(path string) (*GeoIPDat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list router.GeoIPList
+	if err := proto.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	countries := make(map[string]*netipx.IPSet)
+	for _, entry := range list.Entry {
+		// geoip.dat also carries lists like "private" or "cloudflare"
+		if len(entry.CountryCode) != 2 || entry.ReverseMatch {
+			continue
+		}
+
+		var builder netipx.IPSetBuilder
+		for _, cidr := range entry.Cidr {
+			ip, ok := netip.AddrFromSlice(cidr.Ip)
+			if !ok {
+				continue
+			}
+			bits := int(cidr.Prefix)
+			if ip.Is4In6() {
+				ip, bits = ip.Unmap(), bits-96
+			}
+			builder.AddPrefix(netip.PrefixFrom(ip, bits))
+		}
+		set, err := builder.IPSet()
+		if err != nil {
+			return nil, err
+		}
+		countries[entry.CountryCode] = set
+	}
+	return &GeoIPDat{countries: countries}, nil
+}
+
+func (db *GeoIPDat) Lookup(ip netip.Addr) (*Info, error) {
+	ip = ip.Unmap()
+	for country, set := range db.countries {
+		if set.Contains(ip) {
+			return &Info{Country: country}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (db *GeoIPDat) Close() error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package geo
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbRecord covers the GeoLite2/GeoIP2 City, Country and ASN layouts,
+// a database fills whichever fields it has.
+type mmdbRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+type MMDB struct {
+	reader *maxminddb.Reader
+}
+
+func OpenMMDB(path string) (*MMDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MMDB{reader: reader}, nil
+}
+
+func (db *MMDB) Lookup(ip netip.Addr) (*Info, error) {
+	var record mmdbRecord
+	_, ok, err := db.reader.LookupNetwork(net.IP(ip.Unmap().AsSlice()), &record)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	info := &Info{
+		Country:      strings.ToUpper(record.Country.IsoCode),
+		City:         record.City.Names["en"],
+		ASN:          record.AutonomousSystemNumber,
+		Organization: record.AutonomousSystemOrganization,
+	}
+	if *info == (Info{}) {
+		return nil, nil
+	}
+	return info, nil
+}
+
+func (db *MMDB) Close() error {
+	return db.reader.Close()
+}
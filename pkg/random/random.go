@@ -1,6 +1,7 @@
 package random
 
 import (
+	"math"
 	"math/rand"
 	"sort"
 )
@@ -34,3 +35,31 @@ func Pick[T any](items []T, weights []int) T {
 
 	return items[sort.SearchInts(prefixSums, rand.Intn(prefixSums[len(prefixSums)-1]))]
 }
+
+// Beta 从 Beta(alpha, beta) 分布采样，alpha 和 beta 必须为正
+func Beta(alpha, beta float64) float64 {
+	x := gamma(alpha)
+	y := gamma(beta)
+	return x / (x + y)
+}
+
+// gamma 从 Gamma(shape, 1) 分布采样（Marsaglia-Tsang），shape < 1 时用 Gamma(shape+1) 变换
+func gamma(shape float64) float64 {
+	if shape < 1 {
+		return gamma(shape+1) * math.Pow(rand.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rand.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rand.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
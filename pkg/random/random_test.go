@@ -0,0 +1,32 @@
+package random
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBeta(t *testing.T) {
+	tests := []struct {
+		alpha, beta float64
+	}{
+		{1, 1},
+		{2, 8},
+		{30, 10},
+		{0.5, 0.5},
+	}
+	const samples = 20000
+	for _, test := range tests {
+		var sum float64
+		for range samples {
+			x := Beta(test.alpha, test.beta)
+			if x < 0 || x > 1 || math.IsNaN(x) {
+				t.Fatalf("Beta(%v, %v) = %v", test.alpha, test.beta, x)
+			}
+			sum += x
+		}
+		want := test.alpha / (test.alpha + test.beta)
+		if mean := sum / samples; math.Abs(mean-want) > 0.02 {
+			t.Errorf("mean of Beta(%v, %v) = %.3f, want %.3f", test.alpha, test.beta, mean, want)
+		}
+	}
+}
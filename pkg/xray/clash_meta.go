@@ -13,7 +13,9 @@ import (
 // https://github.com/MetaCubeX/mihomo/blob/Alpha/docs/config.yaml
 
 type ClashYaml struct {
-	Proxies []ClashProxy `yaml:"proxies,omitempty"`
+	Proxies     []ClashProxy      `yaml:"proxies,omitempty"`
+	ProxyGroups []ClashProxyGroup `yaml:"proxy-groups,omitempty"`
+	Rules       []string          `yaml:"rules,omitempty"`
 }
 
 type ClashProxy struct {
@@ -22,12 +24,14 @@ type ClashProxy struct {
 	Server   string `yaml:"server,omitempty"`
 	Port     uint16 `yaml:"port,omitempty"`
 	Uuid     string `yaml:"uuid,omitempty"`
+	AlterId  *int   `yaml:"alterId,omitempty"`
 	Cipher   string `yaml:"cipher,omitempty"`
 	Username string `yaml:"username,omitempty"`
 	Password string `yaml:"password,omitempty"`
 
-	Udp        bool `yaml:"udp,omitempty"`
-	udpOverTcp bool `yaml:"udp-over-tcp,omitempty"`
+	Udp               bool `yaml:"udp,omitempty"`
+	UdpOverTcp        bool `yaml:"udp-over-tcp,omitempty"`
+	UdpOverTcpVersion int  `yaml:"udp-over-tcp-version,omitempty"`
 
 	Tls            bool     `yaml:"tls,omitempty"`
 	SkipCertVerify bool     `yaml:"skip-cert-verify,omitempty"`
@@ -45,7 +49,11 @@ type ClashProxy struct {
 	PluginOpts *ClashProxyPluginOpts `yaml:"plugin-opts,omitempty"`
 	WsOpts     *ClashProxyWsOpts     `yaml:"ws-opts,omitempty"`
 	GrpcOpts   *ClashProxyGrpcOpts   `yaml:"grpc-opts,omitempty"`
+	H2Opts     *ClashProxyH2Opts     `yaml:"h2-opts,omitempty"`
+	HttpOpts   *ClashProxyHttpOpts   `yaml:"http-opts,omitempty"`
+	XhttpOpts  *ClashProxyXhttpOpts  `yaml:"xhttp-opts,omitempty"`
 	SsOpts     *ClashProxySsOpts     `yaml:"ss-opts,omitempty"`
+	Smux       *ClashProxySmux       `yaml:"smux,omitempty"`
 
 	// the below are fields of hysteria2.
 	// although xray doesn't support hysteria2,
@@ -74,8 +82,9 @@ type ClashProxyPluginOpts struct {
 }
 
 type ClashProxyWsOpts struct {
-	Path    string                   `yaml:"path,omitempty"`
-	Headers *ClashProxyWsOptsHeaders `yaml:"headers,omitempty"`
+	Path             string                   `yaml:"path,omitempty"`
+	Headers          *ClashProxyWsOptsHeaders `yaml:"headers,omitempty"`
+	V2rayHttpUpgrade bool                     `yaml:"v2ray-http-upgrade,omitempty"`
 }
 
 type ClashProxyWsOptsHeaders struct {
@@ -86,6 +95,46 @@ type ClashProxyGrpcOpts struct {
 	GrpcServiceName string `yaml:"grpc-service-name,omitempty"`
 }
 
+type ClashProxyH2Opts struct {
+	Host []string `yaml:"host,omitempty"`
+	Path string   `yaml:"path,omitempty"`
+}
+
+type ClashProxyHttpOpts struct {
+	Method  string              `yaml:"method,omitempty"`
+	Path    []string            `yaml:"path,omitempty"`
+	Headers map[string][]string `yaml:"headers,omitempty"`
+}
+
+type ClashProxyXhttpOpts struct {
+	Host          string            `yaml:"host,omitempty"`
+	Path          string            `yaml:"path,omitempty"`
+	Mode          string            `yaml:"mode,omitempty"`
+	Headers       map[string]string `yaml:"headers,omitempty"`
+	NoGrpcHeader  bool              `yaml:"no-grpc-header,omitempty"`
+	XPaddingBytes string            `yaml:"x-padding-bytes,omitempty"`
+}
+
+type ClashProxySmux struct {
+	Enabled        bool   `yaml:"enabled,omitempty"`
+	Protocol       string `yaml:"protocol,omitempty"`
+	MaxConnections int    `yaml:"max-connections,omitempty"`
+	MinStreams     int    `yaml:"min-streams,omitempty"`
+	MaxStreams     int    `yaml:"max-streams,omitempty"`
+	Padding        bool   `yaml:"padding,omitempty"`
+}
+
+type ClashProxyGroup struct {
+	Name      string   `yaml:"name,omitempty"`
+	Type      string   `yaml:"type,omitempty"`
+	Proxies   []string `yaml:"proxies,omitempty"`
+	Use       []string `yaml:"use,omitempty"`
+	Url       string   `yaml:"url,omitempty"`
+	Interval  int      `yaml:"interval,omitempty"`
+	Tolerance int      `yaml:"tolerance,omitempty"`
+	Strategy  string   `yaml:"strategy,omitempty"`
+}
+
 type ClashProxySsOpts struct {
 	Enabled  bool   `yaml:"enabled,omitempty"`
 	Method   string `yaml:"method,omitempty"`
@@ -110,13 +159,17 @@ func (clash ClashYaml) xrayConfig() *conf.Config {
 
 	var outbounds []conf.OutboundDetourConfig
 	for _, proxy := range clash.Proxies {
+		// proxy-groups and rules refer to proxies by name, which is the outbound tag
 		if outbound, err := proxy.outbound(); err == nil {
 			outbounds = append(outbounds, *outbound)
 		} else {
 			fmt.Println(err)
 		}
 	}
-	xray.OutboundConfigs = outbounds
+
+	routing, builtinOutbounds := clash.routerConfig(outbounds)
+	xray.OutboundConfigs = append(outbounds, builtinOutbounds...)
+	xray.RouterConfig = routing
 
 	return xray
 }
@@ -170,6 +223,8 @@ func (proxy ClashProxy) shadowsocksOutbound() (*conf.OutboundDetourConfig, error
 	server.Port = proxy.Port
 	server.Cipher = proxy.Cipher
 	server.Password = proxy.Password
+	server.UoT = proxy.UdpOverTcp
+	server.UoTVersion = proxy.UdpOverTcpVersion
 
 	var settings conf.ShadowsocksClientConfig
 	settings.Servers = []*conf.ShadowsocksServerTarget{server}
@@ -182,7 +237,7 @@ func (proxy ClashProxy) shadowsocksOutbound() (*conf.OutboundDetourConfig, error
 
 	if len(proxy.Plugin) != 0 {
 		if proxy.Plugin != "v2ray-plugin" {
-			return nil, fmt.Errorf("unsupport ss plugin: obfs")
+			return nil, fmt.Errorf("unsupport ss plugin: %s", proxy.Plugin)
 		}
 		if proxy.PluginOpts == nil {
 			return nil, fmt.Errorf("unsupport ss plugin-opts: nil")
@@ -204,6 +259,7 @@ func (proxy ClashProxy) shadowsocksOutbound() (*conf.OutboundDetourConfig, error
 		streamSetting.WSSettings = wsSettings
 
 		if proxy.PluginOpts.Tls {
+			streamSetting.Security = "tls"
 			tlsSettings := &conf.TLSConfig{}
 			tlsSettings.Fingerprint = proxy.PluginOpts.Fingerprint
 			tlsSettings.Insecure = proxy.PluginOpts.SkipCertVerify
@@ -356,14 +412,39 @@ func (proxy ClashProxy) trojanOutbound() (*conf.OutboundDetourConfig, error) {
 
 func (proxy ClashProxy) streamSettings(outbound conf.OutboundDetourConfig) (*conf.StreamConfig, error) {
 	streamSettings := &conf.StreamConfig{}
-	network := proxy.Network
-	if len(proxy.Network) == 0 {
-		network = "raw"
+	network, err := proxy.network()
+	if err != nil {
+		return nil, err
 	}
 	transportProtocol := conf.TransportProtocol(network)
 	streamSettings.Network = &transportProtocol
 
 	switch network {
+	case "raw":
+		if proxy.HttpOpts != nil {
+			var request XrayRawSettingsHeaderRequest
+			request.Method = proxy.HttpOpts.Method
+			request.Path = proxy.HttpOpts.Path
+			host := proxy.HttpOpts.Headers["Host"]
+			if len(host) > 0 {
+				var headers XrayRawSettingsHeaderRequestHeaders
+				headers.Host = host
+				request.Headers = &headers
+			}
+			var header XrayRawSettingsHeader
+			header.Type = "http"
+			header.Request = &request
+
+			rawSettings := &conf.TCPConfig{}
+
+			headerRawMessage, err := convertJsonToRawMessage(header)
+			if err != nil {
+				return nil, err
+			}
+			rawSettings.HeaderConfig = headerRawMessage
+
+			streamSettings.RAWSettings = rawSettings
+		}
 	case "ws":
 		if proxy.WsOpts != nil {
 			wsSettings := &conf.WebSocketConfig{}
@@ -373,17 +454,73 @@ func (proxy ClashProxy) streamSettings(outbound conf.OutboundDetourConfig) (*con
 			wsSettings.Path = proxy.WsOpts.Path
 			streamSettings.WSSettings = wsSettings
 		}
+	case "httpupgrade":
+		if proxy.WsOpts != nil {
+			httpupgradeSettings := &conf.HttpUpgradeConfig{}
+			if proxy.WsOpts.Headers != nil {
+				httpupgradeSettings.Host = proxy.WsOpts.Headers.Host
+			}
+			httpupgradeSettings.Path = proxy.WsOpts.Path
+			streamSettings.HTTPUPGRADESettings = httpupgradeSettings
+		}
 	case "grpc":
 		if proxy.GrpcOpts != nil {
 			grpcSettings := &conf.GRPCConfig{}
 			grpcSettings.ServiceName = proxy.GrpcOpts.GrpcServiceName
 			streamSettings.GRPCSettings = grpcSettings
 		}
+	case "xhttp":
+		xhttpSettings := &conf.SplitHTTPConfig{}
+		if proxy.XhttpOpts != nil {
+			xhttpSettings.Host = proxy.XhttpOpts.Host
+			xhttpSettings.Path = proxy.XhttpOpts.Path
+			xhttpSettings.Mode = proxy.XhttpOpts.Mode
+
+			extra := map[string]any{}
+			if len(proxy.XhttpOpts.Headers) > 0 {
+				extra["headers"] = proxy.XhttpOpts.Headers
+			}
+			if proxy.XhttpOpts.NoGrpcHeader {
+				extra["noGRPCHeader"] = true
+			}
+			if len(proxy.XhttpOpts.XPaddingBytes) > 0 {
+				extra["xPaddingBytes"] = proxy.XhttpOpts.XPaddingBytes
+			}
+			if len(extra) > 0 {
+				extraRawMessage, err := convertJsonToRawMessage(extra)
+				if err != nil {
+					return nil, err
+				}
+				xhttpSettings.Extra = extraRawMessage
+			}
+		}
+		streamSettings.XHTTPSettings = xhttpSettings
 	}
 	proxy.parseSecurity(streamSettings, outbound)
 	return streamSettings, nil
 }
 
+// network maps the clash network name to the xray transport.
+// smux is deliberately ignored: clash speaks sing-mux which is not compatible
+// with xray's mux.cool, and servers accept plain connections either way.
+func (proxy ClashProxy) network() (string, error) {
+	switch proxy.Network {
+	case "", "tcp":
+		return "raw", nil
+	case "http":
+		// clash "http" network is the http/1.1 header obfuscation of raw tcp
+		return "raw", nil
+	case "h2":
+		// xray has removed the h2 transport and no xray transport talks to an h2 server
+		return "", fmt.Errorf("unsupport clash network: %s", proxy.Network)
+	case "ws":
+		if proxy.WsOpts != nil && proxy.WsOpts.V2rayHttpUpgrade {
+			return "httpupgrade", nil
+		}
+	}
+	return proxy.Network, nil
+}
+
 func (proxy ClashProxy) parseSecurity(streamSettings *conf.StreamConfig, outbound conf.OutboundDetourConfig) {
 	tlsSettings := &conf.TLSConfig{}
 	realitySettings := &conf.REALITYConfig{}
@@ -0,0 +1,466 @@
+package xray
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/proxy/vless"
+)
+
+const (
+	ClashAutoGroup = "Auto"
+
+	clashTestUrl       = "http://www.gstatic.com/generate_204"
+	clashTestInterval  = 300
+	clashTestTolerance = 50
+)
+
+var DefaultClashRules = []string{
+	"GEOIP,LAN,DIRECT,no-resolve",
+	"GEOSITE,CN,DIRECT",
+	"GEOIP,CN,DIRECT",
+	"MATCH," + ClashAutoGroup,
+}
+
+// Convert outbounds to a clash meta config.
+// healthy[i] marks outbounds[i] as a member of the url-test group, when no
+// outbound is healthy the group falls back to all proxies.
+// rules defaults to DefaultClashRules.
+// Each of groups becomes another url-test group over its members.
+func ConvertOutboundsToClashYaml(outbounds []conf.OutboundDetourConfig, healthy []bool, rules []string, groups ...OutboundGroup) ([]byte, error) {
+	clash := ClashYaml{}
+
+	names := reservedNames(ClashAutoGroup, groups)
+	proxyNames := make([]string, len(outbounds))
+	var group []string
+	for i, outbound := range outbounds {
+		proxy, err := clashProxy(outbound)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		proxy.Name = uniqueName(proxy.Name, names)
+		proxyNames[i] = proxy.Name
+		clash.Proxies = append(clash.Proxies, *proxy)
+
+		if i < len(healthy) && healthy[i] {
+			group = append(group, proxy.Name)
+		}
+	}
+	if len(clash.Proxies) == 0 {
+		return nil, fmt.Errorf("no valid outbounds")
+	}
+
+	if len(group) == 0 {
+		for _, proxy := range clash.Proxies {
+			group = append(group, proxy.Name)
+		}
+	}
+	clash.ProxyGroups = []ClashProxyGroup{{
+		Name:      ClashAutoGroup,
+		Type:      "url-test",
+		Proxies:   group,
+		Url:       clashTestUrl,
+		Interval:  clashTestInterval,
+		Tolerance: clashTestTolerance,
+	}}
+	for _, outboundGroup := range groups {
+		members := outboundGroup.members(proxyNames)
+		if len(members) == 0 {
+			continue
+		}
+		clash.ProxyGroups = append(clash.ProxyGroups, ClashProxyGroup{
+			Name:      outboundGroup.Name,
+			Type:      "url-test",
+			Proxies:   members,
+			Url:       clashTestUrl,
+			Interval:  clashTestInterval,
+			Tolerance: clashTestTolerance,
+		})
+	}
+
+	if rules == nil {
+		rules = DefaultClashRules
+	}
+	clash.Rules = rules
+
+	return yaml.Marshal(clash)
+}
+
+// OutboundGroup is an extra url-test group in exported configs,
+// Members[i] marks outbounds[i] as a member.
+type OutboundGroup struct {
+	Name    string
+	Members []bool
+}
+
+// members returns the exported names of the group members, names[i] is
+// empty for outbounds that failed to convert.
+func (group OutboundGroup) members(names []string) []string {
+	var members []string
+	for i, name := range names {
+		if i < len(group.Members) && group.Members[i] && len(name) > 0 {
+			members = append(members, name)
+		}
+	}
+	return members
+}
+
+// reservedNames keeps proxies from taking the names of groups
+func reservedNames(autoGroup string, groups []OutboundGroup) map[string]bool {
+	names := map[string]bool{autoGroup: true}
+	for _, group := range groups {
+		names[group.Name] = true
+	}
+	return names
+}
+
+func uniqueName(name string, names map[string]bool) string {
+	unique := name
+	for i := 2; names[unique]; i++ {
+		unique = fmt.Sprintf("%s %d", name, i)
+	}
+	names[unique] = true
+	return unique
+}
+
+func clashProxy(outbound conf.OutboundDetourConfig) (*ClashProxy, error) {
+	if outbound.Settings == nil {
+		return nil, fmt.Errorf("no settings in outbound: %s", outbound.Protocol)
+	}
+
+	proxy := &ClashProxy{}
+	proxy.Udp = true
+
+	var err error
+	switch outbound.Protocol {
+	case "shadowsocks":
+		err = proxy.fromShadowsocks(*outbound.Settings)
+	case "vmess":
+		err = proxy.fromVMess(*outbound.Settings)
+	case "vless":
+		err = proxy.fromVLess(*outbound.Settings)
+	case "socks":
+		err = proxy.fromSocks(*outbound.Settings)
+	case "trojan":
+		err = proxy.fromTrojan(*outbound.Settings)
+	default:
+		err = fmt.Errorf("unsupport clash protocol: %s", outbound.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	proxy.Name = getOutboundName(outbound)
+	if len(proxy.Name) == 0 {
+		proxy.Name = fmt.Sprintf("%s:%d", proxy.Server, proxy.Port)
+	}
+
+	err = proxy.fromStreamSettings(outbound.StreamSetting)
+	if err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+func (proxy *ClashProxy) fromShadowsocks(settingsRawMessage json.RawMessage) error {
+	var settings conf.ShadowsocksClientConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in shadowsocks outbound")
+	}
+
+	server := settings.Servers[0]
+	proxy.Type = "ss"
+	proxy.Server = addressString(server.Address)
+	proxy.Port = server.Port
+	proxy.Cipher = server.Cipher
+	proxy.Password = server.Password
+	proxy.UdpOverTcp = server.UoT
+	proxy.UdpOverTcpVersion = server.UoTVersion
+	return nil
+}
+
+func (proxy *ClashProxy) fromVMess(settingsRawMessage json.RawMessage) error {
+	var settings conf.VMessOutboundConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Receivers) == 0 || len(settings.Receivers[0].Users) == 0 {
+		return fmt.Errorf("no users in vmess outbound")
+	}
+
+	vnext := settings.Receivers[0]
+	var account conf.VMessAccount
+	err = json.Unmarshal(vnext.Users[0], &account)
+	if err != nil {
+		return err
+	}
+
+	alterId := 0
+	proxy.Type = "vmess"
+	proxy.Server = addressString(vnext.Address)
+	proxy.Port = vnext.Port
+	proxy.Uuid = account.ID
+	proxy.AlterId = &alterId
+	proxy.Cipher = account.Security
+	if len(proxy.Cipher) == 0 {
+		proxy.Cipher = "auto"
+	}
+	return nil
+}
+
+func (proxy *ClashProxy) fromVLess(settingsRawMessage json.RawMessage) error {
+	var settings conf.VLessOutboundConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Vnext) == 0 || len(settings.Vnext[0].Users) == 0 {
+		return fmt.Errorf("no users in vless outbound")
+	}
+
+	vnext := settings.Vnext[0]
+	var account vless.Account
+	err = json.Unmarshal(vnext.Users[0], &account)
+	if err != nil {
+		return err
+	}
+
+	proxy.Type = "vless"
+	proxy.Server = addressString(vnext.Address)
+	proxy.Port = vnext.Port
+	proxy.Uuid = account.Id
+	proxy.Flow = account.Flow
+	return nil
+}
+
+func (proxy *ClashProxy) fromSocks(settingsRawMessage json.RawMessage) error {
+	var settings conf.SocksClientConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in socks outbound")
+	}
+
+	server := settings.Servers[0]
+	proxy.Type = "socks5"
+	proxy.Server = addressString(server.Address)
+	proxy.Port = server.Port
+	if len(server.Users) > 0 {
+		var account conf.SocksAccount
+		err := json.Unmarshal(server.Users[0], &account)
+		if err != nil {
+			return err
+		}
+		proxy.Username = account.Username
+		proxy.Password = account.Password
+	}
+	return nil
+}
+
+func (proxy *ClashProxy) fromTrojan(settingsRawMessage json.RawMessage) error {
+	var settings conf.TrojanClientConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in trojan outbound")
+	}
+
+	server := settings.Servers[0]
+	proxy.Type = "trojan"
+	proxy.Server = addressString(server.Address)
+	proxy.Port = server.Port
+	proxy.Password = server.Password
+	return nil
+}
+
+func (proxy *ClashProxy) fromStreamSettings(streamSettings *conf.StreamConfig) error {
+	if streamSettings == nil {
+		return nil
+	}
+
+	network := "tcp"
+	if streamSettings.Network != nil {
+		var err error
+		network, err = streamSettings.Network.Build()
+		if err != nil {
+			return err
+		}
+	}
+
+	// shadowsocks only carries transports through the v2ray-plugin
+	if proxy.Type == "ss" {
+		return proxy.fromShadowsocksPlugin(network, streamSettings)
+	}
+
+	switch network {
+	case "tcp":
+		rawSettings := streamSettings.RAWSettings
+		if rawSettings == nil {
+			rawSettings = streamSettings.TCPSettings
+		}
+		if rawSettings == nil || rawSettings.HeaderConfig == nil {
+			break
+		}
+		var header XrayRawSettingsHeader
+		err := json.Unmarshal(rawSettings.HeaderConfig, &header)
+		if err != nil {
+			return err
+		}
+		if header.Type != "http" {
+			break
+		}
+
+		proxy.Network = "http"
+		httpOpts := &ClashProxyHttpOpts{}
+		if header.Request != nil {
+			httpOpts.Method = header.Request.Method
+			httpOpts.Path = header.Request.Path
+			if header.Request.Headers != nil && len(header.Request.Headers.Host) > 0 {
+				httpOpts.Headers = map[string][]string{"Host": header.Request.Headers.Host}
+			}
+		}
+		proxy.HttpOpts = httpOpts
+	case "websocket":
+		proxy.Network = "ws"
+		if wsSettings := streamSettings.WSSettings; wsSettings != nil {
+			proxy.WsOpts = &ClashProxyWsOpts{Path: wsSettings.Path}
+			if len(wsSettings.Host) > 0 {
+				proxy.WsOpts.Headers = &ClashProxyWsOptsHeaders{Host: wsSettings.Host}
+			}
+		}
+	case "httpupgrade":
+		proxy.Network = "ws"
+		proxy.WsOpts = &ClashProxyWsOpts{V2rayHttpUpgrade: true}
+		if httpupgradeSettings := streamSettings.HTTPUPGRADESettings; httpupgradeSettings != nil {
+			proxy.WsOpts.Path = httpupgradeSettings.Path
+			if len(httpupgradeSettings.Host) > 0 {
+				proxy.WsOpts.Headers = &ClashProxyWsOptsHeaders{Host: httpupgradeSettings.Host}
+			}
+		}
+	case "grpc":
+		proxy.Network = "grpc"
+		if grpcSettings := streamSettings.GRPCSettings; grpcSettings != nil {
+			proxy.GrpcOpts = &ClashProxyGrpcOpts{GrpcServiceName: grpcSettings.ServiceName}
+		}
+	case "splithttp":
+		proxy.Network = "xhttp"
+		if xhttpSettings := streamSettings.XHTTPSettings; xhttpSettings != nil {
+			xhttpOpts, err := clashXhttpOpts(xhttpSettings)
+			if err != nil {
+				return err
+			}
+			proxy.XhttpOpts = xhttpOpts
+		}
+	default:
+		return fmt.Errorf("unsupport clash network: %s", network)
+	}
+
+	proxy.fromSecurity(streamSettings)
+	return nil
+}
+
+// clashXhttpOpts keeps the xhttp options clash understands, from the
+// settings and from their extra, which wins like it does in xray
+func clashXhttpOpts(xhttpSettings *conf.SplitHTTPConfig) (*ClashProxyXhttpOpts, error) {
+	xhttpOpts := &ClashProxyXhttpOpts{
+		Host: xhttpSettings.Host,
+		Path: xhttpSettings.Path,
+		Mode: xhttpSettings.Mode,
+	}
+
+	settings := []*conf.SplitHTTPConfig{xhttpSettings}
+	if xhttpSettings.Extra != nil {
+		var extra conf.SplitHTTPConfig
+		err := json.Unmarshal(xhttpSettings.Extra, &extra)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, &extra)
+	}
+	for _, setting := range settings {
+		if len(setting.Headers) > 0 {
+			xhttpOpts.Headers = setting.Headers
+		}
+		if setting.NoGRPCHeader {
+			xhttpOpts.NoGrpcHeader = true
+		}
+		if setting.XPaddingBytes.Left != 0 || setting.XPaddingBytes.Right != 0 {
+			xhttpOpts.XPaddingBytes = setting.XPaddingBytes.String()
+		}
+	}
+	return xhttpOpts, nil
+}
+
+func (proxy *ClashProxy) fromShadowsocksPlugin(network string, streamSettings *conf.StreamConfig) error {
+	switch network {
+	case "tcp":
+		return nil
+	case "websocket":
+		proxy.Plugin = "v2ray-plugin"
+		pluginOpts := &ClashProxyPluginOpts{Mode: "websocket"}
+		if wsSettings := streamSettings.WSSettings; wsSettings != nil {
+			pluginOpts.Host = wsSettings.Host
+			pluginOpts.Path = wsSettings.Path
+		}
+		if streamSettings.Security == "tls" {
+			pluginOpts.Tls = true
+			if tlsSettings := streamSettings.TLSSettings; tlsSettings != nil {
+				pluginOpts.Fingerprint = tlsSettings.Fingerprint
+				pluginOpts.SkipCertVerify = tlsSettings.Insecure
+			}
+		}
+		proxy.PluginOpts = pluginOpts
+		return nil
+	}
+	return fmt.Errorf("unsupport ss plugin network: %s", network)
+}
+
+func (proxy *ClashProxy) fromSecurity(streamSettings *conf.StreamConfig) {
+	var serverName string
+	switch streamSettings.Security {
+	case "tls":
+		proxy.Tls = true
+		tlsSettings := streamSettings.TLSSettings
+		if tlsSettings == nil {
+			break
+		}
+		serverName = tlsSettings.ServerName
+		proxy.SkipCertVerify = tlsSettings.Insecure
+		proxy.ClientFingerprint = tlsSettings.Fingerprint
+		if tlsSettings.ALPN != nil {
+			proxy.Alpn = *tlsSettings.ALPN
+		}
+	case "reality":
+		proxy.Tls = true
+		realitySettings := streamSettings.REALITYSettings
+		if realitySettings == nil {
+			break
+		}
+		serverName = realitySettings.ServerName
+		proxy.ClientFingerprint = realitySettings.Fingerprint
+		proxy.RealityOpts = &ClashProxyRealityOpts{
+			PublicKey: realitySettings.PublicKey,
+			ShortId:   realitySettings.ShortId,
+		}
+	}
+
+	// trojan names the field sni, vmess and vless use servername
+	if proxy.Type == "trojan" {
+		proxy.Sni = serverName
+	} else {
+		proxy.Servername = serverName
+	}
+}
@@ -0,0 +1,138 @@
+package xray
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"gopkg.in/yaml.v3"
+)
+
+// proxyOutbounds drops the builtin outbounds clash rules add
+func proxyOutbounds(outbounds []conf.OutboundDetourConfig) []conf.OutboundDetourConfig {
+	var proxies []conf.OutboundDetourConfig
+	for _, outbound := range outbounds {
+		if outbound.Protocol != "freedom" && outbound.Protocol != "blackhole" {
+			proxies = append(proxies, outbound)
+		}
+	}
+	return proxies
+}
+
+func normalizeOutbounds(t *testing.T, outbounds []conf.OutboundDetourConfig) any {
+	t.Helper()
+
+	outboundsBytes, err := json.Marshal(outbounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, err := normalizeJson(outboundsBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return normalized
+}
+
+// import(export(import(x))) == import(x) for the clash testdata
+func TestClashExportRoundTrip(t *testing.T) {
+	for _, name := range []string{"clash.yaml", "clash_transports.yaml", "clash_routing.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			text, err := os.ReadFile(filepath.Join(shareTestdata, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			xray, err := ConvertShareLinksToXrayJson(string(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			outbounds := proxyOutbounds(xray.OutboundConfigs)
+
+			exported, err := ConvertOutboundsToClashYaml(outbounds, nil, nil)
+			if err != nil {
+				t.Fatalf("ConvertOutboundsToClashYaml: %v", err)
+			}
+			roundTrip, err := ConvertShareLinksToXrayJson(string(exported))
+			if err != nil {
+				t.Fatalf("re-import: %v\n%s", err, exported)
+			}
+
+			want := normalizeOutbounds(t, outbounds)
+			got := normalizeOutbounds(t, proxyOutbounds(roundTrip.OutboundConfigs))
+			if !reflect.DeepEqual(want, got) {
+				gotBytes, _ := json.MarshalIndent(got, "", "  ")
+				t.Errorf("round trip mismatch, exported:\n%s\ngot:\n%s", exported, gotBytes)
+			}
+		})
+	}
+}
+
+func TestClashExportGroups(t *testing.T) {
+	text, err := os.ReadFile(filepath.Join(shareTestdata, "clash_routing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xray, err := ConvertShareLinksToXrayJson(string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hk, jp, us; a proxy named like the auto group is renamed
+	outbounds := proxyOutbounds(xray.OutboundConfigs)
+	outbounds[2].Tag = ClashAutoGroup
+
+	exported, err := ConvertOutboundsToClashYaml(outbounds, []bool{true, false, true}, nil,
+		OutboundGroup{Name: "JP", Members: []bool{false, true, false}},
+		OutboundGroup{Name: "Empty", Members: []bool{false, false, false}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clash ClashYaml
+	if err := yaml.Unmarshal(exported, &clash); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, proxy := range clash.Proxies {
+		names = append(names, proxy.Name)
+	}
+	if want := []string{"hk", "jp", "Auto 2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("proxies = %v, want %v", names, want)
+	}
+	groups := make(map[string][]string)
+	for _, group := range clash.ProxyGroups {
+		if group.Type != "url-test" {
+			t.Errorf("group %s type = %s", group.Name, group.Type)
+		}
+		groups[group.Name] = group.Proxies
+	}
+	want := map[string][]string{ClashAutoGroup: {"hk", "Auto 2"}, "JP": {"jp"}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("groups = %v, want %v", groups, want)
+	}
+	if !reflect.DeepEqual(clash.Rules, DefaultClashRules) {
+		t.Errorf("rules = %v", clash.Rules)
+	}
+
+	// without healthy outbounds the auto group falls back to every proxy
+	exported, err = ConvertOutboundsToClashYaml(outbounds, nil, []string{"MATCH,DIRECT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clash = ClashYaml{}
+	if err := yaml.Unmarshal(exported, &clash); err != nil {
+		t.Fatal(err)
+	}
+	if len(clash.ProxyGroups) != 1 || len(clash.ProxyGroups[0].Proxies) != 3 {
+		t.Errorf("fallback groups = %+v", clash.ProxyGroups)
+	}
+	if !reflect.DeepEqual(clash.Rules, []string{"MATCH,DIRECT"}) {
+		t.Errorf("rules = %v", clash.Rules)
+	}
+
+	if _, err := ConvertOutboundsToClashYaml(nil, nil, nil); err == nil {
+		t.Error("exporting no outbounds should fail")
+	}
+}
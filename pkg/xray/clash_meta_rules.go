@@ -0,0 +1,327 @@
+package xray
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xtls/xray-core/infra/conf"
+)
+
+const (
+	clashDirect = "DIRECT"
+	clashReject = "REJECT"
+
+	directOutboundTag = "direct"
+	blockOutboundTag  = "block"
+)
+
+// A select group routes to the entry picked in clash, which is the first one
+// listed until the user changes it, so it becomes a fixed target instead of a
+// balancer.
+const clashSelectGroup = "select"
+
+// proxy-group type -> xray balancer strategy
+var clashGroupStrategies = map[string]string{
+	"url-test":     "leastPing",
+	"fallback":     "leastPing",
+	"load-balance": "roundRobin",
+}
+
+// routerConfig maps proxy-groups to balancers and rules to routing rules.
+// The returned outbounds are the builtin direct/block outbounds referenced by the rules.
+func (clash ClashYaml) routerConfig(outbounds []conf.OutboundDetourConfig) (*conf.RouterConfig, []conf.OutboundDetourConfig) {
+	if len(clash.ProxyGroups) == 0 && len(clash.Rules) == 0 {
+		return nil, nil
+	}
+
+	proxyTags := make(map[string]bool)
+	for _, outbound := range outbounds {
+		proxyTags[outbound.Tag] = true
+	}
+
+	routing := &conf.RouterConfig{}
+	routing.Balancers = clash.balancers(proxyTags)
+	renamed := exactSelectors(outbounds, routing.Balancers)
+
+	targets := make(map[string]clashTarget)
+	for tag := range proxyTags {
+		if unique, ok := renamed[tag]; ok {
+			targets[tag] = clashTarget{outboundTag: unique}
+		} else {
+			targets[tag] = clashTarget{outboundTag: tag}
+		}
+	}
+	for _, balancer := range routing.Balancers {
+		targets[balancer.Tag] = clashTarget{balancerTag: balancer.Tag}
+	}
+	clash.selectTargets(targets)
+
+	builtins := make(map[string]bool)
+	for _, text := range clash.Rules {
+		rule, err := parseClashRule(text, targets)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if rule.OutboundTag == directOutboundTag || rule.OutboundTag == blockOutboundTag {
+			builtins[rule.OutboundTag] = true
+		}
+
+		ruleRawMessage, err := convertJsonToRawMessage(rule)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		routing.RuleList = append(routing.RuleList, ruleRawMessage)
+	}
+
+	var builtinOutbounds []conf.OutboundDetourConfig
+	if builtins[directOutboundTag] {
+		builtinOutbounds = append(builtinOutbounds, conf.OutboundDetourConfig{Protocol: "freedom", Tag: directOutboundTag})
+	}
+	if builtins[blockOutboundTag] {
+		builtinOutbounds = append(builtinOutbounds, conf.OutboundDetourConfig{Protocol: "blackhole", Tag: blockOutboundTag})
+	}
+
+	return routing, builtinOutbounds
+}
+
+// clashTarget is where a rule sends its traffic, either an outbound or a balancer.
+type clashTarget struct {
+	outboundTag string
+	balancerTag string
+}
+
+// balancers turns every proxy-group but select into a balancer over the
+// proxies it contains, nested groups are flattened. Proxy providers (`use`)
+// can't be resolved offline and are ignored.
+func (clash ClashYaml) balancers(proxyTags map[string]bool) []*conf.BalancingRule {
+	groups := make(map[string]ClashProxyGroup)
+	for _, group := range clash.ProxyGroups {
+		groups[group.Name] = group
+	}
+
+	var balancers []*conf.BalancingRule
+	for _, group := range clash.ProxyGroups {
+		if group.Type == clashSelectGroup {
+			continue
+		}
+		strategy, ok := clashGroupStrategies[group.Type]
+		if !ok {
+			fmt.Println(fmt.Errorf("unsupport proxy-group type: %s", group.Type))
+			continue
+		}
+
+		members := groupMembers(group, groups, proxyTags, map[string]bool{})
+		if len(members) == 0 {
+			fmt.Println(fmt.Errorf("proxy-group has no proxies: %s", group.Name))
+			continue
+		}
+
+		balancer := &conf.BalancingRule{}
+		balancer.Tag = group.Name
+		balancer.Selectors = conf.StringList(members)
+		balancer.Strategy.Type = strategy
+		balancer.FallbackTag = members[0]
+		balancers = append(balancers, balancer)
+	}
+	return balancers
+}
+
+// selectTargets adds the select groups to targets, each routes to the first
+// entry it lists that resolves to a proxy, a balancer or DIRECT/REJECT.
+func (clash ClashYaml) selectTargets(targets map[string]clashTarget) {
+	groups := make(map[string]ClashProxyGroup)
+	for _, group := range clash.ProxyGroups {
+		groups[group.Name] = group
+	}
+	for _, group := range clash.ProxyGroups {
+		if group.Type != clashSelectGroup {
+			continue
+		}
+		if target, ok := selectTarget(group, groups, targets, map[string]bool{}); ok {
+			targets[group.Name] = target
+		} else {
+			fmt.Println(fmt.Errorf("proxy-group has no proxies: %s", group.Name))
+		}
+	}
+}
+
+func selectTarget(group ClashProxyGroup, groups map[string]ClashProxyGroup, targets map[string]clashTarget, visited map[string]bool) (clashTarget, bool) {
+	if visited[group.Name] {
+		return clashTarget{}, false
+	}
+	visited[group.Name] = true
+
+	for _, name := range group.Proxies {
+		switch {
+		case name == clashDirect:
+			return clashTarget{outboundTag: directOutboundTag}, true
+		case strings.HasPrefix(name, clashReject):
+			return clashTarget{outboundTag: blockOutboundTag}, true
+		}
+		if sub, ok := groups[name]; ok && sub.Type == clashSelectGroup {
+			if target, ok := selectTarget(sub, groups, targets, visited); ok {
+				return target, true
+			}
+			continue
+		}
+		if target, ok := targets[name]; ok {
+			return target, true
+		}
+	}
+	return clashTarget{}, false
+}
+
+// exactSelectors renames the balancer members whose tag is a prefix of
+// another outbound tag, as xray selectors match tag prefixes and a member
+// "HK" would also pick "HK 2". The outbounds and balancers are updated in
+// place, the returned map goes from the old tag to the new one.
+func exactSelectors(outbounds []conf.OutboundDetourConfig, balancers []*conf.BalancingRule) map[string]string {
+	tags := map[string]bool{directOutboundTag: true, blockOutboundTag: true}
+	for _, outbound := range outbounds {
+		tags[outbound.Tag] = true
+	}
+	members := make(map[string]bool)
+	for _, balancer := range balancers {
+		for _, selector := range balancer.Selectors {
+			members[selector] = true
+		}
+	}
+
+	renamed := make(map[string]string)
+	for i := range outbounds {
+		tag := outbounds[i].Tag
+		if _, ok := renamed[tag]; ok || !members[tag] || !prefixOfOther(tag, tags) {
+			continue
+		}
+		delete(tags, tag)
+		unique := tag
+		for n := 1; tags[unique] || prefixOfOther(unique, tags); n++ {
+			unique = fmt.Sprintf("%s [%d]", tag, n)
+		}
+		tags[unique] = true
+		renamed[tag] = unique
+		outbounds[i].Tag = unique
+	}
+	if len(renamed) == 0 {
+		return renamed
+	}
+
+	for _, balancer := range balancers {
+		for i, selector := range balancer.Selectors {
+			if unique, ok := renamed[selector]; ok {
+				balancer.Selectors[i] = unique
+			}
+		}
+		if unique, ok := renamed[balancer.FallbackTag]; ok {
+			balancer.FallbackTag = unique
+		}
+	}
+	return renamed
+}
+
+// prefixOfOther reports whether tag is a prefix of any other tag
+func prefixOfOther(tag string, tags map[string]bool) bool {
+	for other := range tags {
+		if other != tag && strings.HasPrefix(other, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func groupMembers(group ClashProxyGroup, groups map[string]ClashProxyGroup, proxyTags map[string]bool, visited map[string]bool) []string {
+	if visited[group.Name] {
+		return nil
+	}
+	visited[group.Name] = true
+
+	var members []string
+	for _, name := range group.Proxies {
+		if proxyTags[name] {
+			members = append(members, name)
+			continue
+		}
+		if sub, ok := groups[name]; ok {
+			members = append(members, groupMembers(sub, groups, proxyTags, visited)...)
+		}
+	}
+
+	seen := make(map[string]bool)
+	unique := members[:0]
+	for _, member := range members {
+		if !seen[member] {
+			seen[member] = true
+			unique = append(unique, member)
+		}
+	}
+	return unique
+}
+
+// parseClashRule converts a clash rule like `DOMAIN-SUFFIX,google.com,Proxy`
+// into an xray field rule.
+func parseClashRule(text string, targets map[string]clashTarget) (*XrayRoutingRule, error) {
+	parts := strings.Split(text, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	ruleType := strings.ToUpper(parts[0])
+	var value, target string
+	switch {
+	case ruleType == "MATCH" && len(parts) >= 2:
+		target = parts[1]
+	case len(parts) >= 3:
+		value, target = parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("unsupport clash rule: %s", text)
+	}
+
+	rule := &XrayRoutingRule{}
+	rule.Type = "field"
+
+	switch ruleType {
+	case "DOMAIN":
+		rule.Domain = []string{"full:" + value}
+	case "DOMAIN-SUFFIX":
+		rule.Domain = []string{"domain:" + value}
+	case "DOMAIN-KEYWORD":
+		rule.Domain = []string{"keyword:" + value}
+	case "DOMAIN-REGEX":
+		rule.Domain = []string{"regexp:" + value}
+	case "GEOSITE":
+		rule.Domain = []string{"geosite:" + strings.ToLower(value)}
+	case "IP-CIDR", "IP-CIDR6":
+		rule.IP = []string{value}
+	case "GEOIP":
+		country := strings.ToLower(value)
+		if country == "lan" {
+			country = "private"
+		}
+		rule.IP = []string{"geoip:" + country}
+	case "DST-PORT":
+		rule.Port = value
+	case "NETWORK":
+		rule.Network = strings.ToLower(value)
+	case "MATCH":
+		rule.Network = "tcp,udp"
+	default:
+		return nil, fmt.Errorf("unsupport clash rule type: %s", text)
+	}
+
+	switch {
+	case target == clashDirect:
+		rule.OutboundTag = directOutboundTag
+	case strings.HasPrefix(target, clashReject):
+		rule.OutboundTag = blockOutboundTag
+	default:
+		resolved, ok := targets[target]
+		if !ok {
+			return nil, fmt.Errorf("unknown clash rule target: %s", text)
+		}
+		rule.OutboundTag, rule.BalancerTag = resolved.outboundTag, resolved.balancerTag
+	}
+
+	return rule, nil
+}
@@ -0,0 +1,159 @@
+package xray
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/proxy/vless"
+)
+
+// Endpoint returns the server address and port the outbound connects to.
+func Endpoint(outbound conf.OutboundDetourConfig) (string, uint16, error) {
+	if outbound.Settings == nil {
+		return "", 0, fmt.Errorf("no settings in outbound: %s", outbound.Protocol)
+	}
+	settings := *outbound.Settings
+
+	switch outbound.Protocol {
+	case "vmess":
+		var config conf.VMessOutboundConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", 0, err
+		}
+		if len(config.Receivers) > 0 {
+			return addressString(config.Receivers[0].Address), config.Receivers[0].Port, nil
+		}
+	case "vless":
+		var config conf.VLessOutboundConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", 0, err
+		}
+		if len(config.Vnext) > 0 {
+			return addressString(config.Vnext[0].Address), config.Vnext[0].Port, nil
+		}
+	case "shadowsocks":
+		var config conf.ShadowsocksClientConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", 0, err
+		}
+		if len(config.Servers) > 0 {
+			return addressString(config.Servers[0].Address), config.Servers[0].Port, nil
+		}
+	case "trojan":
+		var config conf.TrojanClientConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", 0, err
+		}
+		if len(config.Servers) > 0 {
+			return addressString(config.Servers[0].Address), config.Servers[0].Port, nil
+		}
+	case "socks":
+		var config conf.SocksClientConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", 0, err
+		}
+		if len(config.Servers) > 0 {
+			return addressString(config.Servers[0].Address), config.Servers[0].Port, nil
+		}
+	case "wireguard":
+		var config XrayWireGuardSettings
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", 0, err
+		}
+		if len(config.Peers) > 0 {
+			host, portText, err := net.SplitHostPort(config.Peers[0].Endpoint)
+			if err != nil {
+				return "", 0, err
+			}
+			port, err := strconv.ParseUint(portText, 10, 16)
+			if err != nil {
+				return "", 0, err
+			}
+			return host, uint16(port), nil
+		}
+	default:
+		return "", 0, fmt.Errorf("unsupport endpoint protocol: %s", outbound.Protocol)
+	}
+	return "", 0, fmt.Errorf("no server in %s outbound", outbound.Protocol)
+}
+
+// Credential returns what authenticates the outbound at its server,
+// outbounds sharing endpoint and credential are the same node.
+func Credential(outbound conf.OutboundDetourConfig) (string, error) {
+	if outbound.Settings == nil {
+		return "", fmt.Errorf("no settings in outbound: %s", outbound.Protocol)
+	}
+	settings := *outbound.Settings
+
+	switch outbound.Protocol {
+	case "vmess":
+		var config conf.VMessOutboundConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", err
+		}
+		if len(config.Receivers) > 0 && len(config.Receivers[0].Users) > 0 {
+			var account conf.VMessAccount
+			if err := json.Unmarshal(config.Receivers[0].Users[0], &account); err != nil {
+				return "", err
+			}
+			return account.ID, nil
+		}
+	case "vless":
+		var config conf.VLessOutboundConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", err
+		}
+		if len(config.Vnext) > 0 && len(config.Vnext[0].Users) > 0 {
+			var account vless.Account
+			if err := json.Unmarshal(config.Vnext[0].Users[0], &account); err != nil {
+				return "", err
+			}
+			return account.Id, nil
+		}
+	case "shadowsocks":
+		var config conf.ShadowsocksClientConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", err
+		}
+		if len(config.Servers) > 0 {
+			return config.Servers[0].Cipher + ":" + config.Servers[0].Password, nil
+		}
+	case "trojan":
+		var config conf.TrojanClientConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", err
+		}
+		if len(config.Servers) > 0 {
+			return config.Servers[0].Password, nil
+		}
+	case "socks":
+		var config conf.SocksClientConfig
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", err
+		}
+		if len(config.Servers) > 0 {
+			if len(config.Servers[0].Users) == 0 {
+				return "", nil
+			}
+			var account conf.SocksAccount
+			if err := json.Unmarshal(config.Servers[0].Users[0], &account); err != nil {
+				return "", err
+			}
+			return account.Username + ":" + account.Password, nil
+		}
+	case "wireguard":
+		var config XrayWireGuardSettings
+		if err := json.Unmarshal(settings, &config); err != nil {
+			return "", err
+		}
+		if len(config.Peers) > 0 {
+			return config.Peers[0].PublicKey, nil
+		}
+	default:
+		return "", fmt.Errorf("unsupport credential protocol: %s", outbound.Protocol)
+	}
+	return "", fmt.Errorf("no server in %s outbound", outbound.Protocol)
+}
@@ -0,0 +1,43 @@
+package xray
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"zhouxin.learn/go/vxrayui/pkg/hash"
+)
+
+const (
+	fingerprintLength = 16
+
+	outboundTagPrefix = "proxy-"
+)
+
+// Fingerprint identifies an outbound by its protocol settings and transport,
+// the display name and tag are ignored so renamed nodes keep their identity.
+func Fingerprint(outbound conf.OutboundDetourConfig) (string, error) {
+	outbound.SendThrough = nil
+	outbound.Tag = ""
+
+	outboundBytes, err := json.Marshal(outbound)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint, err := hash.CalculateHash(bytes.NewReader(outboundBytes))
+	if err != nil {
+		return "", err
+	}
+	return fingerprint[:fingerprintLength], nil
+}
+
+// OutboundTag derives a stable xray-safe tag from the fingerprint,
+// display names may contain anything and are kept out of the xray config.
+func OutboundTag(outbound conf.OutboundDetourConfig) (string, error) {
+	fingerprint, err := Fingerprint(outbound)
+	if err != nil {
+		return "", err
+	}
+	return outboundTagPrefix + fingerprint, nil
+}
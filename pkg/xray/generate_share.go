@@ -4,15 +4,26 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/xtls/xray-core/infra/conf"
 	"github.com/xtls/xray-core/proxy/vless"
 )
 
+type VMessLinkStyle int
+
+const (
+	// v2rayN base64 json, the most widely supported form
+	VMessLinkV2rayN VMessLinkStyle = iota
+	// VMessAEAD url form of https://github.com/XTLS/Xray-core/discussions/716
+	VMessLinkAEAD
+)
+
 // Convert XrayJson to share links.
-// VMess will generate VMessAEAD link.
+// VMess will generate v2rayN link.
 func ConvertXrayJsonToShareLinks(xrayBytes []byte) (string, error) {
 	var xray conf.Config
 
@@ -21,16 +32,20 @@ func ConvertXrayJsonToShareLinks(xrayBytes []byte) (string, error) {
 		return "", err
 	}
 
-	outbounds := xray.OutboundConfigs
+	return ConvertOutboundsToShareLinks(xray.OutboundConfigs, VMessLinkV2rayN)
+}
+
+// Convert outbounds to share links, one per line.
+func ConvertOutboundsToShareLinks(outbounds []conf.OutboundDetourConfig, vmessStyle VMessLinkStyle) (string, error) {
 	if len(outbounds) == 0 {
 		return "", fmt.Errorf("no valid outbounds")
 	}
 
 	var links []string
 	for _, outbound := range outbounds {
-		link, err := shareLink(outbound)
+		link, err := ShareLink(outbound, vmessStyle)
 		if err == nil {
-			links = append(links, link.String())
+			links = append(links, link)
 		}
 	}
 	if len(links) == 0 {
@@ -40,6 +55,23 @@ func ConvertXrayJsonToShareLinks(xrayBytes []byte) (string, error) {
 	return shareText, nil
 }
 
+// ShareLink generates the share link of a single outbound.
+func ShareLink(proxy conf.OutboundDetourConfig, vmessStyle VMessLinkStyle) (string, error) {
+	if proxy.Settings == nil {
+		return "", fmt.Errorf("no settings in outbound: %s", proxy.Protocol)
+	}
+
+	if proxy.Protocol == "vmess" && vmessStyle == VMessLinkV2rayN {
+		return vmessQrCodeLink(proxy)
+	}
+
+	link, err := shareLink(proxy)
+	if err != nil {
+		return "", err
+	}
+	return link.String(), nil
+}
+
 func shareLink(proxy conf.OutboundDetourConfig) (*url.URL, error) {
 	shareUrl := &url.URL{}
 
@@ -69,29 +101,45 @@ func shareLink(proxy conf.OutboundDetourConfig) (*url.URL, error) {
 		if err != nil {
 			return nil, err
 		}
+	default:
+		return nil, fmt.Errorf("unsupport share link protocol: %s", proxy.Protocol)
+	}
+	err := streamSettingsQuery(proxy, shareUrl)
+	if err != nil {
+		return nil, err
 	}
-	streamSettingsQuery(proxy, shareUrl)
 
 	return shareUrl, nil
 }
 
+func joinHostPort(address *conf.Address, port uint16) string {
+	return net.JoinHostPort(addressString(address), strconv.Itoa(int(port)))
+}
+
+// https://shadowsocks.org/doc/sip002.html
 func shadowsocksLink(proxy conf.OutboundDetourConfig, link *url.URL) error {
 	var settings conf.ShadowsocksClientConfig
 	err := json.Unmarshal(*proxy.Settings, &settings)
 	if err != nil {
 		return err
 	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in shadowsocks outbound")
+	}
 
 	link.Fragment = getOutboundName(proxy)
 	link.Scheme = "ss"
 
-	if len(settings.Servers) > 0 {
-		server := settings.Servers[0]
-		link.Host = fmt.Sprintf("%s:%d", server.Address, server.Port)
-		password := fmt.Sprintf("%s:%s", server.Cipher, server.Password)
-		username := base64.StdEncoding.EncodeToString([]byte(password))
-		link.User = url.User(username)
+	server := settings.Servers[0]
+	link.Host = joinHostPort(server.Address, server.Port)
+	// SIP002 requires plain percent-encoded userinfo for AEAD-2022 ciphers
+	if strings.HasPrefix(server.Cipher, "2022-") {
+		link.User = url.UserPassword(server.Cipher, server.Password)
+		return nil
 	}
+	password := fmt.Sprintf("%s:%s", server.Cipher, server.Password)
+	username := base64.RawURLEncoding.EncodeToString([]byte(password))
+	link.User = url.User(username)
 	return nil
 }
 
@@ -101,23 +149,24 @@ func vmessLink(proxy conf.OutboundDetourConfig, link *url.URL) error {
 	if err != nil {
 		return err
 	}
+	if len(settings.Receivers) == 0 || len(settings.Receivers[0].Users) == 0 {
+		return fmt.Errorf("no users in vmess outbound")
+	}
 
 	link.Fragment = getOutboundName(proxy)
 	link.Scheme = "vmess"
 
-	if len(settings.Receivers) > 0 {
-		vnext := settings.Receivers[0]
-		link.Host = fmt.Sprintf("%s:%d", vnext.Address, vnext.Port)
-		if len(vnext.Users) > 0 {
-			user := vnext.Users[0]
-			var account conf.VMessAccount
-			err := json.Unmarshal(user, &account)
-			if err != nil {
-				return err
-			}
-			link.User = url.User(account.ID)
-			link.RawQuery = addQuery(link.RawQuery, "encryption", account.Security)
-		}
+	vnext := settings.Receivers[0]
+	link.Host = joinHostPort(vnext.Address, vnext.Port)
+
+	var account conf.VMessAccount
+	err = json.Unmarshal(vnext.Users[0], &account)
+	if err != nil {
+		return err
+	}
+	link.User = url.User(account.ID)
+	if len(account.Security) > 0 {
+		link.RawQuery = addQuery(link.RawQuery, "encryption", account.Security)
 	}
 	return nil
 }
@@ -128,25 +177,28 @@ func vlessLink(proxy conf.OutboundDetourConfig, link *url.URL) error {
 	if err != nil {
 		return err
 	}
+	if len(settings.Vnext) == 0 || len(settings.Vnext[0].Users) == 0 {
+		return fmt.Errorf("no users in vless outbound")
+	}
 
 	link.Fragment = getOutboundName(proxy)
 	link.Scheme = "vless"
 
-	if len(settings.Vnext) > 0 {
-		vnext := settings.Vnext[0]
-		link.Host = fmt.Sprintf("%s:%d", vnext.Address, vnext.Port)
-		if len(vnext.Users) > 0 {
-			user := vnext.Users[0]
-			var account vless.Account
-			err := json.Unmarshal(user, &account)
-			if err != nil {
-				return err
-			}
-			link.User = url.User(account.Id)
-			if len(account.Flow) > 0 {
-				link.RawQuery = addQuery(link.RawQuery, "flow", account.Flow)
-			}
-		}
+	vnext := settings.Vnext[0]
+	link.Host = joinHostPort(vnext.Address, vnext.Port)
+
+	var account vless.Account
+	err = json.Unmarshal(vnext.Users[0], &account)
+	if err != nil {
+		return err
+	}
+	link.User = url.User(account.Id)
+	if len(account.Flow) > 0 {
+		link.RawQuery = addQuery(link.RawQuery, "flow", account.Flow)
+	}
+	// none is the default of the parser, omit it to keep links short
+	if len(account.Encryption) > 0 && account.Encryption != "none" {
+		link.RawQuery = addQuery(link.RawQuery, "encryption", account.Encryption)
 	}
 	return nil
 }
@@ -157,27 +209,25 @@ func socksLink(proxy conf.OutboundDetourConfig, link *url.URL) error {
 	if err != nil {
 		return err
 	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in socks outbound")
+	}
 
 	link.Fragment = getOutboundName(proxy)
 	link.Scheme = "socks"
 
-	if len(settings.Servers) > 0 {
-		server := settings.Servers[0]
-		link.Host = fmt.Sprintf("%s:%d", server.Address, server.Port)
-		if len(server.Users) == 0 {
-			username := base64.StdEncoding.EncodeToString([]byte(":"))
-			link.User = url.User(username)
-		} else {
-			user := server.Users[0]
-			var account conf.SocksAccount
-			err := json.Unmarshal(user, &account)
-			if err != nil {
-				return err
-			}
-			password := fmt.Sprintf("%s:%s", account.Username, account.Password)
-			username := base64.StdEncoding.EncodeToString([]byte(password))
-			link.User = url.User(username)
+	server := settings.Servers[0]
+	link.Host = joinHostPort(server.Address, server.Port)
+	if len(server.Users) > 0 {
+		user := server.Users[0]
+		var account conf.SocksAccount
+		err := json.Unmarshal(user, &account)
+		if err != nil {
+			return err
 		}
+		password := fmt.Sprintf("%s:%s", account.Username, account.Password)
+		username := base64.RawURLEncoding.EncodeToString([]byte(password))
+		link.User = url.User(username)
 	}
 	return nil
 }
@@ -188,22 +238,23 @@ func trojanLink(proxy conf.OutboundDetourConfig, link *url.URL) error {
 	if err != nil {
 		return err
 	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in trojan outbound")
+	}
 
 	link.Fragment = getOutboundName(proxy)
 	link.Scheme = "trojan"
 
-	if len(settings.Servers) > 0 {
-		server := settings.Servers[0]
-		link.Host = fmt.Sprintf("%s:%d", server.Address, server.Port)
-		link.User = url.User(server.Password)
-	}
+	server := settings.Servers[0]
+	link.Host = joinHostPort(server.Address, server.Port)
+	link.User = url.User(server.Password)
 	return nil
 }
 
-func streamSettingsQuery(proxy conf.OutboundDetourConfig, link *url.URL) {
+func streamSettingsQuery(proxy conf.OutboundDetourConfig, link *url.URL) error {
 	streamSettings := proxy.StreamSetting
 	if streamSettings == nil {
-		return
+		return nil
 	}
 	query := link.RawQuery
 
@@ -211,20 +262,35 @@ func streamSettingsQuery(proxy conf.OutboundDetourConfig, link *url.URL) {
 	if streamSettings.Network != nil {
 		network = string(*streamSettings.Network)
 	}
-	query = addQuery(query, "type", network)
+	transportProtocol := conf.TransportProtocol(network)
+	network, err := transportProtocol.Build()
+	if err != nil {
+		return err
+	}
+	// share links name the transports as v2rayN does, not as xray configs (raw, websocket, splithttp)
+	shareName := network
+	if name, ok := transportShareNames[network]; ok {
+		shareName = name
+	}
+	query = addQuery(query, "type", shareName)
 
-	if len(streamSettings.Security) == 0 {
-		streamSettings.Security = "none"
+	security := streamSettings.Security
+	if len(security) == 0 {
+		security = "none"
 	}
-	query = addQuery(query, "security", streamSettings.Security)
+	query = addQuery(query, "security", security)
 
 	switch network {
-	case "raw":
-		if streamSettings.RAWSettings == nil {
+	case "tcp":
+		rawSettings := streamSettings.RAWSettings
+		if rawSettings == nil {
+			rawSettings = streamSettings.TCPSettings
+		}
+		if rawSettings == nil {
 			break
 		}
 
-		headerConfig := streamSettings.RAWSettings.HeaderConfig
+		headerConfig := rawSettings.HeaderConfig
 		if headerConfig == nil {
 			break
 		}
@@ -252,7 +318,7 @@ func streamSettingsQuery(proxy conf.OutboundDetourConfig, link *url.URL) {
 				query = addQuery(query, "host", strings.Join(host, ","))
 			}
 		}
-	case "kcp":
+	case "mkcp":
 		if streamSettings.KCPSettings == nil {
 			break
 		}
@@ -275,7 +341,7 @@ func streamSettingsQuery(proxy conf.OutboundDetourConfig, link *url.URL) {
 		if len(headerType) > 0 {
 			query = addQuery(query, "headerType", headerType)
 		}
-	case "ws":
+	case "websocket":
 		if streamSettings.WSSettings == nil {
 			break
 		}
@@ -317,7 +383,7 @@ func streamSettingsQuery(proxy conf.OutboundDetourConfig, link *url.URL) {
 		if len(path) > 0 {
 			query = addQuery(query, "path", path)
 		}
-	case "xhttp":
+	case "splithttp":
 		if streamSettings.XHTTPSettings == nil {
 			break
 		}
@@ -339,7 +405,7 @@ func streamSettingsQuery(proxy conf.OutboundDetourConfig, link *url.URL) {
 		}
 	}
 
-	switch streamSettings.Security {
+	switch security {
 	case "tls":
 		if streamSettings.TLSSettings == nil {
 			break
@@ -391,6 +457,7 @@ func streamSettingsQuery(proxy conf.OutboundDetourConfig, link *url.URL) {
 	}
 
 	link.RawQuery = query
+	return nil
 }
 
 func addQuery(query string, key string, value string) string {
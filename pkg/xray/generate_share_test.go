@@ -0,0 +1,185 @@
+package xray
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xtls/xray-core/infra/conf"
+)
+
+var shareLinkCorpus = []string{
+	"vmess://eyJ2IjoiMiIsInBzIjoidm1lc3Mtd3MiLCJhZGQiOiJleGFtcGxlLmNvbSIsInBvcnQiOiI0NDMiLCJpZCI6IjJhMzI2M2UyLTg5MWMtNDQ0Ni1iNDc0LWE4YzYzYWNiNmUyNSIsImFpZCI6IjAiLCJzY3kiOiJhdXRvIiwibmV0Ijoid3MiLCJ0eXBlIjoibm9uZSIsImhvc3QiOiJleGFtcGxlLmNvbSIsInBhdGgiOiIvd3MiLCJ0bHMiOiJ0bHMiLCJzbmkiOiJleGFtcGxlLmNvbSJ9",
+	"vmess://eyJ2IjoiMiIsInBzIjoidm1lc3MtZ3JwYyIsImFkZCI6IjEuMi4zLjQiLCJwb3J0IjoyMDg3LCJpZCI6IjJhMzI2M2UyLTg5MWMtNDQ0Ni1iNDc0LWE4YzYzYWNiNmUyNSIsImFpZCI6MCwic2N5IjoiYXV0byIsIm5ldCI6ImdycGMiLCJ0eXBlIjoiZ3VuIiwicGF0aCI6ImdycGMiLCJ0bHMiOiJ0bHMiLCJzbmkiOiJleGFtcGxlLmNvbSIsImFscG4iOiJoMixodHRwLzEuMSIsImZwIjoiY2hyb21lIn0=",
+	"vmess://2a3263e2-891c-4446-b474-a8c63acb6e25@example.com:8080?encryption=auto&type=tcp&headerType=http&host=example.com&path=%2F#vmess-http",
+	"vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?encryption=none&flow=xtls-rprx-vision&security=reality&sni=www.microsoft.com&fp=chrome&pbk=SbVKOEMjK0sIlbwg4akyBg5mL5KZwwB-ed4eEE7YnRc&sid=6ba85179e30d4fc2&spx=%2F&type=tcp#vless-reality",
+	"vless://b831381d-6324-4d53-ad4f-8cda48b30811@[2001:db8::1]:443?security=tls&type=grpc&serviceName=grpc&mode=multi&sni=example.com&alpn=h2#vless-grpc",
+	"vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?type=xhttp&host=example.com&path=%2Fxhttp&mode=auto&security=tls&extra=%7B%22xPaddingBytes%22%3A%22100-1000%22%7D#vless-xhttp",
+	"vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:80?type=httpupgrade&host=example.com&path=%2Fup#vless-httpupgrade",
+	"trojan://pass+word@example.com:443?security=tls&sni=example.com&type=ws&host=example.com&path=%2Fws&allowInsecure=1#trojan-ws",
+	"ss://YWVzLTI1Ni1nY206cGFzcyt3b3JkLzE@1.2.3.4:8388#ss",
+	"ss://2022-blake3-aes-128-gcm:c2VjcmV0c2VjcmV0c2VjcmV0@1.2.3.4:8388#ss-2022",
+	"socks://dXNlcjpwYXNz@1.2.3.4:1080#socks",
+	"socks://1.2.3.4:1080#socks-anonymous",
+}
+
+func parseShareLink(t *testing.T, text string) *conf.OutboundDetourConfig {
+	t.Helper()
+
+	link, err := url.Parse(text)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", text, err)
+	}
+	shareLink := XrayShareLink{
+		Link:    link,
+		RawText: text,
+	}
+	outbound, err := shareLink.Outbound()
+	if err != nil {
+		t.Fatalf("Outbound(%q): %v", text, err)
+	}
+	return outbound
+}
+
+func marshalOutbound(t *testing.T, outbound *conf.OutboundDetourConfig) string {
+	t.Helper()
+
+	outboundBytes, err := json.Marshal(outbound)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return string(outboundBytes)
+}
+
+// parse(generate(x)) == x for every outbound of the corpus
+func TestShareLinkRoundTrip(t *testing.T) {
+	styles := map[string]VMessLinkStyle{
+		"v2rayN": VMessLinkV2rayN,
+		"aead":   VMessLinkAEAD,
+	}
+
+	for _, text := range shareLinkCorpus {
+		for styleName, style := range styles {
+			t.Run(styleName+"/"+text, func(t *testing.T) {
+				outbound := parseShareLink(t, text)
+
+				link, err := ShareLink(*outbound, style)
+				if err != nil {
+					t.Fatalf("ShareLink: %v", err)
+				}
+				roundTrip := parseShareLink(t, link)
+
+				want, got := marshalOutbound(t, outbound), marshalOutbound(t, roundTrip)
+				if want != got {
+					t.Errorf("round trip of %s\nwant %s\ngot  %s", link, want, got)
+				}
+			})
+		}
+	}
+}
+
+func TestShareLinkUnsupportedProtocol(t *testing.T) {
+	settings := json.RawMessage(`{}`)
+	outbound := conf.OutboundDetourConfig{Protocol: "freedom", Settings: &settings}
+	if _, err := ShareLink(outbound, VMessLinkV2rayN); err == nil {
+		t.Error("ShareLink(freedom) should fail")
+	}
+}
+
+// v2rayN links carry the share link names of the transports, not the xray config names
+func TestVMessQrCodeNet(t *testing.T) {
+	settings := `"settings":{"vnext":[{"address":"example.com","port":443,"users":[{"id":"2a3263e2-891c-4446-b474-a8c63acb6e25"}]}]}`
+	tests := []struct {
+		name     string
+		outbound string
+		want     string
+	}{
+		{"no stream settings", `{"protocol":"vmess",` + settings + `}`, "tcp"},
+		{"raw", `{"protocol":"vmess",` + settings + `,"streamSettings":{"network":"raw"}}`, "tcp"},
+		{"tcp", `{"protocol":"vmess",` + settings + `,"streamSettings":{"network":"tcp"}}`, "tcp"},
+		{"websocket", `{"protocol":"vmess",` + settings + `,"streamSettings":{"network":"websocket","wsSettings":{"path":"/ws"}}}`, "ws"},
+		{"ws", `{"protocol":"vmess",` + settings + `,"streamSettings":{"network":"ws","wsSettings":{"path":"/ws"}}}`, "ws"},
+		{"splithttp", `{"protocol":"vmess",` + settings + `,"streamSettings":{"network":"splithttp","splithttpSettings":{"path":"/xhttp"}}}`, "xhttp"},
+		{"xhttp", `{"protocol":"vmess",` + settings + `,"streamSettings":{"network":"xhttp","xhttpSettings":{"path":"/xhttp"}}}`, "xhttp"},
+		{"mkcp", `{"protocol":"vmess",` + settings + `,"streamSettings":{"network":"mkcp"}}`, "kcp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outbound conf.OutboundDetourConfig
+			if err := json.Unmarshal([]byte(tt.outbound), &outbound); err != nil {
+				t.Fatal(err)
+			}
+			link, err := ShareLink(outbound, VMessLinkV2rayN)
+			if err != nil {
+				t.Fatalf("ShareLink: %v", err)
+			}
+			qrcodeBytes, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(link, "vmess://"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var qrcode vmessQrCode
+			if err := json.Unmarshal(qrcodeBytes, &qrcode); err != nil {
+				t.Fatal(err)
+			}
+			if qrcode.Net != tt.want {
+				t.Errorf("net = %q, want %q", qrcode.Net, tt.want)
+			}
+		})
+	}
+}
+
+// share links carry the share link names of the transports in type, as v2rayN does
+func TestShareLinkType(t *testing.T) {
+	settings := `"settings":{"vnext":[{"address":"example.com","port":443,"users":[{"id":"2a3263e2-891c-4446-b474-a8c63acb6e25","encryption":"none"}]}]}`
+	tests := []struct {
+		name    string
+		network string
+		want    string
+	}{
+		{"raw", `"network":"raw"`, "tcp"},
+		{"websocket", `"network":"websocket","wsSettings":{"path":"/ws"}`, "ws"},
+		{"splithttp", `"network":"splithttp","splithttpSettings":{"path":"/xhttp"}`, "xhttp"},
+		{"mkcp", `"network":"mkcp"`, "kcp"},
+		{"grpc", `"network":"grpc","grpcSettings":{"serviceName":"grpc"}`, "grpc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outbound conf.OutboundDetourConfig
+			text := `{"protocol":"vless",` + settings + `,"streamSettings":{` + tt.network + `}}`
+			if err := json.Unmarshal([]byte(text), &outbound); err != nil {
+				t.Fatal(err)
+			}
+			link, err := ShareLink(outbound, VMessLinkV2rayN)
+			if err != nil {
+				t.Fatalf("ShareLink: %v", err)
+			}
+			u, err := url.Parse(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.Query().Get("type"); got != tt.want {
+				t.Errorf("type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShadowsocks2022Userinfo(t *testing.T) {
+	tests := map[string]string{
+		"ss://YWVzLTI1Ni1nY206cGFzcyt3b3JkLzE@1.2.3.4:8388#ss":                       "ss://YWVzLTI1Ni1nY206cGFzcyt3b3JkLzE@",
+		"ss://2022-blake3-aes-128-gcm:c2VjcmV0c2VjcmV0c2VjcmV0@1.2.3.4:8388#ss-2022": "ss://2022-blake3-aes-128-gcm:c2VjcmV0c2VjcmV0c2VjcmV0@",
+	}
+	for text, want := range tests {
+		link, err := ShareLink(*parseShareLink(t, text), VMessLinkV2rayN)
+		if err != nil {
+			t.Fatalf("ShareLink: %v", err)
+		}
+		if !strings.HasPrefix(link, want) {
+			t.Errorf("link = %s, want prefix %s", link, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package xray
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -14,10 +15,14 @@ import (
 
 // https://github.com/XTLS/Xray-core/discussions/716
 // Convert share text to XrayJson
-// support v2rayN plain text, v2rayN base64 text
+// support v2rayN plain text, v2rayN base64 text, clash meta yaml, sing-box json
 func ConvertShareLinksToXrayJson(links string) (*conf.Config, error) {
 	text := strings.TrimSpace(links)
 	if strings.HasPrefix(text, "{") {
+		if isSingBoxJson(text) {
+			return tryToParseSingBoxJson(text)
+		}
+
 		var xray conf.Config
 		err := json.Unmarshal([]byte(text), &xray)
 		if err != nil {
@@ -112,6 +117,18 @@ func decodeBase64Text(text string) (string, error) {
 	return string(content), nil
 }
 
+// linkUserInfo returns the unescaped userinfo of the link,
+// unlike url.QueryUnescape a `+` is kept as is.
+func linkUserInfo(link *url.URL) string {
+	if link.User == nil {
+		return ""
+	}
+	if password, ok := link.User.Password(); ok {
+		return link.User.Username() + ":" + password
+	}
+	return link.User.Username()
+}
+
 type XrayShareLink struct {
 	Link    *url.URL
 	RawText string
@@ -169,10 +186,13 @@ func (proxy XrayShareLink) shadowsocksOutbound() (*conf.OutboundDetourConfig, er
 	}
 	server.Port = uint16(port)
 
-	user := proxy.Link.User.String()
-	passwordText, err := decodeBase64Text(user)
-	if err != nil {
-		return nil, err
+	// SIP002 allows plain percent-encoded userinfo for AEAD-2022 ciphers
+	passwordText := linkUserInfo(proxy.Link)
+	if _, ok := proxy.Link.User.Password(); !ok {
+		passwordText, err = decodeBase64Text(proxy.Link.User.String())
+		if err != nil {
+			return nil, err
+		}
 	}
 	pwConfig := strings.SplitN(passwordText, ":", 2)
 	if len(pwConfig) != 2 {
@@ -383,11 +403,7 @@ func (proxy XrayShareLink) trojanOutbound() (*conf.OutboundDetourConfig, error)
 	}
 	server.Port = uint16(port)
 
-	password, err := url.QueryUnescape(proxy.Link.User.String())
-	if err != nil {
-		return nil, err
-	}
-	server.Password = password
+	server.Password = linkUserInfo(proxy.Link)
 
 	settings := &conf.TrojanClientConfig{}
 	settings.Servers = []*conf.TrojanServerTarget{server}
@@ -499,11 +515,13 @@ func (proxy XrayShareLink) streamSettings(link *url.URL) (*conf.StreamConfig, er
 			if err != nil {
 				return nil, err
 			}
-			extraRawMessage, err := convertJsonToRawMessage(extraConfig)
-			if err != nil {
+			// keep the JSON as given, re-marshalling the config by value skips the
+			// pointer MarshalJSON of Int32Range and writes ranges xray cannot read
+			var extraRawMessage bytes.Buffer
+			if err := json.Compact(&extraRawMessage, []byte(extra)); err != nil {
 				return nil, err
 			}
-			xhttpSettings.Extra = extraRawMessage
+			xhttpSettings.Extra = json.RawMessage(extraRawMessage.Bytes())
 		}
 
 		streamSettings.XHTTPSettings = xhttpSettings
@@ -0,0 +1,159 @@
+package xray
+
+import (
+	"encoding/json"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/share")
+
+const shareTestdata = "testdata/share"
+
+// Every testdata/share/<case>.{txt,yaml,json} is converted with ConvertShareLinksToXrayJson
+// and compared with testdata/share/<case>.golden.json.
+// Run `go test ./pkg/xray -run TestConvertShareLinksGolden -update` to regenerate.
+func TestConvertShareLinksGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(shareTestdata, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range inputs {
+		ext := filepath.Ext(input)
+		if ext != ".txt" && ext != ".yaml" && ext != ".json" || strings.HasSuffix(input, ".golden.json") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(input), ext)
+
+		t.Run(name, func(t *testing.T) {
+			text, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			xray, err := ConvertShareLinksToXrayJson(string(text))
+			if err != nil {
+				t.Fatalf("ConvertShareLinksToXrayJson: %v", err)
+			}
+			xrayBytes, err := json.Marshal(xray)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			got, err := normalizeJson(xrayBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenPath := filepath.Join(shareTestdata, name+".golden.json")
+			if *update {
+				goldenBytes, err := json.MarshalIndent(got, "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = os.WriteFile(goldenPath, append(goldenBytes, '\n'), 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			goldenBytes, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := normalizeJson(goldenBytes)
+			if err != nil {
+				t.Fatalf("%s: %v", goldenPath, err)
+			}
+			if !reflect.DeepEqual(want, got) {
+				gotBytes, _ := json.MarshalIndent(got, "", "  ")
+				t.Errorf("%s mismatch, got:\n%s", goldenPath, gotBytes)
+			}
+		})
+	}
+}
+
+// normalizeJson decodes data and drops every empty value, xray config
+// structs marshal all their zero fields which say nothing about the parse.
+func normalizeJson(data []byte) (any, error) {
+	var v any
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+	v, _ = pruneJson(v)
+	return v, nil
+}
+
+func pruneJson(v any) (any, bool) {
+	switch value := v.(type) {
+	case nil:
+		return nil, false
+	case bool:
+		return value, value
+	case float64:
+		return value, value != 0
+	case string:
+		// xray marshals an unset Int32Range as "0"
+		return value, value != "" && value != "0"
+	case []any:
+		var list []any
+		for _, item := range value {
+			if item, ok := pruneJson(item); ok {
+				list = append(list, item)
+			}
+		}
+		return list, len(list) > 0
+	case map[string]any:
+		object := make(map[string]any)
+		for key, item := range value {
+			if item, ok := pruneJson(item); ok {
+				object[key] = item
+			}
+		}
+		return object, len(object) > 0
+	}
+	return v, true
+}
+
+func FuzzXrayShareLinkOutbound(f *testing.F) {
+	for _, text := range shareLinkCorpus {
+		f.Add(text)
+	}
+	f.Add("vmess://eyJwb3J0IjpudWxsfQ==")
+	f.Add("ss://@:")
+	f.Add("socks://%zz@host")
+	f.Add("vless://id@host:443?type=kcp&headerType=wechat-video")
+
+	f.Fuzz(func(t *testing.T, text string) {
+		link, err := url.Parse(text)
+		if err != nil {
+			return
+		}
+		shareLink := XrayShareLink{
+			Link:    link,
+			RawText: text,
+		}
+		// malformed links must fail with an error, never panic
+		shareLink.Outbound()
+	})
+}
+
+func FuzzDecodeBase64Text(f *testing.F) {
+	f.Add("dm1lc3M6Ly8=")
+	f.Add("dm1lc3M6Ly8")
+	f.Add("YWVzLTI1Ni1nY206cGFzcyt3b3JkLzE")
+	f.Add("-_-_")
+	f.Add("=")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, text string) {
+		// feeds are often not base64 at all, that must be an error, never a panic
+		decodeBase64Text(text)
+	})
+}
@@ -0,0 +1,501 @@
+package xray
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/proxy/vless"
+)
+
+// https://sing-box.sagernet.org/configuration/outbound/
+
+type SingBoxConfig struct {
+	Outbounds []SingBoxOutbound `json:"outbounds,omitempty"`
+	Endpoints []SingBoxOutbound `json:"endpoints,omitempty"`
+	Route     *SingBoxRoute     `json:"route,omitempty"`
+}
+
+type SingBoxRoute struct {
+	Final string `json:"final,omitempty"`
+}
+
+type SingBoxOutbound struct {
+	Type       string `json:"type,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Server     string `json:"server,omitempty"`
+	ServerPort uint16 `json:"server_port,omitempty"`
+
+	Uuid       string             `json:"uuid,omitempty"`
+	Security   string             `json:"security,omitempty"`
+	AlterId    int                `json:"alter_id,omitempty"`
+	Flow       string             `json:"flow,omitempty"`
+	Method     string             `json:"method,omitempty"`
+	Version    string             `json:"version,omitempty"`
+	Username   string             `json:"username,omitempty"`
+	Password   string             `json:"password,omitempty"`
+	Plugin     string             `json:"plugin,omitempty"`
+	PluginOpts string             `json:"plugin_opts,omitempty"`
+	UdpOverTcp *SingBoxUdpOverTcp `json:"udp_over_tcp,omitempty"`
+
+	Tls       *SingBoxTls       `json:"tls,omitempty"`
+	Transport *SingBoxTransport `json:"transport,omitempty"`
+
+	// the below are fields of wireguard, both the legacy outbound and the
+	// endpoint (sing-box 1.11+) form.
+	LocalAddress  []string               `json:"local_address,omitempty"`
+	Address       []string               `json:"address,omitempty"`
+	PrivateKey    string                 `json:"private_key,omitempty"`
+	PeerPublicKey string                 `json:"peer_public_key,omitempty"`
+	PreSharedKey  string                 `json:"pre_shared_key,omitempty"`
+	Reserved      []int                  `json:"reserved,omitempty"`
+	Mtu           int                    `json:"mtu,omitempty"`
+	Peers         []SingBoxWireGuardPeer `json:"peers,omitempty"`
+
+	// the below are fields of hysteria2 and tuic.
+	// although xray doesn't support them,
+	// but someone may need them.
+	ServerPorts       []string `json:"server_ports,omitempty"`
+	UpMbps            int      `json:"up_mbps,omitempty"`
+	DownMbps          int      `json:"down_mbps,omitempty"`
+	CongestionControl string   `json:"congestion_control,omitempty"`
+	UdpRelayMode      string   `json:"udp_relay_mode,omitempty"`
+
+	// the below are fields of groups (selector, urltest).
+	Outbounds []string `json:"outbounds,omitempty"`
+	Url       string   `json:"url,omitempty"`
+	Interval  string   `json:"interval,omitempty"`
+	Tolerance int      `json:"tolerance,omitempty"`
+}
+
+type SingBoxWireGuardPeer struct {
+	Address                     string   `json:"address,omitempty"`
+	Port                        uint16   `json:"port,omitempty"`
+	PublicKey                   string   `json:"public_key,omitempty"`
+	PreSharedKey                string   `json:"pre_shared_key,omitempty"`
+	AllowedIps                  []string `json:"allowed_ips,omitempty"`
+	Reserved                    []int    `json:"reserved,omitempty"`
+	PersistentKeepaliveInterval uint32   `json:"persistent_keepalive_interval,omitempty"`
+}
+
+// SingBoxUdpOverTcp accepts both `true` and `{"enabled": true, "version": 2}`
+type SingBoxUdpOverTcp struct {
+	Enabled bool `json:"enabled,omitempty"`
+	Version int  `json:"version,omitempty"`
+}
+
+func (uot *SingBoxUdpOverTcp) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		uot.Enabled = enabled
+		return nil
+	}
+	type singBoxUdpOverTcp SingBoxUdpOverTcp
+	return json.Unmarshal(data, (*singBoxUdpOverTcp)(uot))
+}
+
+type SingBoxTls struct {
+	Enabled    bool            `json:"enabled,omitempty"`
+	ServerName string          `json:"server_name,omitempty"`
+	Insecure   bool            `json:"insecure,omitempty"`
+	Alpn       []string        `json:"alpn,omitempty"`
+	Utls       *SingBoxUtls    `json:"utls,omitempty"`
+	Reality    *SingBoxReality `json:"reality,omitempty"`
+}
+
+type SingBoxUtls struct {
+	Enabled     bool   `json:"enabled,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+type SingBoxReality struct {
+	Enabled   bool   `json:"enabled,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+	ShortId   string `json:"short_id,omitempty"`
+}
+
+type SingBoxTransport struct {
+	Type        string            `json:"type,omitempty"`
+	Host        SingBoxStringList `json:"host,omitempty"`
+	Path        string            `json:"path,omitempty"`
+	Method      string            `json:"method,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ServiceName string            `json:"service_name,omitempty"`
+}
+
+// SingBoxStringList accepts both a string and a list of strings
+type SingBoxStringList []string
+
+func (list *SingBoxStringList) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*list = SingBoxStringList{str}
+		return nil
+	}
+	var strs []string
+	if err := json.Unmarshal(data, &strs); err != nil {
+		return err
+	}
+	*list = strs
+	return nil
+}
+
+// isSingBoxJson reports whether the json text is a sing-box config
+// rather than an xray one, sing-box outbounds carry a `type`.
+func isSingBoxJson(text string) bool {
+	var singBox SingBoxConfig
+	if err := json.Unmarshal([]byte(text), &singBox); err != nil {
+		return false
+	}
+	for _, outbound := range append(singBox.Outbounds, singBox.Endpoints...) {
+		if len(outbound.Type) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func tryToParseSingBoxJson(text string) (*conf.Config, error) {
+	singBox := SingBoxConfig{}
+	err := json.Unmarshal([]byte(text), &singBox)
+	if err != nil {
+		return nil, err
+	}
+
+	xray := singBox.xrayConfig()
+	if len(xray.OutboundConfigs) == 0 {
+		return nil, fmt.Errorf("no valid outbounds")
+	}
+	return xray, nil
+}
+
+func (singBox SingBoxConfig) xrayConfig() *conf.Config {
+	xray := &conf.Config{}
+
+	var outbounds []conf.OutboundDetourConfig
+	for _, proxy := range append(singBox.Outbounds, singBox.Endpoints...) {
+		switch proxy.Type {
+		case "direct", "block", "dns", "selector", "urltest":
+			continue
+		}
+		if outbound, err := proxy.outbound(); err == nil {
+			outbounds = append(outbounds, *outbound)
+		} else {
+			fmt.Println(err)
+		}
+	}
+	xray.OutboundConfigs = outbounds
+
+	return xray
+}
+
+func (proxy SingBoxOutbound) outbound() (*conf.OutboundDetourConfig, error) {
+	switch proxy.Type {
+	case "shadowsocks":
+		return proxy.shadowsocksOutbound()
+	case "vmess":
+		return proxy.vmessOutbound()
+	case "vless":
+		return proxy.vlessOutbound()
+	case "socks":
+		return proxy.socksOutbound()
+	case "trojan":
+		return proxy.trojanOutbound()
+	case "wireguard":
+		return proxy.wireguardOutbound()
+	case "hysteria2", "tuic":
+		return nil, fmt.Errorf("unsupport sing-box type by xray: %s", proxy.Type)
+	}
+	return nil, fmt.Errorf("unsupport sing-box type: %s", proxy.Type)
+}
+
+func (proxy SingBoxOutbound) shadowsocksOutbound() (*conf.OutboundDetourConfig, error) {
+	if len(proxy.Plugin) > 0 {
+		return nil, fmt.Errorf("unsupport sing-box shadowsocks plugin: %s", proxy.Plugin)
+	}
+
+	outbound := &conf.OutboundDetourConfig{}
+	outbound.Protocol = "shadowsocks"
+	setOutboundName(outbound, proxy.Tag)
+
+	server := &conf.ShadowsocksServerTarget{}
+	server.Address = parseAddress(proxy.Server)
+	server.Port = proxy.ServerPort
+	server.Cipher = proxy.Method
+	server.Password = proxy.Password
+	if proxy.UdpOverTcp != nil {
+		server.UoT = proxy.UdpOverTcp.Enabled
+		server.UoTVersion = proxy.UdpOverTcp.Version
+	}
+
+	var settings conf.ShadowsocksClientConfig
+	settings.Servers = []*conf.ShadowsocksServerTarget{server}
+
+	settingsRawMessage, err := convertJsonToRawMessage(settings)
+	if err != nil {
+		return nil, err
+	}
+	outbound.Settings = &settingsRawMessage
+
+	return outbound, nil
+}
+
+func (proxy SingBoxOutbound) vmessOutbound() (*conf.OutboundDetourConfig, error) {
+	outbound := &conf.OutboundDetourConfig{}
+	outbound.Protocol = "vmess"
+	setOutboundName(outbound, proxy.Tag)
+
+	user := &conf.VMessAccount{}
+	user.ID = proxy.Uuid
+	user.Security = proxy.Security
+
+	vnext := &conf.VMessOutboundTarget{}
+	vnext.Address = parseAddress(proxy.Server)
+	vnext.Port = proxy.ServerPort
+
+	userRawMessage, err := convertJsonToRawMessage(user)
+	if err != nil {
+		return nil, err
+	}
+	vnext.Users = []json.RawMessage{userRawMessage}
+
+	settings := conf.VMessOutboundConfig{}
+	settings.Receivers = []*conf.VMessOutboundTarget{vnext}
+
+	settingsRawMessage, err := convertJsonToRawMessage(settings)
+	if err != nil {
+		return nil, err
+	}
+	outbound.Settings = &settingsRawMessage
+
+	streamSettings, err := proxy.streamSettings()
+	if err != nil {
+		return nil, err
+	}
+	outbound.StreamSetting = streamSettings
+
+	return outbound, nil
+}
+
+func (proxy SingBoxOutbound) vlessOutbound() (*conf.OutboundDetourConfig, error) {
+	outbound := &conf.OutboundDetourConfig{}
+	outbound.Protocol = "vless"
+	setOutboundName(outbound, proxy.Tag)
+
+	user := &vless.Account{}
+	user.Id = proxy.Uuid
+	user.Flow = proxy.Flow
+	user.Encryption = "none"
+
+	vnext := &conf.VLessOutboundVnext{}
+	vnext.Address = parseAddress(proxy.Server)
+	vnext.Port = proxy.ServerPort
+
+	userRawMessage, err := convertJsonToRawMessage(user)
+	if err != nil {
+		return nil, err
+	}
+	vnext.Users = []json.RawMessage{userRawMessage}
+
+	settings := &conf.VLessOutboundConfig{}
+	settings.Vnext = []*conf.VLessOutboundVnext{vnext}
+
+	settingsRawMessage, err := convertJsonToRawMessage(settings)
+	if err != nil {
+		return nil, err
+	}
+	outbound.Settings = &settingsRawMessage
+
+	streamSettings, err := proxy.streamSettings()
+	if err != nil {
+		return nil, err
+	}
+	outbound.StreamSetting = streamSettings
+
+	return outbound, nil
+}
+
+func (proxy SingBoxOutbound) socksOutbound() (*conf.OutboundDetourConfig, error) {
+	if len(proxy.Version) > 0 && proxy.Version != "5" {
+		return nil, fmt.Errorf("unsupport sing-box socks version: %s", proxy.Version)
+	}
+
+	outbound := &conf.OutboundDetourConfig{}
+	outbound.Protocol = "socks"
+	setOutboundName(outbound, proxy.Tag)
+
+	server := &conf.SocksRemoteConfig{}
+	server.Address = parseAddress(proxy.Server)
+	server.Port = proxy.ServerPort
+
+	if len(proxy.Username) > 0 {
+		user := &conf.SocksAccount{}
+		user.Username = proxy.Username
+		user.Password = proxy.Password
+
+		userRawMessage, err := convertJsonToRawMessage(user)
+		if err != nil {
+			return nil, err
+		}
+		server.Users = []json.RawMessage{userRawMessage}
+	}
+
+	settings := &conf.SocksClientConfig{}
+	settings.Servers = []*conf.SocksRemoteConfig{server}
+
+	settingsRawMessage, err := convertJsonToRawMessage(settings)
+	if err != nil {
+		return nil, err
+	}
+	outbound.Settings = &settingsRawMessage
+
+	return outbound, nil
+}
+
+func (proxy SingBoxOutbound) trojanOutbound() (*conf.OutboundDetourConfig, error) {
+	outbound := &conf.OutboundDetourConfig{}
+	outbound.Protocol = "trojan"
+	setOutboundName(outbound, proxy.Tag)
+
+	server := &conf.TrojanServerTarget{}
+	server.Address = parseAddress(proxy.Server)
+	server.Port = proxy.ServerPort
+	server.Password = proxy.Password
+
+	settings := &conf.TrojanClientConfig{}
+	settings.Servers = []*conf.TrojanServerTarget{server}
+
+	settingsRawMessage, err := convertJsonToRawMessage(settings)
+	if err != nil {
+		return nil, err
+	}
+	outbound.Settings = &settingsRawMessage
+
+	streamSettings, err := proxy.streamSettings()
+	if err != nil {
+		return nil, err
+	}
+	outbound.StreamSetting = streamSettings
+
+	return outbound, nil
+}
+
+func (proxy SingBoxOutbound) wireguardOutbound() (*conf.OutboundDetourConfig, error) {
+	outbound := &conf.OutboundDetourConfig{}
+	outbound.Protocol = "wireguard"
+	setOutboundName(outbound, proxy.Tag)
+
+	settings := &XrayWireGuardSettings{}
+	settings.SecretKey = proxy.PrivateKey
+	settings.Mtu = proxy.Mtu
+	settings.Reserved = proxy.Reserved
+
+	if len(proxy.Peers) > 0 {
+		// endpoint form
+		settings.Address = proxy.Address
+		for _, peer := range proxy.Peers {
+			settings.Peers = append(settings.Peers, XrayWireGuardPeer{
+				PublicKey:    peer.PublicKey,
+				PreSharedKey: peer.PreSharedKey,
+				Endpoint:     net.JoinHostPort(peer.Address, strconv.Itoa(int(peer.Port))),
+				KeepAlive:    peer.PersistentKeepaliveInterval,
+				AllowedIPs:   peer.AllowedIps,
+			})
+			if len(settings.Reserved) == 0 {
+				settings.Reserved = peer.Reserved
+			}
+		}
+	} else {
+		settings.Address = proxy.LocalAddress
+		settings.Peers = []XrayWireGuardPeer{{
+			PublicKey:    proxy.PeerPublicKey,
+			PreSharedKey: proxy.PreSharedKey,
+			Endpoint:     net.JoinHostPort(proxy.Server, strconv.Itoa(int(proxy.ServerPort))),
+		}}
+	}
+
+	settingsRawMessage, err := convertJsonToRawMessage(settings)
+	if err != nil {
+		return nil, err
+	}
+	outbound.Settings = &settingsRawMessage
+
+	return outbound, nil
+}
+
+func (proxy SingBoxOutbound) streamSettings() (*conf.StreamConfig, error) {
+	streamSettings := &conf.StreamConfig{}
+	network := "raw"
+	if proxy.Transport != nil {
+		network = proxy.Transport.Type
+	}
+	switch network {
+	case "raw", "ws", "httpupgrade", "grpc":
+	default:
+		// including http, which is h2 in sing-box: xray has removed it and xhttp does not speak it
+		return nil, fmt.Errorf("unsupport sing-box transport: %s", network)
+	}
+	transportProtocol := conf.TransportProtocol(network)
+	streamSettings.Network = &transportProtocol
+
+	transport := proxy.Transport
+	switch network {
+	case "ws":
+		wsSettings := &conf.WebSocketConfig{}
+		wsSettings.Path = transport.Path
+		wsSettings.Host = transport.Headers["Host"]
+		streamSettings.WSSettings = wsSettings
+	case "httpupgrade":
+		httpupgradeSettings := &conf.HttpUpgradeConfig{}
+		httpupgradeSettings.Path = transport.Path
+		if len(transport.Host) > 0 {
+			httpupgradeSettings.Host = transport.Host[0]
+		}
+		streamSettings.HTTPUPGRADESettings = httpupgradeSettings
+	case "grpc":
+		grpcSettings := &conf.GRPCConfig{}
+		grpcSettings.ServiceName = transport.ServiceName
+		streamSettings.GRPCSettings = grpcSettings
+	}
+
+	proxy.parseSecurity(streamSettings)
+	return streamSettings, nil
+}
+
+func (proxy SingBoxOutbound) parseSecurity(streamSettings *conf.StreamConfig) {
+	streamSettings.Security = "none"
+	if proxy.Tls == nil || !proxy.Tls.Enabled {
+		return
+	}
+
+	fingerprint := ""
+	if proxy.Tls.Utls != nil && proxy.Tls.Utls.Enabled {
+		fingerprint = proxy.Tls.Utls.Fingerprint
+	}
+
+	if proxy.Tls.Reality != nil && proxy.Tls.Reality.Enabled {
+		realitySettings := &conf.REALITYConfig{}
+		realitySettings.ServerName = proxy.Tls.ServerName
+		realitySettings.Fingerprint = fingerprint
+		realitySettings.PublicKey = proxy.Tls.Reality.PublicKey
+		realitySettings.ShortId = proxy.Tls.Reality.ShortId
+
+		streamSettings.Security = "reality"
+		streamSettings.REALITYSettings = realitySettings
+		return
+	}
+
+	tlsSettings := &conf.TLSConfig{}
+	tlsSettings.ServerName = proxy.Tls.ServerName
+	tlsSettings.Insecure = proxy.Tls.Insecure
+	tlsSettings.Fingerprint = fingerprint
+	if len(proxy.Tls.Alpn) > 0 {
+		alpn := conf.StringList(proxy.Tls.Alpn)
+		tlsSettings.ALPN = &alpn
+	}
+
+	streamSettings.Security = "tls"
+	streamSettings.TLSSettings = tlsSettings
+}
@@ -0,0 +1,392 @@
+package xray
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/proxy/vless"
+)
+
+const (
+	SingBoxAutoGroup = "Auto"
+
+	singBoxDirectTag = "direct"
+)
+
+// Convert outbounds to a sing-box config.
+// healthy[i] marks outbounds[i] as a member of the urltest group, when no
+// outbound is healthy the group falls back to all proxies.
+// Each of groups becomes another urltest group over its members.
+func ConvertOutboundsToSingBoxJson(outbounds []conf.OutboundDetourConfig, healthy []bool, groups ...OutboundGroup) ([]byte, error) {
+	singBox := SingBoxConfig{}
+
+	tags := reservedNames(SingBoxAutoGroup, groups)
+	tags[singBoxDirectTag] = true
+	proxyTags := make([]string, len(outbounds))
+	var proxies, group []string
+	for i, outbound := range outbounds {
+		proxy, err := singBoxOutbound(outbound)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		proxy.Tag = uniqueName(proxy.Tag, tags)
+		proxyTags[i] = proxy.Tag
+		proxies = append(proxies, proxy.Tag)
+		if proxy.Type == "wireguard" {
+			singBox.Endpoints = append(singBox.Endpoints, *proxy)
+		} else {
+			singBox.Outbounds = append(singBox.Outbounds, *proxy)
+		}
+
+		if i < len(healthy) && healthy[i] {
+			group = append(group, proxy.Tag)
+		}
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no valid outbounds")
+	}
+
+	if len(group) == 0 {
+		group = proxies
+	}
+	singBox.Outbounds = append(singBox.Outbounds,
+		SingBoxOutbound{
+			Type:      "urltest",
+			Tag:       SingBoxAutoGroup,
+			Outbounds: group,
+			Url:       clashTestUrl,
+			Interval:  fmt.Sprintf("%ds", clashTestInterval),
+			Tolerance: clashTestTolerance,
+		},
+	)
+	for _, outboundGroup := range groups {
+		members := outboundGroup.members(proxyTags)
+		if len(members) == 0 {
+			continue
+		}
+		singBox.Outbounds = append(singBox.Outbounds, SingBoxOutbound{
+			Type:      "urltest",
+			Tag:       outboundGroup.Name,
+			Outbounds: members,
+			Url:       clashTestUrl,
+			Interval:  fmt.Sprintf("%ds", clashTestInterval),
+			Tolerance: clashTestTolerance,
+		})
+	}
+	singBox.Outbounds = append(singBox.Outbounds, SingBoxOutbound{
+		Type: "direct",
+		Tag:  singBoxDirectTag,
+	})
+	singBox.Route = &SingBoxRoute{Final: SingBoxAutoGroup}
+
+	return json.MarshalIndent(singBox, "", "  ")
+}
+
+func singBoxOutbound(outbound conf.OutboundDetourConfig) (*SingBoxOutbound, error) {
+	if outbound.Settings == nil {
+		return nil, fmt.Errorf("no settings in outbound: %s", outbound.Protocol)
+	}
+
+	proxy := &SingBoxOutbound{}
+
+	var err error
+	switch outbound.Protocol {
+	case "shadowsocks":
+		err = proxy.fromShadowsocks(*outbound.Settings)
+	case "vmess":
+		err = proxy.fromVMess(*outbound.Settings)
+	case "vless":
+		err = proxy.fromVLess(*outbound.Settings)
+	case "socks":
+		err = proxy.fromSocks(*outbound.Settings)
+	case "trojan":
+		err = proxy.fromTrojan(*outbound.Settings)
+	case "wireguard":
+		err = proxy.fromWireGuard(*outbound.Settings)
+	default:
+		err = fmt.Errorf("unsupport sing-box protocol: %s", outbound.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	proxy.Tag = getOutboundName(outbound)
+	if len(proxy.Tag) == 0 {
+		proxy.Tag = proxy.endpoint()
+	}
+
+	err = proxy.fromStreamSettings(outbound.StreamSetting)
+	if err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// endpoint is the server of the proxy, wireguard endpoints keep it in the first peer
+func (proxy *SingBoxOutbound) endpoint() string {
+	if len(proxy.Peers) > 0 {
+		return net.JoinHostPort(proxy.Peers[0].Address, strconv.Itoa(int(proxy.Peers[0].Port)))
+	}
+	return net.JoinHostPort(proxy.Server, strconv.Itoa(int(proxy.ServerPort)))
+}
+
+func (proxy *SingBoxOutbound) fromShadowsocks(settingsRawMessage json.RawMessage) error {
+	var settings conf.ShadowsocksClientConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in shadowsocks outbound")
+	}
+
+	server := settings.Servers[0]
+	proxy.Type = "shadowsocks"
+	proxy.Server = addressString(server.Address)
+	proxy.ServerPort = server.Port
+	proxy.Method = server.Cipher
+	proxy.Password = server.Password
+	if server.UoT {
+		proxy.UdpOverTcp = &SingBoxUdpOverTcp{Enabled: true, Version: server.UoTVersion}
+	}
+	return nil
+}
+
+func (proxy *SingBoxOutbound) fromVMess(settingsRawMessage json.RawMessage) error {
+	var settings conf.VMessOutboundConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Receivers) == 0 || len(settings.Receivers[0].Users) == 0 {
+		return fmt.Errorf("no users in vmess outbound")
+	}
+
+	vnext := settings.Receivers[0]
+	var account conf.VMessAccount
+	err = json.Unmarshal(vnext.Users[0], &account)
+	if err != nil {
+		return err
+	}
+
+	proxy.Type = "vmess"
+	proxy.Server = addressString(vnext.Address)
+	proxy.ServerPort = vnext.Port
+	proxy.Uuid = account.ID
+	proxy.Security = account.Security
+	if len(proxy.Security) == 0 {
+		proxy.Security = "auto"
+	}
+	return nil
+}
+
+func (proxy *SingBoxOutbound) fromVLess(settingsRawMessage json.RawMessage) error {
+	var settings conf.VLessOutboundConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Vnext) == 0 || len(settings.Vnext[0].Users) == 0 {
+		return fmt.Errorf("no users in vless outbound")
+	}
+
+	vnext := settings.Vnext[0]
+	var account vless.Account
+	err = json.Unmarshal(vnext.Users[0], &account)
+	if err != nil {
+		return err
+	}
+
+	proxy.Type = "vless"
+	proxy.Server = addressString(vnext.Address)
+	proxy.ServerPort = vnext.Port
+	proxy.Uuid = account.Id
+	proxy.Flow = account.Flow
+	return nil
+}
+
+func (proxy *SingBoxOutbound) fromSocks(settingsRawMessage json.RawMessage) error {
+	var settings conf.SocksClientConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in socks outbound")
+	}
+
+	server := settings.Servers[0]
+	proxy.Type = "socks"
+	proxy.Version = "5"
+	proxy.Server = addressString(server.Address)
+	proxy.ServerPort = server.Port
+	if len(server.Users) > 0 {
+		var account conf.SocksAccount
+		err := json.Unmarshal(server.Users[0], &account)
+		if err != nil {
+			return err
+		}
+		proxy.Username = account.Username
+		proxy.Password = account.Password
+	}
+	return nil
+}
+
+func (proxy *SingBoxOutbound) fromTrojan(settingsRawMessage json.RawMessage) error {
+	var settings conf.TrojanClientConfig
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Servers) == 0 {
+		return fmt.Errorf("no servers in trojan outbound")
+	}
+
+	server := settings.Servers[0]
+	proxy.Type = "trojan"
+	proxy.Server = addressString(server.Address)
+	proxy.ServerPort = server.Port
+	proxy.Password = server.Password
+	return nil
+}
+
+func (proxy *SingBoxOutbound) fromWireGuard(settingsRawMessage json.RawMessage) error {
+	var settings XrayWireGuardSettings
+	err := json.Unmarshal(settingsRawMessage, &settings)
+	if err != nil {
+		return err
+	}
+	if len(settings.Peers) == 0 {
+		return fmt.Errorf("no peers in wireguard outbound")
+	}
+
+	proxy.Type = "wireguard"
+	proxy.Address = settings.Address
+	proxy.PrivateKey = settings.SecretKey
+	proxy.Mtu = settings.Mtu
+	for _, peer := range settings.Peers {
+		host, portText, err := net.SplitHostPort(peer.Endpoint)
+		if err != nil {
+			return err
+		}
+		port, err := strconv.Atoi(portText)
+		if err != nil {
+			return err
+		}
+
+		proxy.Peers = append(proxy.Peers, SingBoxWireGuardPeer{
+			Address:                     host,
+			Port:                        uint16(port),
+			PublicKey:                   peer.PublicKey,
+			PreSharedKey:                peer.PreSharedKey,
+			AllowedIps:                  peer.AllowedIPs,
+			Reserved:                    settings.Reserved,
+			PersistentKeepaliveInterval: peer.KeepAlive,
+		})
+	}
+	if len(proxy.Peers[0].AllowedIps) == 0 {
+		proxy.Peers[0].AllowedIps = []string{"0.0.0.0/0", "::/0"}
+	}
+	return nil
+}
+
+func (proxy *SingBoxOutbound) fromStreamSettings(streamSettings *conf.StreamConfig) error {
+	if streamSettings == nil {
+		return nil
+	}
+
+	network := "tcp"
+	if streamSettings.Network != nil {
+		var err error
+		network, err = streamSettings.Network.Build()
+		if err != nil {
+			return err
+		}
+	}
+
+	switch network {
+	case "tcp":
+		rawSettings := streamSettings.RAWSettings
+		if rawSettings == nil {
+			rawSettings = streamSettings.TCPSettings
+		}
+		if rawSettings != nil && rawSettings.HeaderConfig != nil {
+			var header XrayRawSettingsHeader
+			err := json.Unmarshal(rawSettings.HeaderConfig, &header)
+			if err != nil {
+				return err
+			}
+			if header.Type == "http" {
+				return fmt.Errorf("unsupport sing-box transport: http header obfuscation")
+			}
+		}
+	case "websocket":
+		transport := &SingBoxTransport{Type: "ws"}
+		if wsSettings := streamSettings.WSSettings; wsSettings != nil {
+			transport.Path = wsSettings.Path
+			if len(wsSettings.Host) > 0 {
+				transport.Headers = map[string]string{"Host": wsSettings.Host}
+			}
+		}
+		proxy.Transport = transport
+	case "httpupgrade":
+		transport := &SingBoxTransport{Type: "httpupgrade"}
+		if httpupgradeSettings := streamSettings.HTTPUPGRADESettings; httpupgradeSettings != nil {
+			transport.Path = httpupgradeSettings.Path
+			if len(httpupgradeSettings.Host) > 0 {
+				transport.Host = SingBoxStringList{httpupgradeSettings.Host}
+			}
+		}
+		proxy.Transport = transport
+	case "grpc":
+		transport := &SingBoxTransport{Type: "grpc"}
+		if grpcSettings := streamSettings.GRPCSettings; grpcSettings != nil {
+			transport.ServiceName = grpcSettings.ServiceName
+		}
+		proxy.Transport = transport
+	default:
+		return fmt.Errorf("unsupport sing-box network: %s", network)
+	}
+
+	proxy.fromSecurity(streamSettings)
+	return nil
+}
+
+func (proxy *SingBoxOutbound) fromSecurity(streamSettings *conf.StreamConfig) {
+	switch streamSettings.Security {
+	case "tls":
+		tls := &SingBoxTls{Enabled: true}
+		if tlsSettings := streamSettings.TLSSettings; tlsSettings != nil {
+			tls.ServerName = tlsSettings.ServerName
+			tls.Insecure = tlsSettings.Insecure
+			if tlsSettings.ALPN != nil {
+				tls.Alpn = *tlsSettings.ALPN
+			}
+			if len(tlsSettings.Fingerprint) > 0 {
+				tls.Utls = &SingBoxUtls{Enabled: true, Fingerprint: tlsSettings.Fingerprint}
+			}
+		}
+		proxy.Tls = tls
+	case "reality":
+		tls := &SingBoxTls{Enabled: true}
+		if realitySettings := streamSettings.REALITYSettings; realitySettings != nil {
+			tls.ServerName = realitySettings.ServerName
+			tls.Reality = &SingBoxReality{
+				Enabled:   true,
+				PublicKey: realitySettings.PublicKey,
+				ShortId:   realitySettings.ShortId,
+			}
+			// sing-box requires utls for reality
+			fingerprint := realitySettings.Fingerprint
+			if len(fingerprint) == 0 {
+				fingerprint = "chrome"
+			}
+			tls.Utls = &SingBoxUtls{Enabled: true, Fingerprint: fingerprint}
+		}
+		proxy.Tls = tls
+	}
+}
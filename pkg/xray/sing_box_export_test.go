@@ -0,0 +1,115 @@
+package xray
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// import(export(import(x))) == import(x) for the sing-box testdata
+func TestSingBoxExportRoundTrip(t *testing.T) {
+	text, err := os.ReadFile(filepath.Join(shareTestdata, "sing_box.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xray, err := ConvertShareLinksToXrayJson(string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := ConvertOutboundsToSingBoxJson(xray.OutboundConfigs, nil)
+	if err != nil {
+		t.Fatalf("ConvertOutboundsToSingBoxJson: %v", err)
+	}
+	roundTrip, err := ConvertShareLinksToXrayJson(string(exported))
+	if err != nil {
+		t.Fatalf("re-import: %v\n%s", err, exported)
+	}
+
+	want := normalizeOutbounds(t, xray.OutboundConfigs)
+	defaultAllowedIPs(want)
+	got := normalizeOutbounds(t, roundTrip.OutboundConfigs)
+	if !reflect.DeepEqual(want, got) {
+		gotBytes, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("round trip mismatch, exported:\n%s\ngot:\n%s", exported, gotBytes)
+	}
+}
+
+// defaultAllowedIPs fills the allowed IPs of wireguard peers that have none with
+// what xray assumes, sing-box endpoints require them and the exporter writes them out
+func defaultAllowedIPs(outbounds any) {
+	for _, outbound := range outbounds.([]any) {
+		outbound := outbound.(map[string]any)
+		if outbound["protocol"] != "wireguard" {
+			continue
+		}
+		settings := outbound["settings"].(map[string]any)
+		for _, peer := range settings["peers"].([]any) {
+			peer := peer.(map[string]any)
+			if _, ok := peer["allowedIPs"]; !ok {
+				peer["allowedIPs"] = []any{"0.0.0.0/0", "::/0"}
+			}
+		}
+	}
+}
+
+func TestSingBoxExportGroups(t *testing.T) {
+	text, err := os.ReadFile(filepath.Join(shareTestdata, "clash_routing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xray, err := ConvertShareLinksToXrayJson(string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hk, jp, us; a proxy named like the direct outbound is renamed
+	outbounds := proxyOutbounds(xray.OutboundConfigs)
+	outbounds[2].Tag = singBoxDirectTag
+
+	exported, err := ConvertOutboundsToSingBoxJson(outbounds, []bool{false, true, true},
+		OutboundGroup{Name: "HK", Members: []bool{true, false, false}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var singBox SingBoxConfig
+	if err := json.Unmarshal(exported, &singBox); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := make(map[string]SingBoxOutbound)
+	var order []string
+	for _, outbound := range singBox.Outbounds {
+		tags[outbound.Tag] = outbound
+		order = append(order, outbound.Tag)
+	}
+	if want := []string{"hk", "jp", "direct 2", SingBoxAutoGroup, "HK", singBoxDirectTag}; !reflect.DeepEqual(order, want) {
+		t.Errorf("outbounds = %v, want %v", order, want)
+	}
+	if got := tags[SingBoxAutoGroup].Outbounds; !reflect.DeepEqual(got, []string{"jp", "direct 2"}) {
+		t.Errorf("auto group = %v", got)
+	}
+	if got := tags["HK"]; got.Type != "urltest" || !reflect.DeepEqual(got.Outbounds, []string{"hk"}) {
+		t.Errorf("HK group = %+v", got)
+	}
+	if singBox.Route == nil || singBox.Route.Final != SingBoxAutoGroup {
+		t.Errorf("route = %+v", singBox.Route)
+	}
+}
+
+// sing-box has no transport speaking xhttp, its http transport is h2
+func TestSingBoxExportSkipsXhttp(t *testing.T) {
+	text, err := os.ReadFile(filepath.Join(shareTestdata, "vless_xhttp_extra.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xray, err := ConvertShareLinksToXrayJson(string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConvertOutboundsToSingBoxJson(proxyOutbounds(xray.OutboundConfigs), nil); err == nil {
+		t.Error("exported xhttp outbounds to sing-box")
+	}
+}
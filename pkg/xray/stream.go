@@ -0,0 +1,83 @@
+package xray
+
+import (
+	"encoding/json"
+
+	"github.com/xtls/xray-core/infra/conf"
+)
+
+// share link names of the canonical xray transport names
+var transportShareNames = map[string]string{
+	"tcp":         "tcp",
+	"mkcp":        "kcp",
+	"websocket":   "ws",
+	"grpc":        "grpc",
+	"httpupgrade": "httpupgrade",
+	"splithttp":   "xhttp",
+}
+
+// Transport returns the share link name of the outbound transport: tcp, kcp,
+// ws, grpc, httpupgrade or xhttp. Outbounds without stream settings are tcp.
+func Transport(outbound conf.OutboundDetourConfig) (string, error) {
+	if outbound.StreamSetting == nil || outbound.StreamSetting.Network == nil {
+		return "tcp", nil
+	}
+	network, err := outbound.StreamSetting.Network.Build()
+	if err != nil {
+		return "", err
+	}
+	if name, ok := transportShareNames[network]; ok {
+		return name, nil
+	}
+	return network, nil
+}
+
+// Security returns the stream security (none, tls or reality) and the
+// uTLS client fingerprint it uses.
+func Security(outbound conf.OutboundDetourConfig) (string, string) {
+	streamSettings := outbound.StreamSetting
+	if streamSettings == nil {
+		return "none", ""
+	}
+
+	switch streamSettings.Security {
+	case "tls":
+		if streamSettings.TLSSettings != nil {
+			return "tls", streamSettings.TLSSettings.Fingerprint
+		}
+		return "tls", ""
+	case "reality":
+		if streamSettings.REALITYSettings != nil {
+			return "reality", streamSettings.REALITYSettings.Fingerprint
+		}
+		return "reality", ""
+	case "":
+		return "none", ""
+	}
+	return streamSettings.Security, ""
+}
+
+// SupportsUDP reports whether the outbound relays UDP to the server it
+// reaches over its transport. vmess, vless and trojan carry UDP inside the
+// stream, shadowsocks sends native UDP to the server address and bypasses
+// ws, grpc and the other transports unless UDP over TCP is enabled.
+func SupportsUDP(outbound conf.OutboundDetourConfig) bool {
+	switch outbound.Protocol {
+	case "vmess", "vless", "trojan", "socks", "wireguard":
+		return true
+	case "shadowsocks":
+		if outbound.Settings == nil {
+			return false
+		}
+		var settings conf.ShadowsocksClientConfig
+		if err := json.Unmarshal(*outbound.Settings, &settings); err != nil || len(settings.Servers) == 0 {
+			return false
+		}
+		if settings.Servers[0].UoT {
+			return true
+		}
+		transport, err := Transport(outbound)
+		return err == nil && transport == "tcp"
+	}
+	return false
+}
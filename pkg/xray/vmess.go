@@ -1,6 +1,7 @@
 package xray
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -11,10 +12,12 @@ import (
 
 // https://github.com/2dust/v2rayN/wiki/%E5%88%86%E4%BA%AB%E9%93%BE%E6%8E%A5%E6%A0%BC%E5%BC%8F%E8%AF%B4%E6%98%8E(ver-2)
 type vmessQrCode struct {
+	V    string      `json:"v,omitempty"`
 	Ps   string      `json:"ps,omitempty"`
 	Add  string      `json:"add,omitempty"`
 	Port interface{} `json:"port,omitempty"`
 	Id   string      `json:"id,omitempty"`
+	Aid  interface{} `json:"aid,omitempty"`
 	Scy  string      `json:"scy,omitempty"`
 	Net  string      `json:"net,omitempty"`
 	Type string      `json:"type,omitempty"`
@@ -147,6 +150,19 @@ func (proxy vmessQrCode) streamSettings() (*conf.StreamConfig, error) {
 		mode := proxy.Type
 		grcpSettings.MultiMode = mode == "multi"
 		streamSettings.GRPCSettings = grcpSettings
+	case "httpupgrade":
+		httpupgradeSettings := &conf.HttpUpgradeConfig{}
+		httpupgradeSettings.Host = proxy.Host
+		httpupgradeSettings.Path = proxy.Path
+
+		streamSettings.HTTPUPGRADESettings = httpupgradeSettings
+	case "xhttp", "splithttp":
+		xhttpSettings := &conf.SplitHTTPConfig{}
+		xhttpSettings.Host = proxy.Host
+		xhttpSettings.Path = proxy.Path
+		xhttpSettings.Mode = proxy.Type
+
+		streamSettings.XHTTPSettings = xhttpSettings
 	}
 
 	err := proxy.parseSecurity(streamSettings)
@@ -193,3 +209,152 @@ func (proxy vmessQrCode) parseSecurity(streamSettings *conf.StreamConfig) error
 	}
 	return nil
 }
+
+func vmessQrCodeLink(proxy conf.OutboundDetourConfig) (string, error) {
+	qrcode, err := newVMessQrCode(proxy)
+	if err != nil {
+		return "", err
+	}
+
+	qrcodeBytes, err := json.Marshal(qrcode)
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(qrcodeBytes), nil
+}
+
+func newVMessQrCode(proxy conf.OutboundDetourConfig) (*vmessQrCode, error) {
+	var settings conf.VMessOutboundConfig
+	err := json.Unmarshal(*proxy.Settings, &settings)
+	if err != nil {
+		return nil, err
+	}
+	if len(settings.Receivers) == 0 || len(settings.Receivers[0].Users) == 0 {
+		return nil, fmt.Errorf("no users in vmess outbound")
+	}
+
+	vnext := settings.Receivers[0]
+	var account conf.VMessAccount
+	err = json.Unmarshal(vnext.Users[0], &account)
+	if err != nil {
+		return nil, err
+	}
+
+	qrcode := &vmessQrCode{}
+	qrcode.V = "2"
+	qrcode.Ps = getOutboundName(proxy)
+	qrcode.Add = addressString(vnext.Address)
+	qrcode.Port = strconv.Itoa(int(vnext.Port))
+	qrcode.Id = account.ID
+	qrcode.Aid = "0"
+	qrcode.Scy = account.Security
+
+	err = qrcode.fromStreamSettings(proxy.StreamSetting)
+	if err != nil {
+		return nil, err
+	}
+	return qrcode, nil
+}
+
+func (qrcode *vmessQrCode) fromStreamSettings(streamSettings *conf.StreamConfig) error {
+	if streamSettings == nil {
+		qrcode.Net = "tcp"
+		return nil
+	}
+
+	network := "raw"
+	if streamSettings.Network != nil {
+		network = string(*streamSettings.Network)
+	}
+
+	transportProtocol := conf.TransportProtocol(network)
+	network, err := transportProtocol.Build()
+	if err != nil {
+		return err
+	}
+	// v2rayN names the transports as share links do, not as xray configs (raw, websocket, splithttp)
+	qrcode.Net = network
+	if name, ok := transportShareNames[network]; ok {
+		qrcode.Net = name
+	}
+
+	switch network {
+	case "tcp":
+		rawSettings := streamSettings.RAWSettings
+		if rawSettings == nil {
+			rawSettings = streamSettings.TCPSettings
+		}
+		if rawSettings == nil || rawSettings.HeaderConfig == nil {
+			break
+		}
+		var header XrayRawSettingsHeader
+		err := json.Unmarshal(rawSettings.HeaderConfig, &header)
+		if err != nil {
+			return err
+		}
+		qrcode.Type = header.Type
+		if header.Request != nil {
+			qrcode.Path = strings.Join(header.Request.Path, ",")
+			if header.Request.Headers != nil {
+				qrcode.Host = strings.Join(header.Request.Headers.Host, ",")
+			}
+		}
+	case "mkcp":
+		kcpSettings := streamSettings.KCPSettings
+		if kcpSettings == nil {
+			break
+		}
+		if kcpSettings.Seed != nil {
+			qrcode.Path = *kcpSettings.Seed
+		}
+		if kcpSettings.HeaderConfig != nil {
+			var header XrayFakeHeader
+			err := json.Unmarshal(kcpSettings.HeaderConfig, &header)
+			if err != nil {
+				return err
+			}
+			qrcode.Type = header.Type
+		}
+	case "websocket":
+		if wsSettings := streamSettings.WSSettings; wsSettings != nil {
+			qrcode.Host = wsSettings.Host
+			qrcode.Path = wsSettings.Path
+		}
+	case "grpc":
+		if grpcSettings := streamSettings.GRPCSettings; grpcSettings != nil {
+			qrcode.Path = grpcSettings.ServiceName
+			if grpcSettings.MultiMode {
+				qrcode.Type = "multi"
+			} else {
+				qrcode.Type = "gun"
+			}
+		}
+	case "httpupgrade":
+		if httpupgradeSettings := streamSettings.HTTPUPGRADESettings; httpupgradeSettings != nil {
+			qrcode.Host = httpupgradeSettings.Host
+			qrcode.Path = httpupgradeSettings.Path
+		}
+	case "splithttp":
+		if xhttpSettings := streamSettings.XHTTPSettings; xhttpSettings != nil {
+			qrcode.Host = xhttpSettings.Host
+			qrcode.Path = xhttpSettings.Path
+			qrcode.Type = xhttpSettings.Mode
+		}
+	}
+
+	switch streamSettings.Security {
+	case "", "none":
+	case "tls":
+		qrcode.Tls = "tls"
+		if tlsSettings := streamSettings.TLSSettings; tlsSettings != nil {
+			qrcode.Sni = tlsSettings.ServerName
+			qrcode.Fp = tlsSettings.Fingerprint
+			if tlsSettings.ALPN != nil {
+				qrcode.Alpn = strings.Join(*tlsSettings.ALPN, ",")
+			}
+		}
+	default:
+		return fmt.Errorf("unsupport vmess qrcode security: %s", streamSettings.Security)
+	}
+	return nil
+}
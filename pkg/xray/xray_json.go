@@ -2,6 +2,7 @@ package xray
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/infra/conf"
@@ -13,6 +14,7 @@ type XrayRawSettingsHeader struct {
 }
 
 type XrayRawSettingsHeaderRequest struct {
+	Method  string                               `json:"method,omitempty"`
 	Path    []string                             `json:"path,omitempty"`
 	Headers *XrayRawSettingsHeaderRequestHeaders `json:"headers,omitempty"`
 }
@@ -25,15 +27,41 @@ type XrayFakeHeader struct {
 	Type string `json:"type,omitempty"`
 }
 
+type XrayWireGuardSettings struct {
+	SecretKey string              `json:"secretKey,omitempty"`
+	Address   []string            `json:"address,omitempty"`
+	Peers     []XrayWireGuardPeer `json:"peers,omitempty"`
+	Mtu       int                 `json:"mtu,omitempty"`
+	Reserved  []int               `json:"reserved,omitempty"`
+}
+
+type XrayWireGuardPeer struct {
+	PublicKey    string   `json:"publicKey,omitempty"`
+	PreSharedKey string   `json:"preSharedKey,omitempty"`
+	Endpoint     string   `json:"endpoint,omitempty"`
+	KeepAlive    uint32   `json:"keepAlive,omitempty"`
+	AllowedIPs   []string `json:"allowedIPs,omitempty"`
+}
+
+type XrayRoutingRule struct {
+	Type        string   `json:"type,omitempty"`
+	Domain      []string `json:"domain,omitempty"`
+	IP          []string `json:"ip,omitempty"`
+	Port        string   `json:"port,omitempty"`
+	Network     string   `json:"network,omitempty"`
+	OutboundTag string   `json:"outboundTag,omitempty"`
+	BalancerTag string   `json:"balancerTag,omitempty"`
+}
+
+// The display name of an imported outbound travels in its tag, xray treats
+// the tag as an opaque label. SendThrough is the local address to bind and
+// must never carry a name.
 func setOutboundName(outbound *conf.OutboundDetourConfig, name string) {
-	outbound.SendThrough = &name
+	outbound.Tag = name
 }
 
 func getOutboundName(outbound conf.OutboundDetourConfig) string {
-	if outbound.SendThrough != nil {
-		return *outbound.SendThrough
-	}
-	return ""
+	return outbound.Tag
 }
 
 func parseAddress(addr string) *conf.Address {
@@ -42,6 +70,14 @@ func parseAddress(addr string) *conf.Address {
 	return address
 }
 
+// addressString returns the address without the brackets xray puts around ipv6
+func addressString(address *conf.Address) string {
+	if address == nil || address.Address == nil {
+		return ""
+	}
+	return strings.Trim(address.String(), "[]")
+}
+
 func convertJsonToRawMessage(v any) (json.RawMessage, error) {
 	vBytes, err := json.Marshal(v)
 	if err != nil {